@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -9,10 +10,20 @@ import (
 	"os"
 	"os/signal"
 
+	"yujian-backend/pkg/biz"
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/biz/export"
+	"yujian-backend/pkg/config"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/es"
 	mylog "yujian-backend/pkg/log"
+	"yujian-backend/pkg/mail"
 )
 
 func main() {
+	// 读取配置
+	config.InitConfig()
+
 	// 创建日志
 	logger := mylog.GetLogger()
 	defer func(logger *zap.SugaredLogger) {
@@ -22,12 +33,28 @@ func main() {
 		}
 	}(logger)
 
+	// 初始化依赖
+	db.InitDB(*config.Config.DB)
+	es.InitESClient()
+	if err := auth.InitJWT(config.Config.JWT); err != nil {
+		logger.Fatalf("failed to init jwt: %s", err)
+	}
+	if err := mail.InitMailer(config.Config.Mail); err != nil {
+		logger.Fatalf("failed to init mailer: %s", err)
+	}
+
+	// 后台任务, 退出时停止
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go export.RunCleanup(ctx)
+
 	// 启动app
 	r := gin.Default()
+	biz.SetupRouter(r)
 	errQuit := make(chan error, 1)
 	go func() {
-		if err := r.Run(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			// todo[xinhui]: 添加日志,记录错误
+		if err := r.Run(":" + config.Config.Server.Port); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorf("Gin run failed: %s", err)
 			errQuit <- err
 		}
 	}()
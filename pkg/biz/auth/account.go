@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"yujian-backend/pkg/config"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/mail"
+	"yujian-backend/pkg/model"
+)
+
+// normalizeEmail 规范化并校验邮箱地址
+func normalizeEmail(email string) (string, bool) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	addr, err := netmail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", false
+	}
+	return email, true
+}
+
+// checkEmailAvailable 校验邮箱格式以及是否已被其他用户使用, 不可用时返回错误码
+func checkEmailAvailable(email string, userId int64) (string, model.ErrorCode, error) {
+	email, ok := normalizeEmail(email)
+	if !ok {
+		return "", model.EmailInvalid, nil
+	}
+	existingUser, err := db.GetUserRepository().GetUserByEmail(email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", model.Success, err
+	}
+	if err == nil && existingUser.Id != userId {
+		return "", model.EmailExists, nil
+	}
+	return email, model.Success, nil
+}
+
+// mailLink 拼接邮件中的链接
+func mailLink(path, token string) string {
+	return fmt.Sprintf("%s%s?token=%s", strings.TrimRight(config.Config.Mail.LinkBaseURL, "/"), path, url.QueryEscape(token))
+}
+
+// sendVerificationMail 向用户的邮箱发送验证邮件
+func sendVerificationMail(user *model.UserDTO) error {
+	token, err := generateActionToken(purposeVerifyEmail, actionClaims{
+		UserId: user.Id,
+		Email:  user.Email,
+	}, jwtConfig.VerifyExpire)
+	if err != nil {
+		return err
+	}
+	return mail.GetMailer().Send(&mail.Message{
+		To:      []string{user.Email},
+		Subject: "遇荐 - 验证你的邮箱",
+		Body: fmt.Sprintf("%s 你好,\n\n请点击下面的链接验证邮箱, 链接 %s 内有效:\n%s\n\n如果不是你本人操作, 请忽略这封邮件。\n",
+			user.Name, jwtConfig.VerifyExpire, mailLink("/verify-email", token)),
+	})
+}
+
+// RequestEmailVerification 设置邮箱或重发验证邮件, 需要登录
+func RequestEmailVerification() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRepository := db.GetUserRepository()
+
+		var req model.EmailVerificationRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Error: errors.New("invalid request body")})
+			return
+		}
+
+		currentUser, ok := GetCurrentUser(c)
+		if !ok {
+			abortUnauthorized(c)
+			return
+		}
+		userDTO, err := userRepository.GetUserById(currentUser.Id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, model.BaseResp{Code: model.UserNotExists, ErrMsg: "用户不存在"})
+			return
+		}
+
+		// 传了新邮箱就先更新, 新邮箱处于未验证状态
+		if req.Email != "" {
+			email, code, err := checkEmailAvailable(req.Email, userDTO.Id)
+			if err != nil {
+				log.GetLogger().Errorf("查询邮箱失败: %v", err)
+				c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+				return
+			}
+			if code != model.Success {
+				c.JSON(http.StatusBadRequest, model.BaseResp{Code: code, ErrMsg: "邮箱不可用"})
+				return
+			}
+			if email != userDTO.Email {
+				if err = userRepository.UpdateEmail(userDTO.Id, email); errors.Is(err, gorm.ErrDuplicatedKey) {
+					c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.EmailExists, ErrMsg: "邮箱不可用"})
+					return
+				} else if err != nil {
+					log.GetLogger().Errorf("更新邮箱失败: %v", err)
+					c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+					return
+				}
+				userDTO.Email = email
+				userDTO.EmailVerified = false
+			}
+		}
+
+		if userDTO.Email == "" {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.EmailNotSet, ErrMsg: "尚未设置邮箱"})
+			return
+		}
+		if userDTO.EmailVerified {
+			c.JSON(http.StatusOK, model.BaseResp{Code: model.Success, ErrMsg: "邮箱已验证"})
+			return
+		}
+
+		if err = sendVerificationMail(userDTO); err != nil {
+			log.GetLogger().Errorf("发送验证邮件失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("failed to send mail")})
+			return
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// ConfirmEmailVerification 提交验证邮件中的令牌, 完成邮箱验证
+func ConfirmEmailVerification() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.ConfirmTokenRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Error: errors.New("invalid request body")})
+			return
+		}
+
+		claims, err := parseActionToken(purposeVerifyEmail, req.Token)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.ActionTokenInvalid, ErrMsg: "验证链接无效或已过期"})
+			return
+		}
+
+		// 签发之后邮箱又被修改过, 旧链接不再有效
+		verified, err := db.GetUserRepository().MarkEmailVerified(claims.UserId, claims.Email)
+		if err != nil {
+			log.GetLogger().Errorf("验证邮箱失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		if !verified {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.ActionTokenInvalid, ErrMsg: "验证链接无效或已过期"})
+			return
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// RequestPasswordReset 申请重置密码, 向已验证的邮箱发送重置邮件
+// 无论邮箱是否存在都返回成功, 避免被用来探测注册邮箱
+func RequestPasswordReset() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRepository := db.GetUserRepository()
+
+		var req model.PasswordResetRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Error: errors.New("invalid request body")})
+			return
+		}
+		email, ok := normalizeEmail(req.Email)
+		if !ok {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.EmailInvalid, ErrMsg: "邮箱格式不正确"})
+			return
+		}
+
+		userDTO, err := userRepository.GetUserByEmail(email)
+		if err != nil || !userDTO.EmailVerified {
+			c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+			return
+		}
+		credential, err := userRepository.GetUserCredentialById(userDTO.Id)
+		if err != nil {
+			log.GetLogger().Errorf("查询用户失败: %v", err)
+			c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+			return
+		}
+
+		token, err := generateActionToken(purposeResetPassword, actionClaims{
+			UserId:      userDTO.Id,
+			Fingerprint: passwordFingerprint(credential.Password),
+		}, jwtConfig.ResetExpire)
+		if err != nil {
+			log.GetLogger().Errorf("签发重置令牌失败: %v", err)
+			c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+			return
+		}
+		if err = mail.GetMailer().Send(&mail.Message{
+			To:      []string{userDTO.Email},
+			Subject: "遇荐 - 重置密码",
+			Body: fmt.Sprintf("%s 你好,\n\n请点击下面的链接重置密码, 链接 %s 内有效且只能使用一次:\n%s\n\n如果不是你本人操作, 请忽略这封邮件。\n",
+				userDTO.Name, jwtConfig.ResetExpire, mailLink("/reset-password", token)),
+		}); err != nil {
+			log.GetLogger().Errorf("发送重置邮件失败: %v", err)
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// ConfirmPasswordReset 提交重置邮件中的令牌和新密码
+// 重置成功后吊销该用户的全部会话
+func ConfirmPasswordReset() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRepository := db.GetUserRepository()
+
+		var req model.PasswordResetConfirmDTO
+		if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" || req.NewPassword == "" {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Error: errors.New("invalid request body")})
+			return
+		}
+
+		invalidResp := model.BaseResp{Code: model.ActionTokenInvalid, ErrMsg: "重置链接无效或已过期"}
+		claims, err := parseActionToken(purposeResetPassword, req.Token)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, invalidResp)
+			return
+		}
+		credential, err := userRepository.GetUserCredentialById(claims.UserId)
+		if err != nil || passwordFingerprint(credential.Password) != claims.Fingerprint {
+			c.JSON(http.StatusBadRequest, invalidResp)
+			return
+		}
+
+		passwordHash, err := HashPassword(req.NewPassword)
+		if errors.Is(err, ErrPasswordTooLong) {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Error: errors.New("password is too long")})
+			return
+		} else if err != nil {
+			log.GetLogger().Errorf("密码哈希失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		if err = userRepository.UpdatePassword(credential.Id, passwordHash); err != nil {
+			log.GetLogger().Errorf("更新密码失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+
+		RecordAudit(c, model.AuditPasswordChange, credential.Id, credential.Name, "reset")
+
+		if err = db.GetSessionRepository().RevokeUserSessions(credential.Id); err != nil {
+			log.GetLogger().Errorf("吊销会话失败: %v", err)
+		}
+		resetLoginFailures(credential.Name)
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 一次性令牌的用途, 写在令牌的受众字段里, 不同用途的令牌不能混用
+const (
+	purposeVerifyEmail   = "verify_email"
+	purposeResetPassword = "reset_password"
+	purposeDataExport    = "data_export"
+)
+
+// actionClaims 邮箱验证、密码重置等一次性令牌携带的信息
+type actionClaims struct {
+	UserId int64  `json:"user_id"`
+	Email  string `json:"email,omitempty"`
+	// Fingerprint 签发时密码哈希的摘要, 密码改过之后令牌自动失效
+	Fingerprint string `json:"fp,omitempty"`
+	// ResourceId 令牌授权访问的资源ID, 例如导出任务ID
+	ResourceId int64 `json:"rid,omitempty"`
+	// OIDC 第三方登录发起时的状态
+	OIDC *oidcLoginState `json:"oidc,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// generateActionToken 签发指定用途的一次性令牌
+func generateActionToken(purpose string, claims actionClaims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:    jwtConfig.Issuer,
+		Subject:   strconv.FormatInt(claims.UserId, 10),
+		Audience:  jwt.ClaimStrings{purpose},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+	return jwt.NewWithClaims(signingMethod, claims).SignedString(signKey)
+}
+
+// parseActionToken 校验指定用途的一次性令牌
+func parseActionToken(purpose, tokenString string) (*actionClaims, error) {
+	claims := &actionClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			return verifyKey, nil
+		},
+		jwt.WithValidMethods([]string{signingMethod.Alg()}),
+		jwt.WithIssuer(jwtConfig.Issuer),
+		jwt.WithAudience(purpose),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// passwordFingerprint 计算密码哈希的摘要
+func passwordFingerprint(passwordHash string) string {
+	sum := sha256.Sum256([]byte(passwordHash))
+	return hex.EncodeToString(sum[:8])
+}
+
+// GenerateDownloadToken 签发数据导出的下载令牌, 放在下载链接里, 持有链接即可下载
+func GenerateDownloadToken(userId, exportId int64, ttl time.Duration) (string, error) {
+	return generateActionToken(purposeDataExport, actionClaims{
+		UserId:     userId,
+		ResourceId: exportId,
+	}, ttl)
+}
+
+// ParseDownloadToken 校验数据导出的下载令牌, 返回用户ID和导出任务ID
+func ParseDownloadToken(tokenString string) (userId int64, exportId int64, err error) {
+	claims, err := parseActionToken(purposeDataExport, tokenString)
+	if err != nil {
+		return 0, 0, err
+	}
+	return claims.UserId, claims.ResourceId, nil
+}
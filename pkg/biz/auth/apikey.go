@@ -0,0 +1,329 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"yujian-backend/pkg/config"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+)
+
+const (
+	// apiKeyHeader 携带 API Key 的请求头
+	apiKeyHeader = "X-Api-Key"
+	// apiKeyPrefix 密钥的固定前缀, 方便在日志和代码仓库中识别泄露的密钥
+	apiKeyPrefix = "yjk_"
+	// apiKeyBytes 密钥的随机字节数
+	apiKeyBytes = 32
+	// apiKeyDisplayLen 保存下来用于辨认的密钥前缀长度
+	apiKeyDisplayLen = 12
+	// apiKeyTouchInterval 最近使用时间的更新间隔, 避免每个请求都写库
+	apiKeyTouchInterval = time.Minute
+)
+
+// currentAPIKeyKey 当前请求使用的 API Key 在gin上下文中的键
+const currentAPIKeyKey = "current_api_key"
+
+// hashAPIKey 计算 API Key 的哈希, 数据库只保存哈希
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate 接受 JWT 或 X-Api-Key 两种认证方式, 并把当前用户写入上下文
+// 使用 API Key 时要求密钥拥有全部 scopes; JWT 代表用户本人, 不受 scope 限制
+func Authenticate(scopes ...model.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rawKey := c.GetHeader(apiKeyHeader); rawKey != "" {
+			if !authenticateAPIKey(c, rawKey, scopes) {
+				return
+			}
+		} else if !authenticateJWT(c) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// OptionalAuthenticate 用于公开的读取接口, 不带凭证时按未登录继续处理
+// 带了 X-Api-Key 时同 Authenticate 一样校验密钥和 scopes, 不通过时拒绝请求; 带了 JWT 时同 OptionalJWTAuth
+func OptionalAuthenticate(scopes ...model.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rawKey := c.GetHeader(apiKeyHeader); rawKey != "" {
+			if !authenticateAPIKey(c, rawKey, scopes) {
+				return
+			}
+		} else {
+			setOptionalJWTUser(c)
+		}
+		c.Next()
+	}
+}
+
+// authenticateAPIKey 校验 API Key 及其 scopes 并写入当前用户, 失败时中断请求并返回 false
+func authenticateAPIKey(c *gin.Context, rawKey string, scopes []model.Scope) bool {
+	key, code, err := checkAPIKey(rawKey, time.Now())
+	if err != nil {
+		log.GetLogger().Errorf("校验API Key失败: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+		return false
+	}
+	if code != model.Success {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, model.BaseResp{Code: code, ErrMsg: "API Key 无效"})
+		return false
+	}
+	for _, scope := range scopes {
+		if !key.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, model.BaseResp{
+				Code:   model.APIKeyScopeDenied,
+				ErrMsg: "API Key 缺少授权范围 " + string(scope),
+			})
+			return false
+		}
+	}
+
+	// 角色以数据库为准, 密钥创建后用户被降权同样生效
+	userDTO, err := db.GetUserRepository().GetUserById(key.UserId)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, model.BaseResp{Code: model.APIKeyInvalid, ErrMsg: "API Key 无效"})
+		return false
+	}
+	c.Set(currentUserKey, &model.UserDTO{
+		Id:   userDTO.Id,
+		Name: userDTO.Name,
+		Role: userDTO.Role,
+	})
+	c.Set(currentAPIKeyKey, key)
+	return true
+}
+
+// checkAPIKey 校验 API Key 是否存在、未吊销且未过期, 不可用时返回错误码
+func checkAPIKey(rawKey string, now time.Time) (*model.APIKeyDO, model.ErrorCode, error) {
+	apiKeyRepository := db.GetAPIKeyRepository()
+
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) {
+		return nil, model.APIKeyInvalid, nil
+	}
+	key, err := apiKeyRepository.GetAPIKeyByHash(hashAPIKey(rawKey))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.APIKeyInvalid, nil
+		}
+		return nil, model.Success, err
+	}
+	if key.RevokedAt != nil {
+		return nil, model.APIKeyInvalid, nil
+	}
+	if !now.Before(key.ExpiresAt) {
+		return nil, model.APIKeyExpired, nil
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err = apiKeyRepository.TouchAPIKey(key.Id, now); err != nil {
+			log.GetLogger().Warnf("更新API Key使用时间失败, id=%d: %v", key.Id, err)
+		}
+	}
+	return key, model.Success, nil
+}
+
+// GetCurrentAPIKey 获取当前请求使用的 API Key, 使用 JWT 认证时返回 false
+func GetCurrentAPIKey(c *gin.Context) (*model.APIKeyDO, bool) {
+	value, exists := c.Get(currentAPIKeyKey)
+	if !exists {
+		return nil, false
+	}
+	key, ok := value.(*model.APIKeyDO)
+	return key, ok
+}
+
+// CreateAPIKey 创建 API Key, 密钥明文只在响应中返回一次
+// 默认为当前用户创建; 拥有用户管理权限时可以为服务账号等其他用户创建
+func CreateAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKeyRepository := db.GetAPIKeyRepository()
+		apiKeyConfig := config.Config.APIKey
+
+		var req model.CreateAPIKeyRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.CreateAPIKeyResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid request body")},
+			})
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || len([]rune(req.Name)) > 64 || len(req.Scopes) == 0 {
+			c.JSON(http.StatusBadRequest, model.CreateAPIKeyResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("name and scopes are required")},
+			})
+			return
+		}
+		scopes := make([]model.Scope, 0, len(req.Scopes))
+		seen := make(map[model.Scope]bool, len(req.Scopes))
+		for _, scope := range req.Scopes {
+			if !scope.Valid() {
+				c.JSON(http.StatusBadRequest, model.CreateAPIKeyResponseDTO{
+					BaseResp: model.BaseResp{Error: errors.New("invalid scope: " + string(scope))},
+				})
+				return
+			}
+			if !seen[scope] {
+				seen[scope] = true
+				scopes = append(scopes, scope)
+			}
+		}
+
+		currentUser, ok := GetCurrentUser(c)
+		if !ok {
+			abortUnauthorized(c)
+			return
+		}
+		ownerId := currentUser.Id
+		if req.UserId != 0 && req.UserId != currentUser.Id {
+			if !currentUser.Role.HasPermission(model.PermManageUsers) {
+				AbortForbidden(c)
+				return
+			}
+			if _, err := db.GetUserRepository().GetUserById(req.UserId); err != nil {
+				c.JSON(http.StatusNotFound, model.CreateAPIKeyResponseDTO{
+					BaseResp: model.BaseResp{Code: model.UserNotExists, ErrMsg: "用户不存在"},
+				})
+				return
+			}
+			ownerId = req.UserId
+		}
+
+		now := time.Now()
+		expiresAt := now.Add(apiKeyConfig.DefaultExpire)
+		if req.ExpiresAt != nil {
+			expiresAt = *req.ExpiresAt
+		}
+		if !expiresAt.After(now) || expiresAt.Sub(now) > apiKeyConfig.MaxExpire {
+			c.JSON(http.StatusBadRequest, model.CreateAPIKeyResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid expires_at")},
+			})
+			return
+		}
+
+		if count, err := apiKeyRepository.CountActiveAPIKeys(ownerId, now); err != nil {
+			log.GetLogger().Errorf("统计API Key失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.CreateAPIKeyResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		} else if count >= int64(apiKeyConfig.MaxPerUser) {
+			c.JSON(http.StatusBadRequest, model.CreateAPIKeyResponseDTO{
+				BaseResp: model.BaseResp{Code: model.APIKeyLimitReached, ErrMsg: "API Key 数量已达上限"},
+			})
+			return
+		}
+
+		secret, err := randomToken(apiKeyBytes)
+		if err != nil {
+			log.GetLogger().Errorf("生成API Key失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.CreateAPIKeyResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		rawKey := apiKeyPrefix + secret
+		key := &model.APIKeyDO{
+			UserId:     ownerId,
+			Name:       req.Name,
+			Prefix:     rawKey[:apiKeyDisplayLen],
+			KeyHash:    hashAPIKey(rawKey),
+			Scopes:     model.JoinScopes(scopes),
+			ExpiresAt:  expiresAt,
+			CreateTime: now,
+		}
+		if err = apiKeyRepository.CreateAPIKey(key); err != nil {
+			log.GetLogger().Errorf("保存API Key失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.CreateAPIKeyResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		RecordAudit(c, model.AuditAPIKeyCreate, ownerId, "", key.Name+" ["+key.Scopes+"]")
+
+		c.JSON(http.StatusCreated, model.CreateAPIKeyResponseDTO{
+			Key:    rawKey,
+			APIKey: key.Transfer(),
+		})
+	}
+}
+
+// ListAPIKeys 列出当前用户的 API Key, 拥有用户管理权限时可以用 user_id 查询其他用户
+func ListAPIKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := GetCurrentUser(c)
+		if !ok {
+			abortUnauthorized(c)
+			return
+		}
+
+		userId := currentUser.Id
+		if v := c.Query("user_id"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, model.APIKeyListResponseDTO{
+					BaseResp: model.BaseResp{Error: errors.New("invalid user_id")},
+				})
+				return
+			}
+			if id != currentUser.Id && !currentUser.Role.HasPermission(model.PermManageUsers) {
+				AbortForbidden(c)
+				return
+			}
+			userId = id
+		}
+
+		keys, err := db.GetAPIKeyRepository().ListAPIKeysByUserId(userId)
+		if err != nil {
+			log.GetLogger().Errorf("查询API Key失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.APIKeyListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.APIKeyListResponseDTO{Keys: keys})
+	}
+}
+
+// RevokeAPIKey 吊销 API Key, 本人或拥有用户管理权限的用户可以操作
+func RevokeAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKeyRepository := db.GetAPIKeyRepository()
+
+		keyId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Error: errors.New("invalid key id")})
+			return
+		}
+
+		key, err := apiKeyRepository.GetAPIKeyById(keyId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, model.BaseResp{Code: model.APIKeyInvalid, ErrMsg: "API Key 不存在"})
+			return
+		}
+		if !AuthorizeOwner(c, key.UserId, model.PermManageUsers) {
+			return
+		}
+
+		if err = apiKeyRepository.RevokeAPIKey(key.Id); err != nil {
+			log.GetLogger().Errorf("吊销API Key失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		RecordAudit(c, model.AuditAPIKeyRevoke, key.UserId, "", key.Name)
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
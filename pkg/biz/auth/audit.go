@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+const (
+	auditDefaultLimit = 20
+	auditMaxLimit     = 100
+)
+
+// RecordAudit 记录一条审计事件, 请求来源和操作人从上下文中获取
+// 审计写入失败只记录日志, 不影响业务请求
+func RecordAudit(c *gin.Context, eventType model.AuditEventType, userId int64, userName string, detail string) {
+	event := &model.AuditEventDO{
+		EventType: eventType,
+		UserId:    userId,
+		UserName:  utils.Truncate(userName, 64),
+		IP:        c.ClientIP(),
+		UserAgent: utils.Truncate(c.Request.UserAgent(), 255),
+		Detail:    utils.Truncate(detail, 255),
+	}
+	if currentUser, ok := GetCurrentUser(c); ok {
+		event.ActorId = currentUser.Id
+	}
+	if err := db.GetAuditRepository().RecordEvent(event); err != nil {
+		log.GetLogger().Errorf("记录审计事件失败, type=%s, userId=%d: %v", eventType, userId, err)
+	}
+}
+
+// QueryAuditEvents 查询审计事件, 支持按用户、事件类型和时间范围过滤, 仅管理员可用
+// 时间参数使用 RFC3339 格式, 例如 2024-01-02T15:04:05+08:00
+func QueryAuditEvents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := model.AuditQuery{
+			EventType: model.AuditEventType(c.Query("event_type")),
+		}
+
+		var err error
+		if v := c.Query("user_id"); v != "" {
+			if query.UserId, err = strconv.ParseInt(v, 10, 64); err != nil {
+				c.JSON(http.StatusBadRequest, model.AuditEventListResponseDTO{
+					BaseResp: model.BaseResp{Error: errors.New("invalid user_id")},
+				})
+				return
+			}
+		}
+		if v := c.Query("start"); v != "" {
+			if query.Start, err = time.Parse(time.RFC3339, v); err != nil {
+				c.JSON(http.StatusBadRequest, model.AuditEventListResponseDTO{
+					BaseResp: model.BaseResp{Error: errors.New("invalid start")},
+				})
+				return
+			}
+		}
+		if v := c.Query("end"); v != "" {
+			if query.End, err = time.Parse(time.RFC3339, v); err != nil {
+				c.JSON(http.StatusBadRequest, model.AuditEventListResponseDTO{
+					BaseResp: model.BaseResp{Error: errors.New("invalid end")},
+				})
+				return
+			}
+		}
+		var ok bool
+		if query.Offset, query.Limit, ok = utils.ParsePage(c.Query("offset"), c.Query("limit"), auditDefaultLimit, auditMaxLimit); !ok {
+			c.JSON(http.StatusBadRequest, model.AuditEventListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid offset or limit")},
+			})
+			return
+		}
+
+		events, total, err := db.GetAuditRepository().QueryEvents(&query)
+		if err != nil {
+			log.GetLogger().Errorf("查询审计事件失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.AuditEventListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.AuditEventListResponseDTO{
+			Total:  total,
+			Events: events,
+		})
+	}
+}
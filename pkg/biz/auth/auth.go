@@ -2,9 +2,15 @@ package auth
 
 import (
 	"errors"
-	"github.com/gin-gonic/gin"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
 	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
 	"yujian-backend/pkg/model"
 )
 
@@ -21,42 +27,79 @@ func UserLogin() gin.HandlerFunc {
 		if err = c.ShouldBindJSON(&authInfo); err != nil {
 			// 当请求体无法被正确解析时，返回错误响应
 			badBody := model.LoginResponseDTO{
-				Error: errors.New("invalid request body"),
+				BaseResp: model.BaseResp{Error: errors.New("invalid request body")},
 			}
 			c.JSON(http.StatusBadRequest, badBody)
 			return
 		}
 
+		// 防爆破: 账号被锁定、IP被封禁或处于退避期时直接拒绝
+		clientIP := c.ClientIP()
+		if code, wait, allowed := checkLoginAllowed(authInfo.UserName, clientIP, time.Now()); !allowed {
+			RecordAudit(c, model.AuditLoginFailure, 0, authInfo.UserName, "throttled")
+			c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, model.LoginResponseDTO{
+				BaseResp: model.BaseResp{
+					Code:   code,
+					ErrMsg: "登录尝试过于频繁, 请稍后再试",
+				},
+			})
+			return
+		}
+
 		// 查数据库
-		var userDTO *model.UserDTO
-		if userDTO, err = userRepository.GetUserByName(authInfo.UserName); err != nil {
-			// 当数据库中找不到指定用户名的用户时，返回错误响应
-			userNotFound := model.LoginResponseDTO{
-				Error: errors.New("user not found"),
-			}
-			c.JSON(http.StatusOK, userNotFound)
+		userDO, err := userRepository.GetUserCredentialByName(authInfo.UserName)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.GetLogger().Errorf("查询用户失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.LoginResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
 			return
+		}
+
+		// 验证用户密码; 用户不存在或没有设置密码时同样做一次 bcrypt 比对, 错误码和耗时都不暴露用户名是否存在
+		var ok, needRehash bool
+		if userDO != nil && userDO.Password != "" {
+			ok, needRehash = CheckPassword(userDO.Password, authInfo.Password)
 		} else {
-			// todo[xinhui] 用JWT来解决
-			// 验证用户密码
-			if userDTO.Password == authInfo.Password {
-				// 当密码匹配时，返回包含令牌和用户信息的成功响应
-				okResp := model.LoginResponseDTO{
-					Token: "123",
-					User:  *userDTO,
-					Error: nil,
-				}
-				c.JSON(http.StatusOK, okResp)
-				return
+			CheckPassword(dummyPasswordHash(), authInfo.Password)
+		}
+		if !ok {
+			recordLoginFailure(authInfo.UserName, clientIP, time.Now())
+			if userDO == nil {
+				RecordAudit(c, model.AuditLoginFailure, 0, authInfo.UserName, "user not found")
 			} else {
-				// 当密码不匹配时，返回错误响应
-				invalidPassWord := model.LoginResponseDTO{
-					Error: errors.New("invalid request body"),
-				}
-				c.JSON(http.StatusOK, invalidPassWord)
-				return
+				RecordAudit(c, model.AuditLoginFailure, userDO.Id, userDO.Name, "wrong password")
 			}
+			c.JSON(http.StatusOK, model.LoginResponseDTO{
+				BaseResp: model.BaseResp{
+					Code:  model.InvalidCredentials,
+					Error: errors.New("invalid username or password"),
+				},
+			})
+			return
 		}
+		resetLoginFailures(authInfo.UserName)
+
+		// 历史明文密码或代价变更的哈希, 登录成功后重新哈希
+		if needRehash {
+			rehashPassword(userDO.Id, authInfo.Password)
+		}
+
+		// 签发令牌, 启用了二次验证的用户先返回中间令牌
+		respondLogin(c, userDO.Transfer(), "password")
+	}
+}
+
+// rehashPassword 重新哈希并保存用户密码, 失败只记录日志, 不影响本次登录
+func rehashPassword(userId int64, password string) {
+	hash, err := HashPassword(password)
+	if err != nil {
+		log.GetLogger().Errorf("重新哈希密码失败: %v", err)
+		return
+	}
+	if err = db.GetUserRepository().UpdatePassword(userId, hash); err != nil {
+		log.GetLogger().Errorf("保存密码哈希失败, userId=%d: %v", userId, err)
 	}
 }
 
@@ -73,50 +116,129 @@ func UserRegister() gin.HandlerFunc {
 		if err = c.ShouldBindJSON(&registerInfo); err != nil {
 			// 当请求体无法被正确解析时，返回错误响应
 			badBody := model.RegisterResponseDTO{
-				Error: errors.New("invalid request body"),
+				BaseResp: model.BaseResp{Error: errors.New("invalid request body")},
 			}
 			c.JSON(http.StatusBadRequest, badBody)
 			return
 		}
+		// 空密码的 bcrypt 哈希能被空密码通过校验, 用户名和密码都必须填写
+		if registerInfo.UserName == "" || registerInfo.Password == "" {
+			c.JSON(http.StatusBadRequest, model.RegisterResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("username and password are required")},
+			})
+			return
+		}
+		if len([]rune(registerInfo.UserName)) > model.MaxUserNameLen {
+			c.JSON(http.StatusBadRequest, model.RegisterResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("username is too long")},
+			})
+			return
+		}
 
 		// 检查用户名是否已存在
 		var existingUser *model.UserDTO
-		if existingUser, err = userRepository.GetUserByName(registerInfo.UserName); err != nil {
+		if existingUser, err = userRepository.GetUserByName(registerInfo.UserName); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 			internalErr := model.RegisterResponseDTO{
-				Error: errors.New("internal server error"),
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
 			}
 			c.JSON(http.StatusInternalServerError, internalErr)
 			return
 		} else if err == nil && existingUser != nil {
 			// 当用户名已存在时，返回错误响应
 			userExists := model.RegisterResponseDTO{
-				Error: errors.New("user already exists"),
+				BaseResp: model.BaseResp{
+					Code:  model.UserExists,
+					Error: errors.New("user already exists"),
+				},
 			}
 			c.JSON(http.StatusOK, userExists)
 			return
 		}
 
-		// 创建新用户
+		// 邮箱可选, 填写时校验格式和唯一性
+		var email string
+		if registerInfo.Email != "" {
+			var code model.ErrorCode
+			if email, code, err = checkEmailAvailable(registerInfo.Email, 0); err != nil {
+				log.GetLogger().Errorf("查询邮箱失败: %v", err)
+				c.JSON(http.StatusInternalServerError, model.RegisterResponseDTO{
+					BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+				})
+				return
+			} else if code != model.Success {
+				c.JSON(http.StatusOK, model.RegisterResponseDTO{
+					BaseResp: model.BaseResp{Code: code, ErrMsg: "邮箱不可用"},
+				})
+				return
+			}
+		}
+
+		// 创建新用户, 只存储密码哈希
+		passwordHash, err := HashPassword(registerInfo.Password)
+		if errors.Is(err, ErrPasswordTooLong) {
+			c.JSON(http.StatusBadRequest, model.RegisterResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("password is too long")},
+			})
+			return
+		} else if err != nil {
+			log.GetLogger().Errorf("密码哈希失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.RegisterResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
 		newUser := &model.UserDTO{
 			Name:     registerInfo.UserName,
-			Password: registerInfo.Password, // 注意：在实际应用中，应存储加密后的密码
+			Password: passwordHash,
+			Role:     model.RoleUser,
+			Email:    email,
 		}
-		if id, err := userRepository.CreateUser(newUser); err != nil {
+		if id, err := userRepository.CreateUser(newUser); errors.Is(err, gorm.ErrDuplicatedKey) {
+			// 并发注册抢先用了同一个用户名或邮箱
+			conflict := model.RegisterResponseDTO{
+				BaseResp: model.BaseResp{Code: model.EmailExists, ErrMsg: "邮箱不可用"},
+			}
+			if _, nameErr := userRepository.GetUserByName(newUser.Name); nameErr == nil {
+				conflict.BaseResp = model.BaseResp{Code: model.UserExists, Error: errors.New("user already exists")}
+			}
+			c.JSON(http.StatusOK, conflict)
+			return
+		} else if err != nil {
 			// 当用户创建失败时，返回错误响应
 			createFailed := model.RegisterResponseDTO{
-				Error: errors.New("failed to create user"),
+				BaseResp: model.BaseResp{Error: errors.New("failed to create user")},
 			}
 			c.JSON(http.StatusInternalServerError, createFailed)
 			return
 		} else {
 			newUser.Id = id
+			newUser.Password = ""
+		}
+
+		RecordAudit(c, model.AuditRegister, newUser.Id, newUser.Name, "")
+
+		// 验证邮件发送失败不影响注册, 用户可以稍后重发
+		if newUser.Email != "" {
+			if err = sendVerificationMail(newUser); err != nil {
+				log.GetLogger().Errorf("发送验证邮件失败: %v", err)
+			}
+		}
+
+		// 注册成功，签发令牌
+		token, refreshToken, err := IssueTokenPair(newUser)
+		if err != nil {
+			log.GetLogger().Errorf("签发令牌失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.RegisterResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
 		}
 
-		// 注册成功，返回包含令牌和用户信息的成功响应
+		// 返回包含令牌和用户信息的成功响应
 		okResp := model.RegisterResponseDTO{
-			Token: "123", // todo[xinhui] 用JWT来解决
-			User:  *newUser,
-			Error: nil,
+			Token:        token,
+			RefreshToken: refreshToken,
+			User:         *newUser,
 		}
 		c.JSON(http.StatusOK, okResp)
 	}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"yujian-backend/pkg/model"
+)
+
+// Claims 令牌中携带的用户信息
+type Claims struct {
+	UserId   int64      `json:"user_id"`
+	UserName string     `json:"user_name"`
+	Role     model.Role `json:"role"`
+	jwt.RegisteredClaims
+}
+
+var (
+	jwtConfig     *model.JWTConfig
+	signingMethod jwt.SigningMethod
+	signKey       interface{}
+	verifyKey     interface{}
+)
+
+// HS256 密钥的要求
+const (
+	minJWTSecretLen      = 32
+	placeholderJWTSecret = "change-me"
+)
+
+// InitJWT 根据配置初始化签名算法和密钥
+func InitJWT(config *model.JWTConfig) error {
+	switch strings.ToUpper(config.Algorithm) {
+	case "HS256":
+		// 仓库里示例配置的占位密钥是公开的, 用它签名等于谁都能伪造令牌
+		if config.Secret == placeholderJWTSecret {
+			return errors.New("jwt secret is the placeholder from the example config, set a random secret")
+		}
+		if len(config.Secret) < minJWTSecretLen {
+			return fmt.Errorf("jwt secret must be at least %d bytes", minJWTSecretLen)
+		}
+		signingMethod = jwt.SigningMethodHS256
+		signKey = []byte(config.Secret)
+		verifyKey = signKey
+	case "RS256":
+		privateKeyPEM, err := os.ReadFile(config.PrivateKeyFile)
+		if err != nil {
+			return fmt.Errorf("读取私钥失败: %v", err)
+		}
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privateKeyPEM)
+		if err != nil {
+			return fmt.Errorf("解析私钥失败: %v", err)
+		}
+		publicKeyPEM, err := os.ReadFile(config.PublicKeyFile)
+		if err != nil {
+			return fmt.Errorf("读取公钥失败: %v", err)
+		}
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM(publicKeyPEM)
+		if err != nil {
+			return fmt.Errorf("解析公钥失败: %v", err)
+		}
+		signingMethod = jwt.SigningMethodRS256
+		signKey = privateKey
+		verifyKey = publicKey
+	default:
+		return fmt.Errorf("unsupported jwt algorithm: %s", config.Algorithm)
+	}
+	jwtConfig = config
+	return nil
+}
+
+// GenerateToken 为用户签发访问令牌
+func GenerateToken(user *model.UserDTO) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		UserId:   user.Id,
+		UserName: user.Name,
+		Role:     user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    jwtConfig.Issuer,
+			Subject:   strconv.FormatInt(user.Id, 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(jwtConfig.Expire)),
+		},
+	}
+	return jwt.NewWithClaims(signingMethod, claims).SignedString(signKey)
+}
+
+// ParseToken 校验令牌签名和有效期,返回其中的用户信息
+func ParseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			return verifyKey, nil
+		},
+		jwt.WithValidMethods([]string{signingMethod.Alg()}),
+		jwt.WithIssuer(jwtConfig.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	// 带受众的是邮箱验证、密码重置等一次性令牌, 不能当作访问令牌
+	if len(claims.Audience) > 0 {
+		return nil, jwt.ErrTokenInvalidAudience
+	}
+	return claims, nil
+}
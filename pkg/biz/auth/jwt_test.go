@@ -0,0 +1,19 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"yujian-backend/pkg/model"
+)
+
+func TestInitJWTRejectsWeakSecret(t *testing.T) {
+	for _, secret := range []string{"", placeholderJWTSecret, strings.Repeat("x", minJWTSecretLen-1)} {
+		if err := InitJWT(&model.JWTConfig{Algorithm: "HS256", Secret: secret}); err == nil {
+			t.Fatalf("InitJWT accepted secret %q", secret)
+		}
+	}
+	if err := InitJWT(&model.JWTConfig{Algorithm: "HS256", Secret: strings.Repeat("x", minJWTSecretLen)}); err != nil {
+		t.Fatalf("InitJWT rejected a %d-byte secret: %v", minJWTSecretLen, err)
+	}
+}
@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/config"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+)
+
+// LoginAttempt 某个用户名或IP的登录失败记录
+type LoginAttempt struct {
+	Failures    int       // 统计窗口内连续失败的次数
+	LastFailure time.Time // 最近一次失败时间
+	LockedUntil time.Time // 锁定截止时间, 零值表示未锁定
+}
+
+// LoginAttemptStore 登录失败计数的存储
+// 单机部署使用 MemoryAttemptStore, 多节点部署需要换成共享存储的实现
+type LoginAttemptStore interface {
+	// Get 获取失败记录, 不存在或已超出统计窗口时返回 nil
+	Get(key string, window time.Duration) (*LoginAttempt, error)
+	// Incr 记录一次失败, 返回更新后的记录
+	Incr(key string, now time.Time, window time.Duration) (*LoginAttempt, error)
+	// Lock 锁定到 until 为止, 同时清零失败次数
+	Lock(key string, until time.Time) error
+	// Reset 清除失败记录
+	Reset(key string) error
+}
+
+// attemptSweepMinInserts 至少新增这么多条记录后才清理一次过期记录
+const attemptSweepMinInserts = 1024
+
+// MemoryAttemptStore 基于内存的 LoginAttemptStore 实现
+type MemoryAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]*LoginAttempt
+	inserts  int // 上次清理之后新增的记录数
+	lastSize int // 上次清理之后剩下的记录数
+}
+
+// NewMemoryAttemptStore 创建内存存储
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{attempts: make(map[string]*LoginAttempt)}
+}
+
+// expired 记录是否已经没有意义, 可以丢弃
+func (a *LoginAttempt) expired(now time.Time, window time.Duration) bool {
+	return now.After(a.LockedUntil) && now.Sub(a.LastFailure) > window
+}
+
+func (s *MemoryAttemptStore) Get(key string, window time.Duration) (*LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok {
+		return nil, nil
+	}
+	if attempt.expired(time.Now(), window) {
+		delete(s.attempts, key)
+		return nil, nil
+	}
+	copied := *attempt
+	return &copied, nil
+}
+
+func (s *MemoryAttemptStore) Incr(key string, now time.Time, window time.Duration) (*LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok || attempt.expired(now, window) {
+		// 新增的记录数超过上次清理后剩下的记录数时才整体清理一次, 每次新增分摊到的清理开销是常数
+		s.inserts++
+		if s.inserts >= attemptSweepMinInserts && s.inserts >= s.lastSize {
+			s.sweep(now, window)
+			s.inserts = 0
+			s.lastSize = len(s.attempts)
+		}
+		attempt = &LoginAttempt{}
+		s.attempts[key] = attempt
+	}
+	attempt.Failures++
+	attempt.LastFailure = now
+	copied := *attempt
+	return &copied, nil
+}
+
+func (s *MemoryAttemptStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok {
+		attempt = &LoginAttempt{LastFailure: time.Now()}
+		s.attempts[key] = attempt
+	}
+	attempt.Failures = 0
+	attempt.LockedUntil = until
+	return nil
+}
+
+func (s *MemoryAttemptStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
+
+// sweep 清理过期记录, 防止被大量不同用户名撑爆内存, 调用方需持有锁
+func (s *MemoryAttemptStore) sweep(now time.Time, window time.Duration) {
+	for key, attempt := range s.attempts {
+		if attempt.expired(now, window) {
+			delete(s.attempts, key)
+		}
+	}
+}
+
+var loginAttemptStore LoginAttemptStore = NewMemoryAttemptStore()
+
+// SetLoginAttemptStore 替换登录失败计数的存储实现
+func SetLoginAttemptStore(store LoginAttemptStore) {
+	loginAttemptStore = store
+}
+
+func userAttemptKey(userName string) string {
+	return "user:" + userName
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// backoff 失败 failures 次后需要等待的时长
+func backoff(failures int, loginConfig *model.LoginConfig) time.Duration {
+	if failures <= 0 || loginConfig.BaseDelay <= 0 {
+		return 0
+	}
+	delay := loginConfig.BaseDelay
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if delay >= loginConfig.MaxDelay {
+			return loginConfig.MaxDelay
+		}
+	}
+	return delay
+}
+
+// checkLoginAllowed 检查用户名和IP当前是否允许尝试登录
+// 不允许时返回对应的错误码和需要等待的时长
+func checkLoginAllowed(userName, ip string, now time.Time) (model.ErrorCode, time.Duration, bool) {
+	loginConfig := config.Config.Login
+
+	checks := []struct {
+		key        string
+		lockedCode model.ErrorCode
+	}{
+		{userAttemptKey(userName), model.AccountLocked},
+		{ipAttemptKey(ip), model.LoginIPBlocked},
+	}
+	for _, check := range checks {
+		attempt, err := loginAttemptStore.Get(check.key, loginConfig.Window)
+		if err != nil {
+			// 计数存储不可用时放行, 不能因此让所有人都无法登录
+			log.GetLogger().Errorf("读取登录失败记录失败: %v", err)
+			continue
+		}
+		if attempt == nil {
+			continue
+		}
+		if now.Before(attempt.LockedUntil) {
+			return check.lockedCode, attempt.LockedUntil.Sub(now), false
+		}
+		if wait := attempt.LastFailure.Add(backoff(attempt.Failures, loginConfig)).Sub(now); wait > 0 {
+			return model.LoginTooFrequent, wait, false
+		}
+	}
+	return model.Success, 0, true
+}
+
+// recordLoginFailure 记录一次登录失败, 达到阈值时锁定用户名或IP
+func recordLoginFailure(userName, ip string, now time.Time) {
+	loginConfig := config.Config.Login
+
+	thresholds := map[string]int{
+		userAttemptKey(userName): loginConfig.MaxFailures,
+		ipAttemptKey(ip):         loginConfig.IPMaxFailures,
+	}
+	for key, threshold := range thresholds {
+		attempt, err := loginAttemptStore.Incr(key, now, loginConfig.Window)
+		if err != nil {
+			log.GetLogger().Errorf("记录登录失败失败: %v", err)
+			continue
+		}
+		if threshold > 0 && attempt.Failures >= threshold {
+			log.GetLogger().Warnf("登录失败次数过多, 锁定 %s", key)
+			if err = loginAttemptStore.Lock(key, now.Add(loginConfig.LockDuration)); err != nil {
+				log.GetLogger().Errorf("锁定登录失败: %v", err)
+			}
+		}
+	}
+}
+
+// resetLoginFailures 登录成功后清除用户名的失败记录
+// IP的记录不清除, 避免攻击者用自己的账号登录来洗掉撞库的计数
+func resetLoginFailures(userName string) {
+	if err := loginAttemptStore.Reset(userAttemptKey(userName)); err != nil {
+		log.GetLogger().Errorf("清除登录失败记录失败: %v", err)
+	}
+}
+
+// VerifyCurrentPassword 修改密码等敏感操作前校验账号的当前密码, 失败同样计入登录失败次数
+// 返回 false 时 code 为限流错误码(此时 wait 为需要等待的时长)或 WrongPassword
+func VerifyCurrentPassword(c *gin.Context, userDO *model.UserDO, password string) (code model.ErrorCode, wait time.Duration, ok bool) {
+	clientIP := c.ClientIP()
+	if code, wait, allowed := checkLoginAllowed(userDO.Name, clientIP, time.Now()); !allowed {
+		RecordAudit(c, model.AuditLoginFailure, userDO.Id, userDO.Name, "throttled")
+		return code, wait, false
+	}
+	if ok, _ := CheckPassword(userDO.Password, password); !ok {
+		recordLoginFailure(userDO.Name, clientIP, time.Now())
+		RecordAudit(c, model.AuditLoginFailure, userDO.Id, userDO.Name, "wrong current password")
+		return model.WrongPassword, 0, false
+	}
+	resetLoginFailures(userDO.Name)
+	return model.Success, 0, true
+}
@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"yujian-backend/pkg/config"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/model"
+)
+
+// useLoginConfig 在测试期间替换登录限制的配置, 并换成新的内存计数存储
+func useLoginConfig(t *testing.T, loginConfig *model.LoginConfig) {
+	t.Helper()
+	previousConfig, previousStore := config.Config.Login, loginAttemptStore
+	config.Config.Login = loginConfig
+	SetLoginAttemptStore(NewMemoryAttemptStore())
+	t.Cleanup(func() {
+		config.Config.Login = previousConfig
+		SetLoginAttemptStore(previousStore)
+	})
+}
+
+func TestBackoffDoubles(t *testing.T) {
+	loginConfig := &model.LoginConfig{BaseDelay: time.Second, MaxDelay: 8 * time.Second}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{10, 8 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.failures, loginConfig); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+	if got := backoff(3, &model.LoginConfig{}); got != 0 {
+		t.Errorf("backoff without base delay = %v, want 0", got)
+	}
+}
+
+func TestCheckLoginAllowedBacksOff(t *testing.T) {
+	useLoginConfig(t, &model.LoginConfig{BaseDelay: time.Second, MaxDelay: time.Minute, Window: time.Hour})
+	now := time.Now()
+
+	recordLoginFailure("alice", "10.0.0.1", now)
+	recordLoginFailure("alice", "10.0.0.1", now)
+	code, wait, allowed := checkLoginAllowed("alice", "10.0.0.2", now)
+	if allowed || code != model.LoginTooFrequent || wait != 2*time.Second {
+		t.Fatalf("after 2 failures: code = %d, wait = %v, allowed = %v", code, wait, allowed)
+	}
+	if _, _, allowed = checkLoginAllowed("alice", "10.0.0.2", now.Add(2*time.Second+time.Millisecond)); !allowed {
+		t.Fatal("still throttled after the backoff")
+	}
+}
+
+func TestLoginLockoutAfterMaxFailures(t *testing.T) {
+	useLoginConfig(t, &model.LoginConfig{
+		MaxFailures:   3,
+		IPMaxFailures: 5,
+		LockDuration:  time.Minute,
+		Window:        time.Hour,
+	})
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		recordLoginFailure("alice", "10.0.0.1", now)
+	}
+	if code, _, allowed := checkLoginAllowed("alice", "10.0.0.1", now); !allowed {
+		t.Fatalf("locked before reaching the threshold, code = %d", code)
+	}
+	recordLoginFailure("alice", "10.0.0.1", now)
+	code, wait, allowed := checkLoginAllowed("alice", "10.0.0.2", now)
+	if allowed || code != model.AccountLocked || wait != time.Minute {
+		t.Fatalf("after 3 failures: code = %d, wait = %v, allowed = %v", code, wait, allowed)
+	}
+	if _, _, allowed = checkLoginAllowed("alice", "10.0.0.2", now.Add(time.Minute+time.Second)); !allowed {
+		t.Fatal("still locked after the lock duration")
+	}
+
+	// 同一IP换着用户名尝试, 达到IP的阈值后封禁该IP
+	for i := 0; i < 2; i++ {
+		recordLoginFailure("user"+strconv.Itoa(i), "10.0.0.1", now)
+	}
+	code, _, allowed = checkLoginAllowed("bob", "10.0.0.1", now)
+	if allowed || code != model.LoginIPBlocked {
+		t.Fatalf("after 5 failures from one IP: code = %d, allowed = %v", code, allowed)
+	}
+}
+
+func TestMemoryAttemptStoreWindowExpiry(t *testing.T) {
+	store := NewMemoryAttemptStore()
+	window := time.Minute
+	start := time.Now().Add(-2 * window)
+
+	if attempt, _ := store.Incr("k", start, window); attempt.Failures != 1 {
+		t.Fatalf("failures = %d, want 1", attempt.Failures)
+	}
+	if attempt, _ := store.Incr("k", start.Add(time.Second), window); attempt.Failures != 2 {
+		t.Fatalf("failures = %d, want 2", attempt.Failures)
+	}
+	// 超出统计窗口后读不到, 再失败时从头计数
+	if attempt, _ := store.Get("k", window); attempt != nil {
+		t.Fatalf("attempt outside the window = %+v, want nil", attempt)
+	}
+	if attempt, _ := store.Incr("k", time.Now(), window); attempt.Failures != 1 {
+		t.Fatalf("failures after the window = %d, want 1", attempt.Failures)
+	}
+}
+
+func TestMemoryAttemptStoreSweep(t *testing.T) {
+	store := NewMemoryAttemptStore()
+	window := time.Minute
+	old := time.Now().Add(-2 * window)
+	now := time.Now()
+
+	for i := 0; i < attemptSweepMinInserts; i++ {
+		if _, err := store.Incr("old:"+strconv.Itoa(i), old, window); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < attemptSweepMinInserts; i++ {
+		attempt, err := store.Incr("new:"+strconv.Itoa(i), now, window)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 触发清理的那次新增不能把自己刚建的记录清掉
+		if attempt.Failures != 1 {
+			t.Fatalf("failures = %d, want 1", attempt.Failures)
+		}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for i := 0; i < attemptSweepMinInserts; i++ {
+		if _, ok := store.attempts["old:"+strconv.Itoa(i)]; ok {
+			t.Fatalf("expired attempt old:%d was not swept", i)
+		}
+		if _, ok := store.attempts["new:"+strconv.Itoa(i)]; !ok {
+			t.Fatalf("live attempt new:%d was swept", i)
+		}
+	}
+}
+
+func TestUserLoginDoesNotRevealUnknownUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useLoginConfig(t, &model.LoginConfig{Window: time.Hour})
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.UseDB(database)
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.GetUserRepository().CreateUser(&model.UserDTO{Name: "alice", Password: hash}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.POST("/login", UserLogin())
+	login := func(userName, password string) *model.LoginResponseDTO {
+		body, _ := json.Marshal(model.LoginRequestDTO{UserName: userName, Password: password})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body)))
+		var resp model.LoginResponseDTO
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return &resp
+	}
+
+	if resp := login("alice", "wrong"); resp.Code != model.InvalidCredentials {
+		t.Fatalf("wrong password: code = %d, want %d", resp.Code, model.InvalidCredentials)
+	}
+	if resp := login("nobody", "wrong"); resp.Code != model.InvalidCredentials {
+		t.Fatalf("unknown user: code = %d, want %d", resp.Code, model.InvalidCredentials)
+	}
+}
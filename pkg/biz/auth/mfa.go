@@ -0,0 +1,435 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"gorm.io/gorm"
+
+	"yujian-backend/pkg/config"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+)
+
+// purposeMFAPending 两步登录中间令牌的用途
+const purposeMFAPending = "mfa_pending"
+
+const (
+	totpPeriod        = 30 // TOTP 时间步长, 单位秒
+	totpSkew          = 1  // 允许前后各偏差一个时间步
+	recoveryCodeBytes = 5  // 恢复码随机字节数, base32 编码后为 8 个字符
+)
+
+var totpOpts = totp.ValidateOpts{
+	Period:    totpPeriod,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+// validateTOTP 校验TOTP验证码, 通过时返回匹配的时间步
+func validateTOTP(secret, code string, now time.Time) (int64, bool) {
+	if len(code) != int(otp.DigitsSix) {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0), totpOpts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// normalizeRecoveryCode 去掉恢复码中的分隔符和空白, 统一为小写
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// hashRecoveryCode 计算恢复码的哈希, 数据库只保存哈希
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateRecoveryCodes 生成一组恢复码, 返回明文和对应的哈希
+func generateRecoveryCodes(n int) ([]string, []string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, n)
+	hashes := make([]string, n)
+	buf := make([]byte, recoveryCodeBytes)
+	for i := 0; i < n; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(buf))
+		codes[i] = code[:4] + "-" + code[4:]
+		hashes[i] = hashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
+
+// verifyMFACode 校验已启用二次验证用户提交的验证码, 六位数字按TOTP校验, 其余按恢复码校验
+func verifyMFACode(mfa *model.UserMFADO, code string) (bool, error) {
+	mfaRepository := db.GetMFARepository()
+
+	code = strings.TrimSpace(code)
+	if _, err := strconv.Atoi(code); err == nil && len(code) == int(otp.DigitsSix) {
+		step, ok := validateTOTP(mfa.Secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		// 同一个时间步只能使用一次, 防止验证码被截获后重放
+		return mfaRepository.AdvanceStep(mfa.UserId, step)
+	}
+	return mfaRepository.UseRecoveryCode(mfa.UserId, hashRecoveryCode(code))
+}
+
+// getEnabledMFA 获取用户已启用的二次验证设置, 未启用时返回 nil
+func getEnabledMFA(userId int64) (*model.UserMFADO, error) {
+	mfa, err := db.GetMFARepository().GetMFA(userId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !mfa.Enabled {
+		return nil, nil
+	}
+	return mfa, nil
+}
+
+// respondLogin 第一步认证通过后的响应: 启用了二次验证时只返回中间令牌, 否则直接签发令牌对
+// method 为登录方式, 记录在审计事件中
+func respondLogin(c *gin.Context, user *model.UserDTO, method string) {
+	mfa, err := getEnabledMFA(user.Id)
+	if err != nil {
+		log.GetLogger().Errorf("查询二次验证设置失败: %v", err)
+		c.JSON(http.StatusInternalServerError, model.LoginResponseDTO{
+			BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+		})
+		return
+	}
+	if mfa != nil {
+		// 中间令牌绑定密码摘要, 改密码后未完成的两步登录随之作废
+		credential, err := db.GetUserRepository().GetUserCredentialById(user.Id)
+		var mfaToken string
+		if err == nil {
+			mfaToken, err = generateActionToken(purposeMFAPending, actionClaims{
+				UserId:      user.Id,
+				Fingerprint: passwordFingerprint(credential.Password),
+			}, config.Config.MFA.PendingExpire)
+		}
+		if err != nil {
+			log.GetLogger().Errorf("签发二次验证令牌失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.LoginResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.LoginResponseDTO{
+			BaseResp:    model.BaseResp{Code: model.MFARequired, ErrMsg: "需要二次验证"},
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		})
+		return
+	}
+
+	token, refreshToken, err := IssueTokenPair(user)
+	if err != nil {
+		log.GetLogger().Errorf("签发令牌失败: %v", err)
+		c.JSON(http.StatusInternalServerError, model.LoginResponseDTO{
+			BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+		})
+		return
+	}
+	RecordAudit(c, model.AuditLoginSuccess, user.Id, user.Name, method)
+	c.JSON(http.StatusOK, model.LoginResponseDTO{
+		Token:        token,
+		RefreshToken: refreshToken,
+		User:         *user,
+	})
+}
+
+// EnrollTOTP 登记TOTP, 返回密钥和 otpauth 链接, 需要再调用确认接口才会启用
+func EnrollTOTP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mfaRepository := db.GetMFARepository()
+
+		currentUser, ok := GetCurrentUser(c)
+		if !ok {
+			abortUnauthorized(c)
+			return
+		}
+
+		if mfa, err := getEnabledMFA(currentUser.Id); err != nil {
+			log.GetLogger().Errorf("查询二次验证设置失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		} else if mfa != nil {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.MFAAlreadyEnabled, ErrMsg: "已经启用二次验证"})
+			return
+		}
+
+		key, err := totp.Generate(totp.GenerateOpts{
+			Issuer:      config.Config.MFA.Issuer,
+			AccountName: currentUser.Name,
+			Period:      totpPeriod,
+			Digits:      otp.DigitsSix,
+			Algorithm:   otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			log.GetLogger().Errorf("生成TOTP密钥失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+
+		// 重复登记会覆盖之前未确认的密钥
+		if err = mfaRepository.SaveMFA(&model.UserMFADO{
+			UserId:     currentUser.Id,
+			Secret:     key.Secret(),
+			CreateTime: time.Now(),
+		}); err != nil {
+			log.GetLogger().Errorf("保存TOTP密钥失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+
+		c.JSON(http.StatusOK, model.TOTPEnrollResponseDTO{
+			Secret: key.Secret(),
+			URI:    key.URL(),
+		})
+	}
+}
+
+// ConfirmTOTP 用验证器生成的验证码确认登记, 启用二次验证并返回恢复码
+func ConfirmTOTP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mfaRepository := db.GetMFARepository()
+
+		var req model.TOTPCodeRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Error: errors.New("invalid request body")})
+			return
+		}
+
+		currentUser, ok := GetCurrentUser(c)
+		if !ok {
+			abortUnauthorized(c)
+			return
+		}
+		if !checkMFAAttemptAllowed(c, currentUser) {
+			return
+		}
+
+		mfa, err := mfaRepository.GetMFA(currentUser.Id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.MFANotEnrolled, ErrMsg: "尚未登记二次验证"})
+			return
+		} else if err != nil {
+			log.GetLogger().Errorf("查询二次验证设置失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		if mfa.Enabled {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.MFAAlreadyEnabled, ErrMsg: "已经启用二次验证"})
+			return
+		}
+
+		step, ok := validateTOTP(mfa.Secret, strings.TrimSpace(req.Code), time.Now())
+		if !ok {
+			recordMFAFailure(c, currentUser)
+			c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.MFACodeInvalid, ErrMsg: "验证码错误"})
+			return
+		}
+		resetLoginFailures(currentUser.Name)
+
+		codes, hashes, err := generateRecoveryCodes(config.Config.MFA.RecoveryCodes)
+		if err != nil {
+			log.GetLogger().Errorf("生成恢复码失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		if err = mfaRepository.EnableMFA(currentUser.Id, step, hashes); err != nil {
+			log.GetLogger().Errorf("启用二次验证失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+
+		// 恢复码只在这里返回一次
+		c.JSON(http.StatusOK, model.TOTPConfirmResponseDTO{RecoveryCodes: codes})
+	}
+}
+
+// DisableTOTP 关闭二次验证, 需要提交当前的验证码或一个恢复码
+func DisableTOTP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.TOTPCodeRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Error: errors.New("invalid request body")})
+			return
+		}
+
+		currentUser, ok := GetCurrentUser(c)
+		if !ok {
+			abortUnauthorized(c)
+			return
+		}
+		if !checkMFAAttemptAllowed(c, currentUser) {
+			return
+		}
+
+		mfa, err := getEnabledMFA(currentUser.Id)
+		if err != nil {
+			log.GetLogger().Errorf("查询二次验证设置失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		if mfa == nil {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.MFANotEnrolled, ErrMsg: "尚未启用二次验证"})
+			return
+		}
+
+		if ok, err = verifyMFACode(mfa, req.Code); err != nil {
+			log.GetLogger().Errorf("校验二次验证码失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		} else if !ok {
+			recordMFAFailure(c, currentUser)
+			c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.MFACodeInvalid, ErrMsg: "验证码错误"})
+			return
+		}
+		resetLoginFailures(currentUser.Name)
+
+		if err = db.GetMFARepository().DeleteMFA(currentUser.Id); err != nil {
+			log.GetLogger().Errorf("关闭二次验证失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// checkMFAAttemptAllowed 已登录用户提交二次验证码前检查失败次数, 与登录共用计数防止穷举
+// 被限制时返回 429 并返回 false
+func checkMFAAttemptAllowed(c *gin.Context, currentUser *model.UserDTO) bool {
+	code, wait, allowed := checkLoginAllowed(currentUser.Name, c.ClientIP(), time.Now())
+	if allowed {
+		return true
+	}
+	RecordAudit(c, model.AuditLoginFailure, currentUser.Id, currentUser.Name, "throttled")
+	c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	c.JSON(http.StatusTooManyRequests, model.BaseResp{Code: code, ErrMsg: "尝试过于频繁, 请稍后再试"})
+	return false
+}
+
+// recordMFAFailure 记录一次二次验证码错误
+func recordMFAFailure(c *gin.Context, currentUser *model.UserDTO) {
+	recordLoginFailure(currentUser.Name, c.ClientIP(), time.Now())
+	RecordAudit(c, model.AuditLoginFailure, currentUser.Id, currentUser.Name, "wrong mfa code")
+}
+
+// LoginMFA 两步登录的第二步, 用中间令牌和验证码换取正式的令牌对
+func LoginMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.MFALoginRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil || req.MFAToken == "" || req.Code == "" {
+			c.JSON(http.StatusBadRequest, model.LoginResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid request body")},
+			})
+			return
+		}
+
+		claims, err := parseActionToken(purposeMFAPending, req.MFAToken)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, model.LoginResponseDTO{
+				BaseResp: model.BaseResp{Code: model.ActionTokenInvalid, ErrMsg: "登录已过期, 请重新登录"},
+			})
+			return
+		}
+		// 中间令牌签发后改过密码, 令牌作废
+		userDO, err := db.GetUserRepository().GetUserCredentialById(claims.UserId)
+		if err != nil || passwordFingerprint(userDO.Password) != claims.Fingerprint {
+			c.JSON(http.StatusUnauthorized, model.LoginResponseDTO{
+				BaseResp: model.BaseResp{Code: model.ActionTokenInvalid, ErrMsg: "登录已过期, 请重新登录"},
+			})
+			return
+		}
+
+		// 验证码同样计入登录失败次数, 防止穷举
+		clientIP := c.ClientIP()
+		if code, wait, allowed := checkLoginAllowed(userDO.Name, clientIP, time.Now()); !allowed {
+			RecordAudit(c, model.AuditLoginFailure, userDO.Id, userDO.Name, "throttled")
+			c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, model.LoginResponseDTO{
+				BaseResp: model.BaseResp{
+					Code:   code,
+					ErrMsg: "登录尝试过于频繁, 请稍后再试",
+				},
+			})
+			return
+		}
+
+		mfa, err := getEnabledMFA(userDO.Id)
+		if err != nil {
+			log.GetLogger().Errorf("查询二次验证设置失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.LoginResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		// 中间令牌有效期内关闭了二次验证, 直接放行
+		if mfa != nil {
+			ok, err := verifyMFACode(mfa, req.Code)
+			if err != nil {
+				log.GetLogger().Errorf("校验二次验证码失败: %v", err)
+				c.JSON(http.StatusInternalServerError, model.LoginResponseDTO{
+					BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+				})
+				return
+			}
+			if !ok {
+				recordLoginFailure(userDO.Name, clientIP, time.Now())
+				RecordAudit(c, model.AuditLoginFailure, userDO.Id, userDO.Name, "wrong mfa code")
+				c.JSON(http.StatusOK, model.LoginResponseDTO{
+					BaseResp: model.BaseResp{Code: model.MFACodeInvalid, ErrMsg: "验证码错误"},
+				})
+				return
+			}
+		}
+		resetLoginFailures(userDO.Name)
+
+		userDTO := userDO.Transfer()
+		token, refreshToken, err := IssueTokenPair(userDTO)
+		if err != nil {
+			log.GetLogger().Errorf("签发令牌失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.LoginResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		RecordAudit(c, model.AuditLoginSuccess, userDTO.Id, userDTO.Name, "mfa")
+		c.JSON(http.StatusOK, model.LoginResponseDTO{
+			Token:        token,
+			RefreshToken: refreshToken,
+			User:         *userDTO,
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+)
+
+// currentUserKey 当前用户在gin上下文中的键
+const currentUserKey = "current_user"
+
+// JWTAuth 校验 Authorization: Bearer 请求头中的令牌,并把当前用户写入上下文
+func JWTAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authenticateJWT(c) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// OptionalJWTAuth 带了有效令牌时把当前用户写入上下文, 没带或无效时按未登录继续处理
+// 用于公开接口根据登录状态返回不同内容的场景
+func OptionalJWTAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setOptionalJWTUser(c)
+		c.Next()
+	}
+}
+
+// setOptionalJWTUser 带了有效令牌时把当前用户写入上下文, 否则不做任何事
+func setOptionalJWTUser(c *gin.Context) {
+	if tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok && tokenString != "" {
+		if claims, err := ParseToken(tokenString); err == nil {
+			if currentUser, err := loadTokenUser(claims); err == nil {
+				c.Set(currentUserKey, currentUser)
+			}
+		}
+	}
+}
+
+// authenticateJWT 校验 Bearer 令牌并写入当前用户, 失败时中断请求并返回 false
+func authenticateJWT(c *gin.Context) bool {
+	tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || tokenString == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, model.BaseResp{
+			Code:   model.Unauthorized,
+			ErrMsg: "缺少令牌",
+		})
+		return false
+	}
+
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		resp := model.BaseResp{
+			Code:   model.TokenInvalid,
+			ErrMsg: "令牌无效",
+		}
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			resp.Code = model.TokenExpired
+			resp.ErrMsg = "令牌已过期"
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, resp)
+		return false
+	}
+
+	currentUser, err := loadTokenUser(claims)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, model.BaseResp{
+			Code:   model.TokenInvalid,
+			ErrMsg: "令牌无效",
+		})
+		return false
+	} else if err != nil {
+		log.GetLogger().Errorf("查询令牌对应的用户失败, userId=%d: %v", claims.UserId, err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+		return false
+	}
+	c.Set(currentUserKey, currentUser)
+	return true
+}
+
+// loadTokenUser 按令牌中的用户ID从数据库读取当前用户
+// 角色以数据库为准, 降级或注销后已签发的访问令牌不再带有原来的权限
+func loadTokenUser(claims *Claims) (*model.UserDTO, error) {
+	userDTO, err := db.GetUserRepository().GetUserById(claims.UserId)
+	if err != nil {
+		return nil, err
+	}
+	return &model.UserDTO{
+		Id:   userDTO.Id,
+		Name: userDTO.Name,
+		Role: userDTO.Role,
+	}, nil
+}
+
+// GetCurrentUser 获取经过认证的当前用户
+func GetCurrentUser(c *gin.Context) (*model.UserDTO, bool) {
+	value, exists := c.Get(currentUserKey)
+	if !exists {
+		return nil, false
+	}
+	user, ok := value.(*model.UserDTO)
+	return user, ok
+}
+
+// RequireRole 要求当前用户是指定角色之一, 需要挂在 JWTAuth 之后
+func RequireRole(roles ...model.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := GetCurrentUser(c)
+		if !ok {
+			abortUnauthorized(c)
+			return
+		}
+		for _, role := range roles {
+			if currentUser.Role == role {
+				c.Next()
+				return
+			}
+		}
+		AbortForbidden(c)
+	}
+}
+
+// RequirePermission 要求当前用户的角色拥有指定权限, 需要挂在 JWTAuth 之后
+func RequirePermission(permission model.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := GetCurrentUser(c)
+		if !ok {
+			abortUnauthorized(c)
+			return
+		}
+		if !currentUser.Role.HasPermission(permission) {
+			AbortForbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSelfOrPermission 要求路径参数 param 是当前用户自己的ID, 或者当前用户拥有指定权限
+func RequireSelfOrPermission(param string, permission model.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := GetCurrentUser(c)
+		if !ok {
+			abortUnauthorized(c)
+			return
+		}
+		if userId, err := strconv.ParseInt(c.Param(param), 10, 64); err == nil && userId == currentUser.Id {
+			c.Next()
+			return
+		}
+		if !currentUser.Role.HasPermission(permission) {
+			AbortForbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// abortUnauthorized 以401中断请求
+func abortUnauthorized(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, model.BaseResp{
+		Code:   model.Unauthorized,
+		ErrMsg: "未登录",
+	})
+}
+
+// AbortForbidden 以403中断请求
+func AbortForbidden(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusForbidden, model.BaseResp{
+		Code:   model.PermissionDenied,
+		ErrMsg: "没有权限",
+	})
+}
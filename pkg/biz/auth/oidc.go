@@ -0,0 +1,369 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+
+	"yujian-backend/pkg/config"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+const (
+	// purposeOIDCState 第三方登录状态令牌的用途
+	purposeOIDCState = "oidc_state"
+	// oidcStateCookie 保存第三方登录状态的 cookie
+	oidcStateCookie = "oidc_state"
+	// oidcStateTTL 从跳转到提供方到回调之间允许的最长时间
+	oidcStateTTL = 10 * time.Minute
+)
+
+var errUnknownProvider = errors.New("unknown oidc provider")
+
+// oidcProvider 完成服务发现的身份提供方
+type oidcProvider struct {
+	oauth2Config *oauth2.Config
+	verifier     *oidc.IDTokenVerifier
+}
+
+var (
+	oidcMu        sync.Mutex
+	oidcProviders = map[string]*oidcProvider{}
+)
+
+// getOIDCProvider 获取提供方, 第一次使用时才做服务发现
+// 服务发现是网络请求, 不持有锁进行, 一个提供方响应慢不会阻塞其他提供方的登录; 并发的首次请求各自发现, 保留先完成的结果
+func getOIDCProvider(ctx context.Context, name string) (*oidcProvider, error) {
+	oidcMu.Lock()
+	p, ok := oidcProviders[name]
+	oidcMu.Unlock()
+	if ok {
+		return p, nil
+	}
+
+	var providerConfig *model.OIDCProviderConfig
+	for _, pc := range config.Config.OIDC.Providers {
+		if pc.Name == name {
+			providerConfig = pc
+			break
+		}
+	}
+	if providerConfig == nil {
+		return nil, errUnknownProvider
+	}
+
+	provider, err := oidc.NewProvider(ctx, providerConfig.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("oidc服务发现失败: %v", err)
+	}
+	scopes := providerConfig.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+
+	p = &oidcProvider{
+		oauth2Config: &oauth2.Config{
+			ClientID:     providerConfig.ClientID,
+			ClientSecret: providerConfig.ClientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  providerConfig.RedirectURL,
+			Scopes:       scopes,
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: providerConfig.ClientID}),
+	}
+
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	if existing, ok := oidcProviders[name]; ok {
+		return existing, nil
+	}
+	oidcProviders[name] = p
+	return p, nil
+}
+
+// oidcLoginState 跳转到提供方时生成的状态, 签名后放在浏览器的 cookie 中, 回调时取出校验
+// 服务端不保存进行中的登录; state 同时出现在回调参数和发起登录的浏览器的 cookie 中才有效, 防止登录CSRF
+type oidcLoginState struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Verifier string `json:"verifier"` // PKCE code_verifier
+	Nonce    string `json:"nonce"`
+}
+
+// setOIDCStateCookie 把登录状态写入 cookie, 只在本提供方的登录和回调路径下发送
+func setOIDCStateCookie(c *gin.Context, value string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	// 提供方回调是跨站的顶层跳转, 需要 Lax 才会带上 cookie
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, value, maxAge, path.Dir(c.Request.URL.Path), "", secure, true)
+}
+
+// takeOIDCState 读取并清除 cookie 中的登录状态, 与回调参数中的 state 和提供方一致时返回
+func takeOIDCState(c *gin.Context, providerName string) (*oidcLoginState, bool) {
+	value, err := c.Cookie(oidcStateCookie)
+	if err != nil || value == "" {
+		return nil, false
+	}
+	setOIDCStateCookie(c, "", -1)
+
+	claims, err := parseActionToken(purposeOIDCState, value)
+	if err != nil || claims.OIDC == nil {
+		return nil, false
+	}
+	loginState := claims.OIDC
+	if loginState.Provider != providerName ||
+		subtle.ConstantTimeCompare([]byte(loginState.State), []byte(c.Query("state"))) != 1 {
+		return nil, false
+	}
+	return loginState, true
+}
+
+// oidcClaims ID令牌中用到的字段
+type oidcClaims struct {
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	Name              string `json:"name"`
+}
+
+// OIDCLogin 跳转到第三方提供方的授权页面
+func OIDCLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		providerName := c.Param("provider")
+		p, err := getOIDCProvider(c.Request.Context(), providerName)
+		if err != nil {
+			abortOIDCProviderError(c, err)
+			return
+		}
+
+		state, err := randomToken(16)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		nonce, err := randomToken(16)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		verifier := oauth2.GenerateVerifier()
+
+		value, err := generateActionToken(purposeOIDCState, actionClaims{OIDC: &oidcLoginState{
+			Provider: providerName,
+			State:    state,
+			Verifier: verifier,
+			Nonce:    nonce,
+		}}, oidcStateTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		setOIDCStateCookie(c, value, int(oidcStateTTL.Seconds()))
+
+		authURL := p.oauth2Config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// OIDCCallback 处理提供方的回调, 校验身份后登录, 首次登录时自动创建账号
+func OIDCCallback() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		providerName := c.Param("provider")
+
+		if errParam := c.Query("error"); errParam != "" {
+			c.JSON(http.StatusBadRequest, model.LoginResponseDTO{
+				BaseResp: model.BaseResp{Code: model.OAuthFailed, ErrMsg: "第三方登录被拒绝: " + errParam},
+			})
+			return
+		}
+
+		loginState, ok := takeOIDCState(c, providerName)
+		if !ok {
+			c.JSON(http.StatusBadRequest, model.LoginResponseDTO{
+				BaseResp: model.BaseResp{Code: model.OAuthStateInvalid, ErrMsg: "登录已过期, 请重新发起"},
+			})
+			return
+		}
+
+		p, err := getOIDCProvider(ctx, providerName)
+		if err != nil {
+			abortOIDCProviderError(c, err)
+			return
+		}
+
+		oauth2Token, err := p.oauth2Config.Exchange(ctx, c.Query("code"), oauth2.VerifierOption(loginState.Verifier))
+		if err != nil {
+			log.GetLogger().Warnf("oidc换取令牌失败, provider=%s: %v", providerName, err)
+			c.JSON(http.StatusBadGateway, model.LoginResponseDTO{
+				BaseResp: model.BaseResp{Code: model.OAuthFailed, ErrMsg: "第三方登录失败"},
+			})
+			return
+		}
+		rawIDToken, ok := oauth2Token.Extra("id_token").(string)
+		if !ok {
+			c.JSON(http.StatusBadGateway, model.LoginResponseDTO{
+				BaseResp: model.BaseResp{Code: model.OAuthFailed, ErrMsg: "第三方登录失败"},
+			})
+			return
+		}
+		idToken, err := p.verifier.Verify(ctx, rawIDToken)
+		if err != nil || idToken.Nonce != loginState.Nonce {
+			log.GetLogger().Warnf("oidc ID令牌校验失败, provider=%s: %v", providerName, err)
+			c.JSON(http.StatusUnauthorized, model.LoginResponseDTO{
+				BaseResp: model.BaseResp{Code: model.OAuthFailed, ErrMsg: "第三方登录失败"},
+			})
+			return
+		}
+		var claims oidcClaims
+		if err = idToken.Claims(&claims); err != nil {
+			c.JSON(http.StatusBadGateway, model.LoginResponseDTO{
+				BaseResp: model.BaseResp{Code: model.OAuthFailed, ErrMsg: "第三方登录失败"},
+			})
+			return
+		}
+
+		userDTO, err := findOrCreateOIDCUser(c, providerName, idToken.Subject, &claims)
+		if err != nil {
+			log.GetLogger().Errorf("第三方登录创建用户失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.LoginResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+
+		// 第三方登录同样要经过二次验证
+		respondLogin(c, userDTO, "oidc:"+providerName)
+	}
+}
+
+// abortOIDCProviderError 提供方不存在或服务发现失败时返回错误
+func abortOIDCProviderError(c *gin.Context, err error) {
+	if errors.Is(err, errUnknownProvider) {
+		c.JSON(http.StatusNotFound, model.LoginResponseDTO{
+			BaseResp: model.BaseResp{Code: model.OAuthProviderUnknown, ErrMsg: "不支持的登录方式"},
+		})
+		return
+	}
+	log.GetLogger().Errorf("获取oidc提供方失败: %v", err)
+	c.JSON(http.StatusBadGateway, model.LoginResponseDTO{
+		BaseResp: model.BaseResp{Code: model.OAuthFailed, ErrMsg: "第三方登录暂不可用"},
+	})
+}
+
+// findOrCreateOIDCUser 根据第三方身份找到绑定的用户, 没有绑定时创建新用户
+func findOrCreateOIDCUser(c *gin.Context, provider, subject string, claims *oidcClaims) (*model.UserDTO, error) {
+	identityRepository := db.GetIdentityRepository()
+
+	identity, err := identityRepository.GetIdentity(provider, subject)
+	if err == nil {
+		return db.GetUserRepository().GetUserById(identity.UserId)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	name, err := availableUserName(claims, provider)
+	if err != nil {
+		return nil, err
+	}
+	userDO := &model.UserDO{
+		Name:        name,
+		Role:        model.RoleUser,
+		DisplayName: utils.Truncate(claims.Name, 32),
+	}
+	// 只有提供方验证过且本站未被占用的邮箱才带过来, 不会自动合并到已有账号
+	if claims.EmailVerified {
+		if email, code, err := checkEmailAvailable(claims.Email, 0); err == nil && code == model.Success {
+			userDO.Email = &email
+			userDO.EmailVerified = true
+		}
+	}
+
+	identity = &model.UserIdentityDO{
+		Provider:   provider,
+		Subject:    subject,
+		Email:      claims.Email,
+		CreateTime: time.Now(),
+	}
+	err = identityRepository.CreateUserWithIdentity(userDO, identity)
+	if err != nil {
+		// 并发回调已经抢先创建了绑定
+		if existing, getErr := identityRepository.GetIdentity(provider, subject); getErr == nil {
+			return db.GetUserRepository().GetUserById(existing.UserId)
+		}
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// 用户名或邮箱在检查之后被其他账号占用, 重新选择用户名、重新检查邮箱后再创建一次
+		if userDO.Name, err = availableUserName(claims, provider); err != nil {
+			return nil, err
+		}
+		if userDO.Email != nil {
+			if _, code, checkErr := checkEmailAvailable(*userDO.Email, 0); checkErr != nil || code != model.Success {
+				userDO.Email = nil
+				userDO.EmailVerified = false
+			}
+		}
+		userDO.Id = 0
+		identity.Id = 0
+		err = identityRepository.CreateUserWithIdentity(userDO, identity)
+	}
+	if err != nil {
+		return nil, err
+	}
+	RecordAudit(c, model.AuditRegister, userDO.Id, userDO.Name, "oidc:"+provider)
+	return userDO.Transfer(), nil
+}
+
+var userNameInvalidChars = regexp.MustCompile(`[^\p{L}\p{N}_.-]+`)
+
+// availableUserName 根据第三方资料生成一个未被占用的用户名
+func availableUserName(claims *oidcClaims, provider string) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	if base == "" {
+		base = claims.Name
+	}
+	base = userNameInvalidChars.ReplaceAllString(base, "")
+	if base == "" {
+		base = provider + "_user"
+	}
+	// 给后面追加的序号或随机后缀留出长度
+	base = utils.Truncate(base, model.MaxUserNameLen-16)
+
+	userRepository := db.GetUserRepository()
+	candidate := base
+	for i := 2; i <= 10; i++ {
+		_, err := userRepository.GetUserByName(candidate)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s_%d", base, i)
+	}
+
+	suffix, err := randomToken(4)
+	if err != nil {
+		return "", err
+	}
+	return base + "_" + suffix, nil
+}
@@ -0,0 +1,369 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"yujian-backend/pkg/config"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/model"
+)
+
+const (
+	mockClientID = "yujian-test"
+	mockKeyID    = "mock-key"
+)
+
+// mockIdentityProvider 测试用的身份提供方, 提供服务发现、JWKS 和令牌接口
+// 授权页面不经过浏览器, 由测试直接把跳转中的 code_challenge 和 nonce 交给它
+type mockIdentityProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu        sync.Mutex
+	challenge string // 授权请求中的 PKCE code_challenge, 换取令牌时校验 code_verifier
+	nonce     string // 写入ID令牌的 nonce
+	subject   string
+	username  string
+}
+
+func newMockIdentityProvider(t *testing.T) *mockIdentityProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdentityProvider{key: key, subject: "subject-1", username: "alice"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"issuer":                                idp.server.URL,
+			"authorization_endpoint":                idp.server.URL + "/authorize",
+			"token_endpoint":                        idp.server.URL + "/token",
+			"jwks_uri":                              idp.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": mockKeyID,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *mockIdentityProvider) token(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_request"})
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != idp.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                idp.server.URL,
+		"sub":                idp.subject,
+		"aud":                mockClientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Hour).Unix(),
+		"nonce":              idp.nonce,
+		"preferred_username": idp.username,
+		"email":              idp.username + "@example.com",
+		"email_verified":     true,
+	})
+	idToken.Header["kid"] = mockKeyID
+	signed, err := idToken.SignedString(idp.key)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// newOIDCTestRouter 用临时的 sqlite 数据库和指向 idp 的提供方配置搭建登录路由
+func newOIDCTestRouter(t *testing.T, idp *mockIdentityProvider) (*gin.Engine, *gorm.DB) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.UseDB(database)
+
+	config.Config.JWT = &model.JWTConfig{
+		Algorithm:     "HS256",
+		Secret:        "test-secret-at-least-32-bytes-long",
+		Issuer:        "yujian-test",
+		Expire:        time.Hour,
+		RefreshExpire: 24 * time.Hour,
+	}
+	if err = InitJWT(config.Config.JWT); err != nil {
+		t.Fatal(err)
+	}
+	config.Config.OIDC = &model.OIDCConfig{Providers: []*model.OIDCProviderConfig{{
+		Name:         "mock",
+		Issuer:       idp.server.URL,
+		ClientID:     mockClientID,
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/oauth/mock/callback",
+	}}}
+	oidcMu.Lock()
+	oidcProviders = map[string]*oidcProvider{}
+	oidcMu.Unlock()
+
+	r := gin.New()
+	r.GET("/oauth/:provider/login", OIDCLogin())
+	r.GET("/oauth/:provider/callback", OIDCCallback())
+	return r, database
+}
+
+// authorize 发起登录并模拟用户在提供方完成授权, 返回回调要带的 state 和浏览器保存的状态 cookie
+func authorize(t *testing.T, r *gin.Engine, idp *mockIdentityProvider) (string, *http.Cookie) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth/mock/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("login status = %d, body = %s", w.Code, w.Body.String())
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(location.String(), idp.server.URL+"/authorize") {
+		t.Fatalf("redirected to %s", location)
+	}
+	query := location.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization request without PKCE: %s", location.RawQuery)
+	}
+	if query.Get("state") == "" || query.Get("nonce") == "" {
+		t.Fatalf("authorization request without state or nonce: %s", location.RawQuery)
+	}
+	cookie := stateCookie(w)
+	if cookie == nil || cookie.Value == "" || !cookie.HttpOnly {
+		t.Fatalf("login without an HttpOnly state cookie: %v", w.Header().Values("Set-Cookie"))
+	}
+
+	idp.mu.Lock()
+	idp.challenge = query.Get("code_challenge")
+	idp.nonce = query.Get("nonce")
+	idp.mu.Unlock()
+	return query.Get("state"), cookie
+}
+
+// stateCookie 取出响应中设置的状态 cookie
+func stateCookie(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range (&http.Response{Header: w.Header()}).Cookies() {
+		if cookie.Name == oidcStateCookie {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// callback 模拟提供方跳转回来, cookie 为 nil 时表示浏览器没有状态 cookie
+func callback(r *gin.Engine, state string, cookie *http.Cookie) (*httptest.ResponseRecorder, *model.LoginResponseDTO) {
+	w := httptest.NewRecorder()
+	target := "/oauth/mock/callback?code=auth-code&state=" + url.QueryEscape(state)
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	r.ServeHTTP(w, req)
+	var resp model.LoginResponseDTO
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	return w, &resp
+}
+
+func countUsers(t *testing.T, database *gorm.DB) int64 {
+	t.Helper()
+	var count int64
+	if err := database.Model(&model.UserDO{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestOIDCLoginCreatesUserOnFirstLogin(t *testing.T) {
+	idp := newMockIdentityProvider(t)
+	r, database := newOIDCTestRouter(t, idp)
+
+	state, cookie := authorize(t, r, idp)
+	w, resp := callback(r, state, cookie)
+	if w.Code != http.StatusOK || resp.Token == "" || resp.RefreshToken == "" {
+		t.Fatalf("callback status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if resp.User.Name != "alice" {
+		t.Fatalf("user name = %q, want alice", resp.User.Name)
+	}
+	identity, err := db.GetIdentityRepository().GetIdentity("mock", "subject-1")
+	if err != nil || identity.UserId != resp.User.Id {
+		t.Fatalf("identity = %+v, err = %v", identity, err)
+	}
+	created, err := db.GetUserRepository().GetUserById(resp.User.Id)
+	if err != nil || created.Email != "alice@example.com" || !created.EmailVerified {
+		t.Fatalf("created user = %+v, err = %v", created, err)
+	}
+
+	// 再次登录使用已绑定的账号, 不会重复创建
+	state, cookie = authorize(t, r, idp)
+	w, again := callback(r, state, cookie)
+	if w.Code != http.StatusOK || again.User.Id != resp.User.Id {
+		t.Fatalf("second login status = %d, user = %d, want %d", w.Code, again.User.Id, resp.User.Id)
+	}
+	if n := countUsers(t, database); n != 1 {
+		t.Fatalf("users = %d, want 1", n)
+	}
+}
+
+func TestOIDCCallbackRejectsReusedState(t *testing.T) {
+	idp := newMockIdentityProvider(t)
+	r, _ := newOIDCTestRouter(t, idp)
+
+	state, cookie := authorize(t, r, idp)
+	w, _ := callback(r, state, cookie)
+	if w.Code != http.StatusOK {
+		t.Fatalf("first callback status = %d, body = %s", w.Code, w.Body.String())
+	}
+	// 回调之后浏览器中的状态 cookie 被清除
+	cleared := stateCookie(w)
+	if cleared == nil || cleared.MaxAge >= 0 {
+		t.Fatalf("state cookie not cleared: %v", w.Header().Values("Set-Cookie"))
+	}
+	w, resp := callback(r, state, nil)
+	if w.Code != http.StatusBadRequest || resp.Code != model.OAuthStateInvalid {
+		t.Fatalf("reused state: status = %d, code = %d", w.Code, resp.Code)
+	}
+
+	_, cookie = authorize(t, r, idp)
+	w, resp = callback(r, "unknown-state", cookie)
+	if w.Code != http.StatusBadRequest || resp.Code != model.OAuthStateInvalid {
+		t.Fatalf("unknown state: status = %d, code = %d", w.Code, resp.Code)
+	}
+}
+
+func TestOIDCCallbackRejectsStateFromAnotherBrowser(t *testing.T) {
+	idp := newMockIdentityProvider(t)
+	r, database := newOIDCTestRouter(t, idp)
+
+	// 攻击者发起登录拿到 state, 诱导受害者的浏览器带着它访问回调; 受害者的浏览器里没有对应的 cookie
+	attackerState, _ := authorize(t, r, idp)
+	w, resp := callback(r, attackerState, nil)
+	if w.Code != http.StatusBadRequest || resp.Code != model.OAuthStateInvalid {
+		t.Fatalf("without cookie: status = %d, code = %d", w.Code, resp.Code)
+	}
+
+	// 受害者自己也在登录时, cookie 中的 state 与攻击者的不一致
+	_, victimCookie := authorize(t, r, idp)
+	w, resp = callback(r, attackerState, victimCookie)
+	if w.Code != http.StatusBadRequest || resp.Code != model.OAuthStateInvalid {
+		t.Fatalf("cookie of another login: status = %d, code = %d", w.Code, resp.Code)
+	}
+
+	// 篡改过的 cookie 签名校验失败
+	state, cookie := authorize(t, r, idp)
+	cookie.Value += "x"
+	w, resp = callback(r, state, cookie)
+	if w.Code != http.StatusBadRequest || resp.Code != model.OAuthStateInvalid {
+		t.Fatalf("tampered cookie: status = %d, code = %d", w.Code, resp.Code)
+	}
+	if n := countUsers(t, database); n != 0 {
+		t.Fatalf("users = %d, want 0", n)
+	}
+}
+
+func TestOIDCCallbackRejectsNonceMismatch(t *testing.T) {
+	idp := newMockIdentityProvider(t)
+	r, database := newOIDCTestRouter(t, idp)
+
+	state, cookie := authorize(t, r, idp)
+	idp.mu.Lock()
+	idp.nonce = "another-nonce"
+	idp.mu.Unlock()
+
+	w, resp := callback(r, state, cookie)
+	if w.Code != http.StatusUnauthorized || resp.Code != model.OAuthFailed || resp.Token != "" {
+		t.Fatalf("status = %d, code = %d", w.Code, resp.Code)
+	}
+	if n := countUsers(t, database); n != 0 {
+		t.Fatalf("users = %d, want 0", n)
+	}
+}
+
+func TestOIDCCallbackRejectsWrongPKCEVerifier(t *testing.T) {
+	idp := newMockIdentityProvider(t)
+	r, database := newOIDCTestRouter(t, idp)
+
+	state, cookie := authorize(t, r, idp)
+	// 提供方记录的 code_challenge 与这次登录的 code_verifier 不匹配, 例如授权码被另一次登录截获后使用
+	idp.mu.Lock()
+	idp.challenge = "mismatched-challenge"
+	idp.mu.Unlock()
+
+	w, resp := callback(r, state, cookie)
+	if w.Code != http.StatusBadGateway || resp.Code != model.OAuthFailed || resp.Token != "" {
+		t.Fatalf("status = %d, code = %d", w.Code, resp.Code)
+	}
+	if n := countUsers(t, database); n != 0 {
+		t.Fatalf("users = %d, want 0", n)
+	}
+}
+
+func TestOIDCLoginUnknownProvider(t *testing.T) {
+	idp := newMockIdentityProvider(t)
+	r, _ := newOIDCTestRouter(t, idp)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth/other/login", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"yujian-backend/pkg/config"
+)
+
+// bcryptCost 读取配置中的bcrypt代价,未配置时使用默认值
+func bcryptCost() int {
+	cost := config.Config.Password.BcryptCost
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
+
+// ErrPasswordTooLong 密码超过 bcrypt 支持的72字节, 调用方应作为请求参数错误处理
+var ErrPasswordTooLong = bcrypt.ErrPasswordTooLong
+
+// HashPassword 对明文密码做bcrypt哈希
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost())
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// dummyPasswordHash 用户不存在或没有设置密码时用来比对的哈希, 让这类登录的耗时与密码错误时一致
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := HashPassword("yujian-dummy-password")
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// CheckPassword 校验密码是否匹配,needRehash 表示存储的值需要重新哈希
+// 历史数据中的明文密码也能通过校验,此时 needRehash 为 true
+// 没有设置密码的账号(例如第三方登录创建的账号)不能用密码登录
+func CheckPassword(stored, password string) (ok bool, needRehash bool) {
+	if stored == "" {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(stored))
+	if err != nil {
+		// 不是bcrypt哈希,按明文做常量时间比较
+		ok = subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return ok, ok
+	}
+	if bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) != nil {
+		return false, false
+	}
+	return true, cost != bcryptCost()
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/model"
+)
+
+// AuthorizeOwner 校验当前用户能否操作作者为 authorId 的资源
+// 当前用户是作者, 或者角色拥有 overrides 中的任一权限时放行;
+// 否则以403中断请求并返回 false
+func AuthorizeOwner(c *gin.Context, authorId int64, overrides ...model.Permission) bool {
+	currentUser, ok := GetCurrentUser(c)
+	if !ok {
+		abortUnauthorized(c)
+		return false
+	}
+	if currentUser.Id == authorId {
+		return true
+	}
+	for _, permission := range overrides {
+		if currentUser.Role.HasPermission(permission) {
+			return true
+		}
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, model.BaseResp{
+		Code:   model.NotResourceOwner,
+		ErrMsg: "只能操作自己发布的内容",
+	})
+	return false
+}
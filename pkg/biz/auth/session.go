@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+// refreshTokenBytes 刷新令牌的随机字节数
+const refreshTokenBytes = 32
+
+// hashRefreshToken 计算刷新令牌的哈希, 数据库只保存哈希
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomToken 生成 n 个随机字节的 base64url 字符串
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// newSession 生成一个随机刷新令牌以及对应的待保存会话
+func newSession(userId int64, familyId string) (string, *model.SessionDO, error) {
+	token, err := randomToken(refreshTokenBytes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	session := &model.SessionDO{
+		UserId:     userId,
+		FamilyId:   familyId,
+		TokenHash:  hashRefreshToken(token),
+		ExpiresAt:  now.Add(jwtConfig.RefreshExpire),
+		CreateTime: now,
+	}
+	return token, session, nil
+}
+
+// IssueTokenPair 签发访问令牌, 并开启一条新的会话链签发刷新令牌
+func IssueTokenPair(user *model.UserDTO) (accessToken string, refreshToken string, err error) {
+	if accessToken, err = GenerateToken(user); err != nil {
+		return "", "", err
+	}
+
+	refreshToken, session, err := newSession(user.Id, utils.GenerateUUID())
+	if err != nil {
+		return "", "", err
+	}
+	if err = db.GetSessionRepository().CreateSession(session); err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+// RefreshToken 用刷新令牌换取新的令牌对, 旧的刷新令牌随即失效
+func RefreshToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionRepository := db.GetSessionRepository()
+
+		var req model.RefreshTokenRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+			c.JSON(http.StatusBadRequest, model.RefreshTokenResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid request body")},
+			})
+			return
+		}
+
+		invalidResp := model.RefreshTokenResponseDTO{
+			BaseResp: model.BaseResp{
+				Code:   model.RefreshTokenInvalid,
+				ErrMsg: "刷新令牌无效",
+			},
+		}
+
+		session, err := sessionRepository.GetSessionByTokenHash(hashRefreshToken(req.RefreshToken))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, invalidResp)
+			return
+		}
+
+		// 已经轮换或吊销过的令牌再次出现, 说明令牌可能泄露, 吊销整条链
+		if session.RevokedAt != nil {
+			revokeReusedFamily(c, session)
+			return
+		}
+		if time.Now().After(session.ExpiresAt) {
+			c.JSON(http.StatusUnauthorized, invalidResp)
+			return
+		}
+
+		userDTO, err := db.GetUserRepository().GetUserById(session.UserId)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, invalidResp)
+			return
+		}
+
+		// 轮换: 在同一条链上签发新的刷新令牌
+		refreshToken, next, err := newSession(session.UserId, session.FamilyId)
+		if err != nil {
+			log.GetLogger().Errorf("生成刷新令牌失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.RefreshTokenResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		if err = sessionRepository.RotateSession(session.Id, next); err != nil {
+			if errors.Is(err, db.ErrSessionRevoked) {
+				// 并发请求抢先使用了同一个令牌
+				revokeReusedFamily(c, session)
+				return
+			}
+			log.GetLogger().Errorf("轮换会话失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.RefreshTokenResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+
+		accessToken, err := GenerateToken(userDTO)
+		if err != nil {
+			log.GetLogger().Errorf("签发令牌失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.RefreshTokenResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, model.RefreshTokenResponseDTO{
+			Token:        accessToken,
+			RefreshToken: refreshToken,
+		})
+	}
+}
+
+// revokeReusedFamily 吊销被重复使用的刷新令牌所在的整条会话链
+func revokeReusedFamily(c *gin.Context, session *model.SessionDO) {
+	log.GetLogger().Warnf("刷新令牌被重复使用, userId=%d, familyId=%s", session.UserId, session.FamilyId)
+	if err := db.GetSessionRepository().RevokeFamily(session.FamilyId); err != nil {
+		log.GetLogger().Errorf("吊销会话链失败: %v", err)
+	}
+	c.JSON(http.StatusUnauthorized, model.RefreshTokenResponseDTO{
+		BaseResp: model.BaseResp{
+			Code:   model.RefreshTokenReused,
+			ErrMsg: "刷新令牌已失效, 请重新登录",
+		},
+	})
+}
+
+// Logout 吊销刷新令牌所在的会话链
+func Logout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionRepository := db.GetSessionRepository()
+
+		var req model.LogoutRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Error: errors.New("invalid request body")})
+			return
+		}
+
+		// 令牌不存在时同样返回成功, 避免泄露令牌是否有效
+		if session, err := sessionRepository.GetSessionByTokenHash(hashRefreshToken(req.RefreshToken)); err == nil {
+			if err = sessionRepository.RevokeFamily(session.FamilyId); err != nil {
+				log.GetLogger().Errorf("吊销会话链失败: %v", err)
+				c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
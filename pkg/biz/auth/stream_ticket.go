@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+)
+
+const (
+	// purposeStreamTicket 实时推送连接票据的用途
+	purposeStreamTicket = "stream_ticket"
+	// streamTicketTTL 票据有效期, 只用于建立连接, 连接建立后不再校验
+	streamTicketTTL = time.Minute
+	// streamTicketParam 携带票据的查询参数
+	streamTicketParam = "ticket"
+)
+
+// IssueStreamTicket 为当前用户签发实时推送的连接票据
+// 票据只能用于 StreamAuth 保护的推送接口, 有效期很短, 泄露在日志或浏览器历史中也不能当作登录令牌使用
+func IssueStreamTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := GetCurrentUser(c)
+		if !ok {
+			abortUnauthorized(c)
+			return
+		}
+		ticket, err := generateActionToken(purposeStreamTicket, actionClaims{UserId: currentUser.Id}, streamTicketTTL)
+		if err != nil {
+			log.GetLogger().Errorf("签发推送票据失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.StreamTicketResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.StreamTicketResponseDTO{
+			BaseResp:  model.BaseResp{Code: model.Success},
+			Ticket:    ticket,
+			ExpiresIn: int64(streamTicketTTL / time.Second),
+		})
+	}
+}
+
+// StreamAuth 实时推送接口的认证, 接受 Authorization 请求头中的令牌或查询参数 ticket 中的连接票据
+// 只挂在推送接口上, 其他接口不接受放在链接里的凭证
+func StreamAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ticket := c.Query(streamTicketParam)
+		if ticket == "" {
+			if !authenticateJWT(c) {
+				return
+			}
+			c.Next()
+			return
+		}
+
+		claims, err := parseActionToken(purposeStreamTicket, ticket)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, model.BaseResp{Code: model.TokenInvalid, ErrMsg: "票据无效或已过期"})
+			return
+		}
+		// 角色以数据库为准, 签发票据后注销的账号不能再连接
+		userDTO, err := db.GetUserRepository().GetUserById(claims.UserId)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, model.BaseResp{Code: model.TokenInvalid, ErrMsg: "票据无效或已过期"})
+			return
+		}
+		c.Set(currentUserKey, &model.UserDTO{
+			Id:   userDTO.Id,
+			Name: userDTO.Name,
+			Role: userDTO.Role,
+		})
+		c.Next()
+	}
+}
@@ -0,0 +1,99 @@
+package book
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/model"
+)
+
+// CreateBook 创建书的处理函数
+func CreateBook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bookRepository := db.GetBookRepository()
+		var bookDTO model.BookInfoDTO
+		if err := c.ShouldBindJSON(&bookDTO); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		bookDTO.Id = 0
+		if id, err := bookRepository.CreateBook(&bookDTO); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		} else {
+			c.JSON(http.StatusCreated, gin.H{"message": "Book created successfully", "id": id})
+		}
+	}
+}
+
+// GetBookById 根据ID获取书的处理函数
+func GetBookById() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bookRepository := db.GetBookRepository()
+		bookId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+			return
+		}
+
+		bookDTO, err := bookRepository.GetBookById(bookId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"book": bookDTO})
+	}
+}
+
+// UpdateBook 更新书的处理函数
+func UpdateBook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bookRepository := db.GetBookRepository()
+		bookId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+			return
+		}
+
+		var bookDTO model.BookInfoDTO
+		if err := c.ShouldBindJSON(&bookDTO); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if _, err := bookRepository.GetBookById(bookId); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+			return
+		}
+		bookDTO.Id = bookId
+		if err := bookRepository.UpdateBook(&bookDTO); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Book updated successfully"})
+	}
+}
+
+// DeleteBook 删除书的处理函数
+func DeleteBook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bookRepository := db.GetBookRepository()
+		bookId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+			return
+		}
+
+		if err := bookRepository.DeleteBook(bookId); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Book deleted successfully"})
+	}
+}
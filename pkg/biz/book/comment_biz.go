@@ -0,0 +1,138 @@
+package book
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/biz/feed"
+	"yujian-backend/pkg/biz/reputation"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/model"
+)
+
+// CreateBookComment 创建书评的处理函数
+func CreateBookComment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bookRepository := db.GetBookRepository()
+		bookId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+			return
+		}
+
+		var commentDTO model.BookCommentDTO
+		if err := c.ShouldBindJSON(&commentDTO); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if commentDTO.Content == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Content is empty"})
+			return
+		}
+
+		if _, err := bookRepository.GetBookById(bookId); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+			return
+		}
+
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		// 作者取自令牌, 点赞点踩由专门的接口维护, 都不接受客户端传入
+		comment := &model.BookCommentDTO{
+			BookId:     bookId,
+			AuthorId:   currentUser.Id,
+			AuthorName: currentUser.Name,
+			Content:    commentDTO.Content,
+		}
+		if id, err := bookRepository.CreateBookComment(comment); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		} else {
+			feed.Publish(&model.ActivityEventDO{
+				ActorId:   currentUser.Id,
+				Type:      model.ActivityBookReview,
+				SubjectId: id,
+				BookId:    bookId,
+				Summary:   comment.Content,
+			})
+			c.JSON(http.StatusCreated, gin.H{"message": "Comment created successfully", "id": id})
+		}
+	}
+}
+
+// GetBookComments 获取书评列表的处理函数
+func GetBookComments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bookRepository := db.GetBookRepository()
+		bookId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+			return
+		}
+
+		comments, err := bookRepository.GetBookCommentsByBookId(bookId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"comments": comments})
+	}
+}
+
+// UpdateBookComment 更新书评的处理函数, 只有作者本人可以修改
+func UpdateBookComment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bookRepository := db.GetBookRepository()
+
+		var commentDTO model.BookCommentDTO
+		if err := c.ShouldBindJSON(&commentDTO); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if commentDTO.Content == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Content is empty"})
+			return
+		}
+
+		comment, ok := authorizeBookComment(c)
+		if !ok {
+			return
+		}
+
+		comment.Content = commentDTO.Content
+		if err := bookRepository.UpdateBookComment(comment.Transfer()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Comment updated successfully"})
+	}
+}
+
+// DeleteBookComment 删除书评的处理函数, 作者本人或有删评论权限的用户可以删除
+func DeleteBookComment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bookRepository := db.GetBookRepository()
+
+		comment, ok := authorizeBookComment(c, model.PermDeleteAnyComment)
+		if !ok {
+			return
+		}
+
+		if err := bookRepository.DeleteBookComment(comment.Id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		reputation.PenalizeRemoval(c, model.ReputationBookComment, comment.Id, comment.AuthorId)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+	}
+}
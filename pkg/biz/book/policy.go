@@ -0,0 +1,47 @@
+package book
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/model"
+)
+
+// authorizeBookComment 加载路径参数 commentId 对应的书评, 并校验当前用户能否操作它
+// 书评必须属于路径参数 id 对应的书, 校验不通过时请求已被中断, 返回 false
+func authorizeBookComment(c *gin.Context, overrides ...model.Permission) (*model.BookCommentDTO, bool) {
+	comment, ok := loadBookComment(c)
+	if !ok {
+		return nil, false
+	}
+
+	if !auth.AuthorizeOwner(c, comment.AuthorId, overrides...) {
+		return nil, false
+	}
+	return comment, true
+}
+
+// loadBookComment 加载路径参数 commentId 对应的书评, 不校验归属; 书评必须属于路径参数 id 对应的书
+func loadBookComment(c *gin.Context) (*model.BookCommentDTO, bool) {
+	bookId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return nil, false
+	}
+	commentId, err := strconv.ParseInt(c.Param("commentId"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return nil, false
+	}
+
+	comment, err := db.GetBookRepository().GetBookCommentById(commentId)
+	if err != nil || comment.BookId != bookId {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return nil, false
+	}
+	return comment, true
+}
@@ -0,0 +1,71 @@
+package book
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/biz/notification"
+	"yujian-backend/pkg/config"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+)
+
+// SetBookCommentReaction 点赞、点踩或取消表态路径中的书评, 被书评作者拉黑的用户不能表态
+func SetBookCommentReaction() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		comment, ok := loadBookComment(c)
+		if !ok {
+			return
+		}
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		var req model.SetReactionRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !req.Reaction.Valid() {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.ReactionInvalid, ErrMsg: "表态只能是 like、dislike 或空"})
+			return
+		}
+
+		// 匿名书评的作者ID为0, 不存在拉黑关系
+		if comment.AuthorId != 0 {
+			blocked, err := db.GetRelationRepository().IsBlocked(comment.AuthorId, currentUser.Id)
+			if err != nil {
+				log.GetLogger().Errorf("查询拉黑关系失败, user=%d, target=%d: %v", comment.AuthorId, currentUser.Id, err)
+				c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+				return
+			}
+			if blocked {
+				c.JSON(http.StatusForbidden, model.BaseResp{Code: model.BlockedByUser, ErrMsg: "对方已将你拉黑"})
+				return
+			}
+		}
+
+		result, err := db.GetBookRepository().SetBookCommentReaction(comment.Id, currentUser.Id, req.Reaction, config.Config.Reputation.BookComment)
+		if err != nil {
+			log.GetLogger().Errorf("书评表态失败, comment=%d, user=%d: %v", comment.Id, currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.ReactionResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		if result.Reaction == model.ReactionLike && result.Previous != model.ReactionLike {
+			notification.Notify(&model.NotificationDO{
+				UserId:    comment.AuthorId,
+				Type:      model.NotifyBookCommentLike,
+				SubjectId: comment.Id,
+				BookId:    comment.BookId,
+			}, currentUser.Id)
+		}
+		c.JSON(http.StatusOK, model.ReactionResponseDTO{ReactionResultDTO: result})
+	}
+}
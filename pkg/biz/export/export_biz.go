@@ -0,0 +1,171 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/config"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+)
+
+// staleExportAge 排队或打包超过这个时间的任务视为被中断
+const staleExportAge = time.Hour
+
+// RequestDataExport 发起个人数据导出, 已有进行中的任务时直接返回该任务
+func RequestDataExport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		exportRepository := db.GetExportRepository()
+
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.DataExportResponseDTO{
+				BaseResp: model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"},
+			})
+			return
+		}
+
+		now := time.Now()
+		if err := exportRepository.FailStaleExports(now.Add(-staleExportAge)); err != nil {
+			log.GetLogger().Errorf("清理中断的导出任务失败: %v", err)
+		}
+		latest, err := exportRepository.GetLatestExport(currentUser.Id)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.GetLogger().Errorf("查询导出任务失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.DataExportResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		if err == nil && (latest.Status == model.ExportPending || latest.Status == model.ExportRunning) {
+			c.JSON(http.StatusAccepted, model.DataExportResponseDTO{Export: latest.Transfer()})
+			return
+		}
+
+		job := &model.DataExportDO{
+			UserId:     currentUser.Id,
+			Status:     model.ExportPending,
+			CreateTime: now,
+		}
+		if err = exportRepository.CreateExport(job); err != nil {
+			log.GetLogger().Errorf("创建导出任务失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.DataExportResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		jobDTO := job.Transfer()
+		startExportJob(job)
+
+		c.JSON(http.StatusAccepted, model.DataExportResponseDTO{Export: jobDTO})
+	}
+}
+
+// GetDataExport 查询最近一次导出任务, 完成后返回带签名的下载链接
+func GetDataExport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.DataExportResponseDTO{
+				BaseResp: model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"},
+			})
+			return
+		}
+
+		job, err := db.GetExportRepository().GetLatestExport(currentUser.Id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, model.DataExportResponseDTO{
+				BaseResp: model.BaseResp{Code: model.ExportJobNotFound, ErrMsg: "没有导出任务"},
+			})
+			return
+		} else if err != nil {
+			log.GetLogger().Errorf("查询导出任务失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.DataExportResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+
+		jobDTO := job.Transfer()
+		if job.Status == model.ExportDone && job.ExpiresAt != nil {
+			ttl := time.Until(*job.ExpiresAt)
+			if ttl <= 0 {
+				jobDTO.Status = model.ExportExpired
+			} else {
+				token, err := auth.GenerateDownloadToken(job.UserId, job.Id, ttl)
+				if err != nil {
+					log.GetLogger().Errorf("签发下载令牌失败: %v", err)
+					c.JSON(http.StatusInternalServerError, model.DataExportResponseDTO{
+						BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+					})
+					return
+				}
+				jobDTO.DownloadURL = fmt.Sprintf("/exports/%d/download?token=%s", job.Id, url.QueryEscape(token))
+			}
+		}
+		c.JSON(http.StatusOK, model.DataExportResponseDTO{Export: jobDTO})
+	}
+}
+
+// DownloadDataExport 通过签名链接下载导出文件, 不需要登录
+func DownloadDataExport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		exportId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Error: errors.New("invalid export id")})
+			return
+		}
+
+		userId, tokenExportId, err := auth.ParseDownloadToken(c.Query("token"))
+		if err != nil || tokenExportId != exportId {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.ActionTokenInvalid, ErrMsg: "下载链接无效或已过期"})
+			return
+		}
+
+		job, err := db.GetExportRepository().GetExportById(exportId)
+		if err != nil || job.UserId != userId {
+			c.JSON(http.StatusNotFound, model.BaseResp{Code: model.ExportJobNotFound, ErrMsg: "导出任务不存在"})
+			return
+		}
+		if job.Status == model.ExportExpired || (job.ExpiresAt != nil && time.Now().After(*job.ExpiresAt)) {
+			c.JSON(http.StatusGone, model.BaseResp{Code: model.ExportFileExpired, ErrMsg: "导出文件已过期"})
+			return
+		}
+		if job.Status != model.ExportDone {
+			c.JSON(http.StatusConflict, model.BaseResp{Code: model.ExportJobNotReady, ErrMsg: "导出尚未完成"})
+			return
+		}
+
+		path := filepath.Join(config.Config.Export.Dir, filepath.Base(job.FileName))
+		if _, err = os.Stat(path); err != nil {
+			log.GetLogger().Errorf("导出文件不存在, id=%d: %v", job.Id, err)
+			c.JSON(http.StatusGone, model.BaseResp{Code: model.ExportFileExpired, ErrMsg: "导出文件已过期"})
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.FileAttachment(path, fmt.Sprintf("yujian-data-%d.zip", job.Id))
+	}
+}
+
+// RemoveUserExports 删除用户的全部导出任务和文件, 注销账号时调用
+func RemoveUserExports(userId int64) {
+	fileNames, err := db.GetExportRepository().DeleteExportsByUserId(userId)
+	if err != nil {
+		log.GetLogger().Errorf("删除导出任务失败, userId=%d: %v", userId, err)
+		return
+	}
+	for _, fileName := range fileNames {
+		removeExportFile(fileName)
+	}
+}
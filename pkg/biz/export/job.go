@@ -0,0 +1,389 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"yujian-backend/pkg/biz/notification"
+	"yujian-backend/pkg/config"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/es"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+// exportSection 导出包中的一个文件, collect 返回的数据序列化为JSON写入 name
+type exportSection struct {
+	name    string
+	collect func(ctx context.Context, userId int64) (interface{}, error)
+}
+
+// exportSections 导出包包含的全部文件, 新增用户数据时在这里登记
+var exportSections = []exportSection{
+	{name: "profile.json", collect: collectProfile},
+	{name: "posts.json", collect: collectPosts},
+	{name: "post_comments.json", collect: collectPostComments},
+	{name: "book_comments.json", collect: collectBookComments},
+	{name: "reactions.json", collect: collectReactions},
+	{name: "security_events.json", collect: collectSecurityEvents},
+	{name: "following.json", collect: collectFollowing},
+	{name: "followers.json", collect: collectFollowers},
+	{name: "blocks.json", collect: collectBlocks},
+	{name: "mutes.json", collect: collectMutes},
+	{name: "bookshelf.json", collect: collectBookshelf},
+	{name: "reading_goals.json", collect: collectReadingGoals},
+	{name: "activity.json", collect: collectActivity},
+	{name: "notifications.json", collect: collectNotifications},
+	{name: "messages.json", collect: collectMessages},
+	{name: "reputation.json", collect: collectReputation},
+	{name: "groups.json", collect: collectGroups},
+}
+
+// exportProfile 导出的账号资料
+type exportProfile struct {
+	User       *model.UserDTO          `json:"user"`
+	Identities []*model.UserIdentityDO `json:"identities"`
+	MFAEnabled bool                    `json:"mfa_enabled"`
+	APIKeys    []*model.APIKeyDTO      `json:"api_keys"`
+}
+
+func collectProfile(_ context.Context, userId int64) (interface{}, error) {
+	user, err := db.GetUserRepository().GetUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := db.GetIdentityRepository().GetIdentitiesByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	apiKeys, err := db.GetAPIKeyRepository().ListAPIKeysByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	mfa, err := db.GetMFARepository().GetMFA(userId)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &exportProfile{
+		User:       user,
+		Identities: identities,
+		MFAEnabled: mfa != nil && mfa.Enabled,
+		APIKeys:    apiKeys,
+	}, nil
+}
+
+// collectPosts 导出用户的帖子, 正文从ES中读取
+func collectPosts(ctx context.Context, userId int64) (interface{}, error) {
+	posts, err := db.GetPostRepository().GetPostsByAuthorId(userId)
+	if err != nil {
+		return nil, err
+	}
+	indexName := (&model.PostEsModel{}).GetIndexName()
+	exported := make([]*model.ExportedPostDTO, len(posts))
+	for i, post := range posts {
+		exported[i] = &model.ExportedPostDTO{
+			Id:        post.Id,
+			Title:     post.Title,
+			ContentId: post.ContentId,
+			EditTime:  post.EditTime,
+		}
+		doc, err := es.GetDocument[*model.PostEsModel](ctx, indexName, post.ContentId)
+		if err == nil {
+			exported[i].Content = doc.Content
+		} else if !errors.Is(err, es.ErrDocumentNotFound) {
+			return nil, fmt.Errorf("load content of post %d: %w", post.Id, err)
+		}
+	}
+	return exported, nil
+}
+
+func collectPostComments(_ context.Context, userId int64) (interface{}, error) {
+	return db.GetPostRepository().GetPostCommentsByAuthorId(userId)
+}
+
+func collectBookComments(_ context.Context, userId int64) (interface{}, error) {
+	return db.GetBookRepository().GetBookCommentsByAuthorId(userId)
+}
+
+func collectReactions(_ context.Context, userId int64) (interface{}, error) {
+	return db.GetUserRepository().GetUserReactions(userId)
+}
+
+// collectSecurityEvents 导出与用户有关的登录和账号安全事件
+func collectSecurityEvents(_ context.Context, userId int64) (interface{}, error) {
+	events, _, err := db.GetAuditRepository().QueryEvents(&model.AuditQuery{
+		UserId: userId,
+		Limit:  -1, // 不分页, 导出全部
+	})
+	return events, err
+}
+
+func collectFollowing(_ context.Context, userId int64) (interface{}, error) {
+	following, _, err := db.GetFollowRepository().ListFollowing(userId, 0, -1)
+	return following, err
+}
+
+func collectFollowers(_ context.Context, userId int64) (interface{}, error) {
+	followers, _, err := db.GetFollowRepository().ListFollowers(userId, 0, -1)
+	return followers, err
+}
+
+func collectBlocks(_ context.Context, userId int64) (interface{}, error) {
+	blocks, _, err := db.GetRelationRepository().ListBlocked(userId, 0, -1)
+	return blocks, err
+}
+
+func collectMutes(_ context.Context, userId int64) (interface{}, error) {
+	mutes, _, err := db.GetRelationRepository().ListMuted(userId, 0, -1)
+	return mutes, err
+}
+
+// collectBookshelf 导出阅读状态书架和全部自建书架
+func collectBookshelf(_ context.Context, userId int64) (interface{}, error) {
+	shelfRepository := db.GetShelfRepository()
+	entries, _, err := shelfRepository.ListShelfEntries(userId, "", 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	shelves, err := shelfRepository.ListCustomShelves(userId)
+	if err != nil {
+		return nil, err
+	}
+	exported := &model.ExportedShelvesDTO{
+		Entries:       entries,
+		CustomShelves: make([]*model.ExportedCustomShelfDTO, len(shelves)),
+	}
+	for i, shelf := range shelves {
+		books, _, err := shelfRepository.ListShelfBooks(shelf.Id, 0, -1)
+		if err != nil {
+			return nil, err
+		}
+		exported.CustomShelves[i] = &model.ExportedCustomShelfDTO{CustomShelfDTO: shelf, Books: books}
+	}
+	return exported, nil
+}
+
+func collectReadingGoals(_ context.Context, userId int64) (interface{}, error) {
+	return db.GetStatsRepository().ListReadingGoals(userId)
+}
+
+func collectActivity(_ context.Context, userId int64) (interface{}, error) {
+	return db.GetActivityRepository().ListUserActivity(userId, 0, -1)
+}
+
+func collectNotifications(_ context.Context, userId int64) (interface{}, error) {
+	notifications, _, err := db.GetNotificationRepository().ListNotifications(userId, false, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	return notification.RenderNotifications(notifications)
+}
+
+func collectMessages(_ context.Context, userId int64) (interface{}, error) {
+	return db.GetMessageRepository().ListUserMessages(userId)
+}
+
+// exportReputation 导出的声望, 包含全部流水
+type exportReputation struct {
+	Reputation *model.ReputationDTO       `json:"reputation"`
+	Events     []*model.ReputationEventDO `json:"events"`
+}
+
+func collectReputation(_ context.Context, userId int64) (interface{}, error) {
+	reputationRepository := db.GetReputationRepository()
+	breakdown, err := reputationRepository.GetReputation(userId)
+	if err != nil {
+		return nil, err
+	}
+	events, err := reputationRepository.ListEvents(userId, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	return &exportReputation{Reputation: model.NewReputationDTO(breakdown), Events: events}, nil
+}
+
+// exportGroups 导出的读书会数据, 包含加入的读书会、成员身份和待处理的申请或邀请
+type exportGroups struct {
+	Groups      []*model.GroupDO        `json:"groups"`
+	Memberships []*model.GroupMemberDO  `json:"memberships"`
+	Requests    []*model.GroupRequestDO `json:"requests"`
+}
+
+func collectGroups(_ context.Context, userId int64) (interface{}, error) {
+	groupRepository := db.GetGroupRepository()
+	groups, err := groupRepository.ListUserGroups(userId)
+	if err != nil {
+		return nil, err
+	}
+	memberships, requests, err := groupRepository.ListUserMemberships(userId)
+	if err != nil {
+		return nil, err
+	}
+	return &exportGroups{Groups: groups, Memberships: memberships, Requests: requests}, nil
+}
+
+var (
+	slotsOnce sync.Once
+	slots     chan struct{}
+)
+
+// acquireSlot 占用一个打包名额, 限制同时运行的导出任务数量
+func acquireSlot() func() {
+	slotsOnce.Do(func() {
+		n := config.Config.Export.MaxConcurrent
+		if n <= 0 {
+			n = 1
+		}
+		slots = make(chan struct{}, n)
+	})
+	slots <- struct{}{}
+	return func() { <-slots }
+}
+
+// startExportJob 在后台运行导出任务
+func startExportJob(job *model.DataExportDO) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.GetLogger().Errorf("导出任务崩溃, id=%d: %v", job.Id, r)
+				finishExportJob(job, "", fmt.Errorf("panic: %v", r))
+			}
+		}()
+
+		release := acquireSlot()
+		defer release()
+
+		job.Status = model.ExportRunning
+		if err := db.GetExportRepository().UpdateExport(job); err != nil {
+			log.GetLogger().Errorf("更新导出任务失败, id=%d: %v", job.Id, err)
+		}
+
+		fileName, err := buildExportArchive(context.Background(), job)
+		finishExportJob(job, fileName, err)
+		cleanupExpiredExports()
+	}()
+}
+
+// finishExportJob 记录导出任务的结果
+func finishExportJob(job *model.DataExportDO, fileName string, err error) {
+	now := time.Now()
+	job.FinishTime = &now
+	if err != nil {
+		log.GetLogger().Errorf("导出任务失败, id=%d: %v", job.Id, err)
+		job.Status = model.ExportFailed
+		job.ErrMsg = "export failed"
+	} else {
+		expiresAt := now.Add(config.Config.Export.LinkExpire)
+		job.Status = model.ExportDone
+		job.FileName = fileName
+		job.ExpiresAt = &expiresAt
+	}
+	if err := db.GetExportRepository().UpdateExport(job); err != nil {
+		log.GetLogger().Errorf("更新导出任务失败, id=%d: %v", job.Id, err)
+	}
+}
+
+// buildExportArchive 把全部导出数据写入一个zip文件, 返回文件名
+func buildExportArchive(ctx context.Context, job *model.DataExportDO) (string, error) {
+	dir := config.Config.Export.Dir
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	// 文件名带随机部分, 避免被猜到
+	fileName := fmt.Sprintf("export_%d_%s.zip", job.Id, utils.GenerateUUID())
+	path := filepath.Join(dir, fileName)
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", err
+	}
+	if err = writeExportArchive(ctx, file, job.UserId); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", err
+	}
+	if err = file.Close(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return fileName, nil
+}
+
+func writeExportArchive(ctx context.Context, file *os.File, userId int64) error {
+	archive := zip.NewWriter(file)
+	for _, section := range exportSections {
+		data, err := section.collect(ctx, userId)
+		if err != nil {
+			return fmt.Errorf("collect %s: %w", section.name, err)
+		}
+		w, err := archive.Create(section.name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(data); err != nil {
+			return fmt.Errorf("encode %s: %w", section.name, err)
+		}
+	}
+	return archive.Close()
+}
+
+// RunCleanup 启动时清理一次过期的导出文件, 之后按配置的间隔定期清理, 直到 ctx 结束
+// 没有新的导出任务时过期文件也会被及时删除
+func RunCleanup(ctx context.Context) {
+	cleanupExpiredExports()
+	interval := config.Config.Export.CleanupInterval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cleanupExpiredExports()
+		}
+	}
+}
+
+// cleanupExpiredExports 删除已过下载期限的导出文件
+func cleanupExpiredExports() {
+	exportRepository := db.GetExportRepository()
+	exports, err := exportRepository.ListExpiredExports(time.Now())
+	if err != nil {
+		log.GetLogger().Errorf("查询过期导出任务失败: %v", err)
+		return
+	}
+	for _, export := range exports {
+		removeExportFile(export.FileName)
+		export.Status = model.ExportExpired
+		export.FileName = ""
+		if err = exportRepository.UpdateExport(export); err != nil {
+			log.GetLogger().Errorf("更新导出任务失败, id=%d: %v", export.Id, err)
+		}
+	}
+}
+
+// removeExportFile 删除导出文件, 文件不存在时忽略
+func removeExportFile(fileName string) {
+	if fileName == "" {
+		return
+	}
+	path := filepath.Join(config.Config.Export.Dir, filepath.Base(fileName))
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.GetLogger().Errorf("删除导出文件失败, file=%s: %v", fileName, err)
+	}
+}
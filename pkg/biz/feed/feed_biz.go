@@ -0,0 +1,162 @@
+package feed
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/config"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+// 动态的分页参数和存储长度, 长度按字符计
+const (
+	feedDefaultLimit = 20
+	feedMaxLimit     = 50
+	maxTitleLen      = 255
+	maxSummaryLen    = 200
+)
+
+// Publish 记录一条动态, 失败只记日志, 不影响发帖、写书评等触发动态的操作
+func Publish(event *model.ActivityEventDO) {
+	if event.CreateTime.IsZero() {
+		event.CreateTime = time.Now()
+	}
+	event.Title = utils.Truncate(event.Title, maxTitleLen)
+	event.Summary = utils.Truncate(event.Summary, maxSummaryLen)
+	if err := db.GetActivityRepository().Publish(event, config.Config.Feed.FanOutMaxFollowers); err != nil {
+		log.GetLogger().Errorf("记录动态失败, type=%s, actor=%d, subject=%d: %v", event.Type, event.ActorId, event.SubjectId, err)
+	}
+}
+
+// GetHomeFeed 按游标获取当前用户关注的人的动态, 按时间倒序
+func GetHomeFeed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		cursor, limit, ok := parseCursor(c)
+		if !ok {
+			return
+		}
+
+		events, err := db.GetActivityRepository().ListTimeline(currentUser.Id, cursor, limit)
+		if err != nil {
+			log.GetLogger().Errorf("查询首页动态失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.ActivityFeedResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		respondEvents(c, events, limit)
+	}
+}
+
+// GetUserActivity 按游标获取某个用户发布的动态
+func GetUserActivity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		cursor, limit, ok := parseCursor(c)
+		if !ok {
+			return
+		}
+
+		events, err := db.GetActivityRepository().ListUserActivity(userId, cursor, limit)
+		if err != nil {
+			log.GetLogger().Errorf("查询用户动态失败, user=%d: %v", userId, err)
+			c.JSON(http.StatusInternalServerError, model.ActivityFeedResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		respondEvents(c, events, limit)
+	}
+}
+
+// parseCursor 解析 cursor 和 limit 参数, cursor 为上一页最后一条动态的ID, 不传时从最新开始
+func parseCursor(c *gin.Context) (int64, int, bool) {
+	var cursor int64
+	if v := c.Query("cursor"); v != "" {
+		var err error
+		if cursor, err = strconv.ParseInt(v, 10, 64); err != nil || cursor <= 0 {
+			c.JSON(http.StatusBadRequest, model.ActivityFeedResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid cursor")},
+			})
+			return 0, 0, false
+		}
+	}
+	_, limit, ok := utils.ParsePage("", c.Query("limit"), feedDefaultLimit, feedMaxLimit)
+	if !ok {
+		c.JSON(http.StatusBadRequest, model.ActivityFeedResponseDTO{
+			BaseResp: model.BaseResp{Error: errors.New("invalid limit")},
+		})
+		return 0, 0, false
+	}
+	return cursor, limit, true
+}
+
+// respondEvents 加载动态的作者和书后返回, 取满一页时给出下一页的游标
+func respondEvents(c *gin.Context, events []*model.ActivityEventDO, limit int) {
+	result, err := renderEvents(events)
+	if err != nil {
+		log.GetLogger().Errorf("加载动态详情失败: %v", err)
+		c.JSON(http.StatusInternalServerError, model.ActivityFeedResponseDTO{
+			BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+		})
+		return
+	}
+	resp := model.ActivityFeedResponseDTO{Events: result}
+	if len(events) == limit {
+		resp.NextCursor = strconv.FormatInt(events[len(events)-1].Id, 10)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// renderEvents 批量加载动态的作者和书, 书已被删除的动态不返回
+func renderEvents(events []*model.ActivityEventDO) ([]*model.ActivityEventDTO, error) {
+	actorIds := make([]int64, 0, len(events))
+	bookIds := make([]int64, 0, len(events))
+	for _, event := range events {
+		actorIds = append(actorIds, event.ActorId)
+		if event.BookId != 0 {
+			bookIds = append(bookIds, event.BookId)
+		}
+	}
+	actors, err := db.GetUserRepository().BatchGetUsers(actorIds)
+	if err != nil {
+		return nil, err
+	}
+	books, err := db.GetBookRepository().BatchGetBooks(bookIds)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.ActivityEventDTO, 0, len(events))
+	for _, event := range events {
+		actor, ok := actors[event.ActorId]
+		if !ok {
+			actor = model.DeletedUser()
+		}
+		var book *model.BookInfoDTO
+		if event.BookId != 0 {
+			if book, ok = books[event.BookId]; !ok {
+				continue
+			}
+		}
+		result = append(result, event.TransformToDTO(actor, book))
+	}
+	return result, nil
+}
@@ -0,0 +1,346 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/es"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+// 读书会列表的分页参数
+const (
+	groupDefaultLimit = 20
+	groupMaxLimit     = 50
+)
+
+// CreateGroup 创建读书会, 当前用户成为创建者; 不填可见性时为公开
+func CreateGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.CreateGroupRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		if req.Visibility == "" {
+			req.Visibility = model.GroupPublic
+		}
+		bookId := req.CurrentBookId
+
+		group := &model.GroupDO{OwnerId: currentUser.Id}
+		updates, code, errMsg := groupUpdates(group, &model.UpdateGroupRequestDTO{
+			Name:          &req.Name,
+			Description:   &req.Description,
+			Visibility:    &req.Visibility,
+			CurrentBookId: &bookId,
+		})
+		if errMsg != "" {
+			c.JSON(http.StatusBadRequest, model.GroupResponseDTO{BaseResp: model.BaseResp{Code: code, ErrMsg: errMsg}})
+			return
+		}
+		if !checkBook(c, req.CurrentBookId) {
+			return
+		}
+		group.Name = updates["name"].(string)
+		group.Description = updates["description"].(string)
+		group.Visibility = req.Visibility
+		group.CurrentBookId = req.CurrentBookId
+		group.CreateTime = time.Now()
+		group.UpdateTime = group.CreateTime
+
+		if err := db.GetGroupRepository().CreateGroup(group); err != nil {
+			log.GetLogger().Errorf("创建读书会失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.GroupResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		respondGroup(c, http.StatusCreated, &groupAccess{group: group, role: model.GroupOwner})
+	}
+}
+
+// GetGroup 获取读书会详情, 带当前用户的角色和待处理的申请或邀请
+func GetGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		access, ok := loadGroup(c)
+		if !ok {
+			return
+		}
+		respondGroup(c, http.StatusOK, access)
+	}
+}
+
+// UpdateGroup 修改读书会的名称、简介、可见性和正在读的书, 只更新传了的字段; 创建者和管理员可以修改
+func UpdateGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.UpdateGroupRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		access, ok := authorizeGroup(c, false)
+		if !ok {
+			return
+		}
+
+		group := access.group
+		updates, code, errMsg := groupUpdates(group, &req)
+		if errMsg != "" {
+			c.JSON(http.StatusBadRequest, model.GroupResponseDTO{BaseResp: model.BaseResp{Code: code, ErrMsg: errMsg}})
+			return
+		}
+		if bookId, ok := updates["current_book_id"].(int64); ok {
+			if !checkBook(c, bookId) {
+				return
+			}
+			group.CurrentBookId = bookId
+		}
+		if name, ok := updates["name"].(string); ok {
+			group.Name = name
+		}
+		if description, ok := updates["description"].(string); ok {
+			group.Description = description
+		}
+		if visibility, ok := updates["visibility"].(model.GroupVisibility); ok {
+			group.Visibility = visibility
+		}
+
+		if err := db.GetGroupRepository().UpdateGroup(group.Id, updates); err != nil {
+			log.GetLogger().Errorf("修改读书会失败, group=%d: %v", group.Id, err)
+			c.JSON(http.StatusInternalServerError, model.GroupResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		respondGroup(c, http.StatusOK, access)
+	}
+}
+
+// DeleteGroup 解散读书会, 只有创建者可以操作; 读书会内的帖子和评论一并删除
+func DeleteGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		access, ok := authorizeGroup(c, true)
+		if !ok {
+			return
+		}
+
+		contentIds, err := db.GetGroupRepository().DeleteGroup(access.group.Id)
+		if err != nil {
+			log.GetLogger().Errorf("解散读书会失败, group=%d: %v", access.group.Id, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		// ES不参与数据库事务, 清理失败只记录日志, 读书会已经解散
+		if len(contentIds) > 0 {
+			if err = es.DeleteDocuments(context.Background(), (&model.PostEsModel{}).GetIndexName(), contentIds); err != nil {
+				log.GetLogger().Errorf("清理ES文档失败, group=%d: %v", access.group.Id, err)
+			}
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// ListGroups 分页获取读书会, 按创建时间倒序; 只邀请的读书会只有成员能看到
+// 可以用 book_id 筛选正在读某本书的读书会
+func ListGroups() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		offset, limit, ok := utils.ParsePage(c.Query("offset"), c.Query("limit"), groupDefaultLimit, groupMaxLimit)
+		if !ok {
+			c.JSON(http.StatusBadRequest, model.GroupListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid offset or limit")},
+			})
+			return
+		}
+		var bookId int64
+		if v := c.Query("book_id"); v != "" {
+			var err error
+			if bookId, err = strconv.ParseInt(v, 10, 64); err != nil || bookId <= 0 {
+				c.JSON(http.StatusBadRequest, model.GroupListResponseDTO{
+					BaseResp: model.BaseResp{Error: errors.New("invalid book_id")},
+				})
+				return
+			}
+		}
+		viewerId := currentUserId(c)
+
+		groups, total, err := db.GetGroupRepository().ListGroups(viewerId, bookId, offset, limit)
+		if err != nil {
+			log.GetLogger().Errorf("查询读书会列表失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.GroupListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		result, err := renderGroups(groups, viewerId)
+		if err != nil {
+			log.GetLogger().Errorf("加载读书会信息失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.GroupListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.GroupListResponseDTO{Total: total, Groups: result})
+	}
+}
+
+// ListJoinedGroups 获取当前用户加入的全部读书会, 按加入时间倒序
+func ListJoinedGroups() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+
+		groups, err := db.GetGroupRepository().ListUserGroups(currentUser.Id)
+		if err != nil {
+			log.GetLogger().Errorf("查询加入的读书会失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.GroupListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		result, err := renderGroups(groups, currentUser.Id)
+		if err != nil {
+			log.GetLogger().Errorf("加载读书会信息失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.GroupListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.GroupListResponseDTO{Total: int64(len(result)), Groups: result})
+	}
+}
+
+// groupUpdates 校验创建或修改读书会的请求, 转换为需要更新的列; 校验失败时返回错误码和错误信息
+func groupUpdates(group *model.GroupDO, req *model.UpdateGroupRequestDTO) (map[string]interface{}, model.ErrorCode, string) {
+	updates := make(map[string]interface{})
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, model.GroupInfoInvalid, "读书会名称不能为空"
+		}
+		if utf8.RuneCountInString(name) > model.MaxGroupNameLen {
+			return nil, model.GroupInfoInvalid, "读书会名称过长"
+		}
+		updates["name"] = name
+	}
+	if req.Description != nil {
+		description := strings.TrimSpace(*req.Description)
+		if utf8.RuneCountInString(description) > model.MaxGroupDescriptionLen {
+			return nil, model.GroupInfoInvalid, "读书会简介过长"
+		}
+		updates["description"] = description
+	}
+	if req.Visibility != nil {
+		if !req.Visibility.Valid() {
+			return nil, model.GroupInfoInvalid, "可见性只能是 public、private 或 invite_only"
+		}
+		updates["visibility"] = *req.Visibility
+	}
+	if req.CurrentBookId != nil && *req.CurrentBookId != group.CurrentBookId {
+		if *req.CurrentBookId < 0 {
+			return nil, model.GroupInfoInvalid, "书的ID不合法"
+		}
+		updates["current_book_id"] = *req.CurrentBookId
+	}
+	return updates, model.Success, ""
+}
+
+// checkBook 校验书存在, bookId 为0表示不设置; 不存在时已写入响应
+func checkBook(c *gin.Context, bookId int64) bool {
+	if bookId == 0 {
+		return true
+	}
+	if _, err := db.GetBookRepository().GetBookById(bookId); err != nil {
+		c.JSON(http.StatusNotFound, model.BaseResp{Code: model.BookNotExists, ErrMsg: "书不存在"})
+		return false
+	}
+	return true
+}
+
+// respondGroup 加载创建者和正在读的书后返回读书会详情
+func respondGroup(c *gin.Context, status int, access *groupAccess) {
+	result, err := renderGroups([]*model.GroupDO{access.group}, 0)
+	if err != nil {
+		log.GetLogger().Errorf("加载读书会信息失败, group=%d: %v", access.group.Id, err)
+		c.JSON(http.StatusInternalServerError, model.GroupResponseDTO{
+			BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+		})
+		return
+	}
+	group := result[0]
+	group.MyRole = access.role
+	if access.request != nil {
+		group.Pending = access.request.Type
+	}
+	c.JSON(status, model.GroupResponseDTO{Group: group})
+}
+
+// renderGroups 批量加载读书会的创建者和正在读的书, viewerId 不为0时带上该用户的角色和待处理的申请或邀请
+// 已被删除的书不展示
+func renderGroups(groups []*model.GroupDO, viewerId int64) ([]*model.GroupDTO, error) {
+	groupIds := make([]int64, len(groups))
+	ownerIds := make([]int64, len(groups))
+	bookIds := make([]int64, 0, len(groups))
+	for i, group := range groups {
+		groupIds[i] = group.Id
+		ownerIds[i] = group.OwnerId
+		if group.CurrentBookId != 0 {
+			bookIds = append(bookIds, group.CurrentBookId)
+		}
+	}
+	owners, err := db.GetUserRepository().BatchGetUsers(ownerIds)
+	if err != nil {
+		return nil, err
+	}
+	books, err := db.GetBookRepository().BatchGetBooks(bookIds)
+	if err != nil {
+		return nil, err
+	}
+	groupRepository := db.GetGroupRepository()
+	roles, err := groupRepository.BatchGetRoles(groupIds, viewerId)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := groupRepository.BatchGetRequestTypes(groupIds, viewerId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.GroupDTO, len(groups))
+	for i, group := range groups {
+		owner, ok := owners[group.OwnerId]
+		if !ok {
+			owner = model.DeletedUser()
+		}
+		result[i] = group.TransformToDTO(owner, books[group.CurrentBookId])
+		result[i].MyRole = roles[group.Id]
+		result[i].Pending = pending[group.Id]
+	}
+	return result, nil
+}
+
+// currentUserId 当前登录用户的ID, 未登录时为0
+func currentUserId(c *gin.Context) int64 {
+	if currentUser, ok := auth.GetCurrentUser(c); ok {
+		return currentUser.Id
+	}
+	return 0
+}
@@ -0,0 +1,528 @@
+package group
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+// 成员列表的分页参数
+const (
+	memberDefaultLimit = 50
+	memberMaxLimit     = 200
+)
+
+// ListMembers 分页获取读书会成员, 按加入时间排序
+func ListMembers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		access, ok := loadGroup(c)
+		if !ok {
+			return
+		}
+		offset, limit, ok := utils.ParsePage(c.Query("offset"), c.Query("limit"), memberDefaultLimit, memberMaxLimit)
+		if !ok {
+			c.JSON(http.StatusBadRequest, model.GroupMemberListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid offset or limit")},
+			})
+			return
+		}
+
+		members, total, err := db.GetGroupRepository().ListMembers(access.group.Id, offset, limit)
+		if err != nil {
+			log.GetLogger().Errorf("查询读书会成员失败, group=%d: %v", access.group.Id, err)
+			c.JSON(http.StatusInternalServerError, model.GroupMemberListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.GroupMemberListResponseDTO{Total: total, Members: members})
+	}
+}
+
+// JoinGroup 加入读书会: 公开读书会直接加入, 私密读书会提交申请等待管理员批准, 受邀时直接接受邀请
+// 只邀请的读书会没有受邀时看不到, 返回不存在
+func JoinGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.JoinGroupRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		message, ok := requestMessage(c, req.Message)
+		if !ok {
+			return
+		}
+		access, ok := loadGroup(c)
+		if !ok {
+			return
+		}
+		if access.role != "" {
+			c.JSON(http.StatusConflict, model.JoinGroupResponseDTO{
+				BaseResp: model.BaseResp{Code: model.GroupAlreadyMember, ErrMsg: "已经是读书会成员"},
+			})
+			return
+		}
+
+		group := access.group
+		invited := access.request != nil && access.request.Type == model.GroupInvitation
+		if group.Visibility == model.GroupPublic || invited {
+			addMember(c, group.Id, currentUser.Id)
+			return
+		}
+
+		// 私密读书会: 提交或更新申请
+		if err := db.GetGroupRepository().SaveRequest(&model.GroupRequestDO{
+			GroupId:    group.Id,
+			UserId:     currentUser.Id,
+			Type:       model.GroupJoinRequest,
+			Message:    message,
+			CreateTime: time.Now(),
+		}); err != nil {
+			log.GetLogger().Errorf("提交读书会申请失败, group=%d, user=%d: %v", group.Id, currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.JoinGroupResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusAccepted, model.JoinGroupResponseDTO{Pending: model.GroupJoinRequest})
+	}
+}
+
+// LeaveGroup 退出读书会, 创建者需要先转让或解散读书会
+func LeaveGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		access, ok := loadGroup(c)
+		if !ok {
+			return
+		}
+		switch access.role {
+		case "":
+			c.JSON(http.StatusForbidden, model.BaseResp{Code: model.GroupMembersOnly, ErrMsg: "你不是读书会成员"})
+			return
+		case model.GroupOwner:
+			c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.GroupOwnerCannotLeave, ErrMsg: "创建者不能退出, 请先转让或解散读书会"})
+			return
+		}
+
+		if _, err := db.GetGroupRepository().RemoveMember(access.group.Id, currentUser.Id); err != nil {
+			log.GetLogger().Errorf("退出读书会失败, group=%d, user=%d: %v", access.group.Id, currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// ListRequests 获取读书会待处理的加入申请和已发出的邀请, 创建者和管理员可以查看
+func ListRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		access, ok := authorizeGroup(c, false)
+		if !ok {
+			return
+		}
+
+		requests, err := db.GetGroupRepository().ListRequests(access.group.Id)
+		if err != nil {
+			log.GetLogger().Errorf("查询读书会申请失败, group=%d: %v", access.group.Id, err)
+			c.JSON(http.StatusInternalServerError, model.GroupRequestListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		result, err := renderRequests(requests, nil)
+		if err != nil {
+			log.GetLogger().Errorf("加载读书会申请失败, group=%d: %v", access.group.Id, err)
+			c.JSON(http.StatusInternalServerError, model.GroupRequestListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.GroupRequestListResponseDTO{Requests: result})
+	}
+}
+
+// ListInvitations 获取当前用户收到的读书会邀请
+func ListInvitations() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+
+		groupRepository := db.GetGroupRepository()
+		requests, err := groupRepository.ListInvitations(currentUser.Id)
+		if err != nil {
+			log.GetLogger().Errorf("查询读书会邀请失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.GroupRequestListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		groupIds := make([]int64, len(requests))
+		for i, request := range requests {
+			groupIds[i] = request.GroupId
+		}
+		groupMap, err := groupRepository.BatchGetGroups(groupIds)
+		if err != nil {
+			log.GetLogger().Errorf("查询读书会失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.GroupRequestListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		groups := make([]*model.GroupDO, 0, len(groupMap))
+		for _, group := range groupMap {
+			groups = append(groups, group)
+		}
+		rendered, err := renderGroups(groups, currentUser.Id)
+		if err != nil {
+			log.GetLogger().Errorf("加载读书会信息失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.GroupRequestListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		groupDTOs := make(map[int64]*model.GroupDTO, len(rendered))
+		for _, group := range rendered {
+			groupDTOs[group.Id] = group
+		}
+
+		result, err := renderRequests(requests, groupDTOs)
+		if err != nil {
+			log.GetLogger().Errorf("加载读书会邀请失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.GroupRequestListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.GroupRequestListResponseDTO{Requests: result})
+	}
+}
+
+// ApproveRequest 批准用户的加入申请, 创建者和管理员可以操作
+func ApproveRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := pathUserId(c)
+		if !ok {
+			return
+		}
+		access, ok := authorizeGroup(c, false)
+		if !ok {
+			return
+		}
+
+		request, err := db.GetGroupRepository().GetRequest(access.group.Id, userId)
+		if err != nil {
+			log.GetLogger().Errorf("查询读书会申请失败, group=%d, user=%d: %v", access.group.Id, userId, err)
+			c.JSON(http.StatusInternalServerError, model.JoinGroupResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		if request == nil || request.Type != model.GroupJoinRequest {
+			c.JSON(http.StatusNotFound, model.JoinGroupResponseDTO{
+				BaseResp: model.BaseResp{Code: model.GroupRequestNotExists, ErrMsg: "没有待处理的申请"},
+			})
+			return
+		}
+		addMember(c, access.group.Id, userId)
+	}
+}
+
+// DeleteRequest 删除待处理的申请或邀请: 创建者和管理员可以拒绝申请、撤回邀请, 本人可以撤回申请、拒绝邀请
+func DeleteRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := pathUserId(c)
+		if !ok {
+			return
+		}
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		var access *groupAccess
+		if userId == currentUser.Id {
+			access, ok = loadGroup(c)
+		} else {
+			access, ok = authorizeGroup(c, false)
+		}
+		if !ok {
+			return
+		}
+
+		deleted, err := db.GetGroupRepository().DeleteRequest(access.group.Id, userId)
+		if err != nil {
+			log.GetLogger().Errorf("删除读书会申请失败, group=%d, user=%d: %v", access.group.Id, userId, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		if !deleted {
+			c.JSON(http.StatusNotFound, model.BaseResp{Code: model.GroupRequestNotExists, ErrMsg: "没有待处理的申请或邀请"})
+			return
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// InviteMember 邀请用户加入读书会, 创建者和管理员可以操作; 对方已经申请过时直接批准
+// 被对方拉黑时不能邀请
+func InviteMember() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.InviteGroupMemberRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		message, ok := requestMessage(c, req.Message)
+		if !ok {
+			return
+		}
+		access, ok := authorizeGroup(c, false)
+		if !ok {
+			return
+		}
+		currentUser, _ := auth.GetCurrentUser(c)
+
+		if _, err := db.GetUserRepository().GetUserById(req.UserId); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, model.BaseResp{Code: model.UserNotExists, ErrMsg: "用户不存在"})
+			} else {
+				log.GetLogger().Errorf("查询用户失败, id=%d: %v", req.UserId, err)
+				c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			}
+			return
+		}
+		groupRepository := db.GetGroupRepository()
+		role, err := groupRepository.GetRole(access.group.Id, req.UserId)
+		if err != nil {
+			log.GetLogger().Errorf("查询读书会成员失败, group=%d, user=%d: %v", access.group.Id, req.UserId, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		if role != "" {
+			c.JSON(http.StatusConflict, model.JoinGroupResponseDTO{
+				BaseResp: model.BaseResp{Code: model.GroupAlreadyMember, ErrMsg: "对方已经是读书会成员"},
+			})
+			return
+		}
+		blocked, err := db.GetRelationRepository().IsBlocked(req.UserId, currentUser.Id)
+		if err != nil {
+			log.GetLogger().Errorf("查询拉黑关系失败, user=%d, target=%d: %v", req.UserId, currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		if blocked {
+			c.JSON(http.StatusForbidden, model.BaseResp{Code: model.BlockedByUser, ErrMsg: "对方已将你拉黑"})
+			return
+		}
+
+		request, err := groupRepository.GetRequest(access.group.Id, req.UserId)
+		if err != nil {
+			log.GetLogger().Errorf("查询读书会申请失败, group=%d, user=%d: %v", access.group.Id, req.UserId, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		if request != nil && request.Type == model.GroupJoinRequest {
+			addMember(c, access.group.Id, req.UserId)
+			return
+		}
+		if err = groupRepository.SaveRequest(&model.GroupRequestDO{
+			GroupId:    access.group.Id,
+			UserId:     req.UserId,
+			Type:       model.GroupInvitation,
+			InviterId:  currentUser.Id,
+			Message:    message,
+			CreateTime: time.Now(),
+		}); err != nil {
+			log.GetLogger().Errorf("邀请加入读书会失败, group=%d, user=%d: %v", access.group.Id, req.UserId, err)
+			c.JSON(http.StatusInternalServerError, model.JoinGroupResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusAccepted, model.JoinGroupResponseDTO{Pending: model.GroupInvitation})
+	}
+}
+
+// SetMemberRole 修改成员的角色, 只有创建者可以操作; 设为 owner 表示转让读书会, 创建者自己变为管理员
+func SetMemberRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.SetGroupRoleRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		userId, ok := pathUserId(c)
+		if !ok {
+			return
+		}
+		if !req.Role.Valid() {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.GroupRoleInvalid, ErrMsg: "角色只能是 owner、admin 或 member"})
+			return
+		}
+		access, ok := authorizeGroup(c, true)
+		if !ok {
+			return
+		}
+		if userId == access.group.OwnerId {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.GroupRoleInvalid, ErrMsg: "不能修改创建者的角色, 请转让给其他成员"})
+			return
+		}
+
+		groupRepository := db.GetGroupRepository()
+		role, err := groupRepository.GetRole(access.group.Id, userId)
+		if err != nil {
+			log.GetLogger().Errorf("查询读书会成员失败, group=%d, user=%d: %v", access.group.Id, userId, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		if role == "" {
+			c.JSON(http.StatusNotFound, model.BaseResp{Code: model.GroupMembersOnly, ErrMsg: "对方不是读书会成员"})
+			return
+		}
+
+		if req.Role == model.GroupOwner {
+			err = groupRepository.TransferOwnership(access.group.Id, access.group.OwnerId, userId)
+		} else {
+			err = groupRepository.SetRole(access.group.Id, userId, req.Role)
+		}
+		if err != nil {
+			log.GetLogger().Errorf("修改读书会成员角色失败, group=%d, user=%d: %v", access.group.Id, userId, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// RemoveMember 把成员移出读书会: 创建者可以移除任何成员, 管理员只能移除普通成员
+func RemoveMember() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := pathUserId(c)
+		if !ok {
+			return
+		}
+		access, ok := authorizeGroup(c, false)
+		if !ok {
+			return
+		}
+
+		groupRepository := db.GetGroupRepository()
+		role, err := groupRepository.GetRole(access.group.Id, userId)
+		if err != nil {
+			log.GetLogger().Errorf("查询读书会成员失败, group=%d, user=%d: %v", access.group.Id, userId, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		switch {
+		case role == "":
+			c.JSON(http.StatusNotFound, model.BaseResp{Code: model.GroupMembersOnly, ErrMsg: "对方不是读书会成员"})
+			return
+		case role == model.GroupOwner:
+			c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.GroupOwnerCannotLeave, ErrMsg: "不能移除创建者"})
+			return
+		case role == model.GroupAdmin && access.role != model.GroupOwner:
+			c.JSON(http.StatusForbidden, model.BaseResp{Code: model.GroupPermissionDenied, ErrMsg: "只有创建者可以移除管理员"})
+			return
+		}
+
+		if _, err = groupRepository.RemoveMember(access.group.Id, userId); err != nil {
+			log.GetLogger().Errorf("移除读书会成员失败, group=%d, user=%d: %v", access.group.Id, userId, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// addMember 把用户作为普通成员加入读书会并写入响应, 同时删除其待处理的申请或邀请
+func addMember(c *gin.Context, groupId, userId int64) {
+	if _, err := db.GetGroupRepository().AddMember(groupId, userId, model.GroupMember); err != nil {
+		log.GetLogger().Errorf("加入读书会失败, group=%d, user=%d: %v", groupId, userId, err)
+		c.JSON(http.StatusInternalServerError, model.JoinGroupResponseDTO{
+			BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, model.JoinGroupResponseDTO{Joined: true})
+}
+
+// requestMessage 校验申请或邀请的附言, 过长时已写入响应
+func requestMessage(c *gin.Context, message string) (string, bool) {
+	message = strings.TrimSpace(message)
+	if utf8.RuneCountInString(message) > model.MaxGroupRequestMessageLen {
+		c.JSON(http.StatusBadRequest, model.JoinGroupResponseDTO{
+			BaseResp: model.BaseResp{Code: model.GroupInfoInvalid, ErrMsg: "附言过长"},
+		})
+		return "", false
+	}
+	return message, true
+}
+
+// renderRequests 批量加载申请人和邀请人, groups 不为 nil 时带上读书会信息; 已注销的用户显示为占位用户
+func renderRequests(requests []*model.GroupRequestDO, groups map[int64]*model.GroupDTO) ([]*model.GroupRequestDTO, error) {
+	userIds := make([]int64, 0, len(requests)*2)
+	for _, request := range requests {
+		userIds = append(userIds, request.UserId)
+		if request.InviterId != 0 {
+			userIds = append(userIds, request.InviterId)
+		}
+	}
+	users, err := db.GetUserRepository().BatchGetUsers(userIds)
+	if err != nil {
+		return nil, err
+	}
+	user := func(id int64) *model.UserDTO {
+		if u, ok := users[id]; ok {
+			return u
+		}
+		return model.DeletedUser()
+	}
+
+	result := make([]*model.GroupRequestDTO, 0, len(requests))
+	for _, request := range requests {
+		dto := &model.GroupRequestDTO{
+			Id:         request.Id,
+			User:       user(request.UserId),
+			Type:       request.Type,
+			Message:    request.Message,
+			CreateTime: request.CreateTime,
+		}
+		if request.Type == model.GroupInvitation {
+			dto.Inviter = user(request.InviterId)
+		}
+		if groups != nil {
+			group, ok := groups[request.GroupId]
+			if !ok {
+				continue
+			}
+			dto.Group = group
+		}
+		result = append(result, dto)
+	}
+	return result, nil
+}
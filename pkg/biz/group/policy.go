@@ -0,0 +1,100 @@
+package group
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+)
+
+// groupAccess 当前用户看到的读书会, 以及他在其中的角色和待处理的申请或邀请
+type groupAccess struct {
+	group   *model.GroupDO
+	role    model.GroupRole
+	request *model.GroupRequestDO
+}
+
+// loadGroup 加载路径参数 id 对应的读书会及当前用户在其中的身份
+// 只邀请的读书会对非成员且没有受邀的人隐藏; 不存在或看不到时请求已被中断, 返回 false
+func loadGroup(c *gin.Context) (*groupAccess, bool) {
+	groupId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+		return nil, false
+	}
+
+	groupRepository := db.GetGroupRepository()
+	group, err := groupRepository.GetGroup(groupId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			abortGroupNotExists(c)
+		} else {
+			abortInternalError(c, "查询读书会失败, group=%d: %v", groupId, err)
+		}
+		return nil, false
+	}
+
+	access := &groupAccess{group: group}
+	if currentUser, ok := auth.GetCurrentUser(c); ok {
+		if access.role, err = groupRepository.GetRole(groupId, currentUser.Id); err != nil {
+			abortInternalError(c, "查询读书会成员失败, group=%d: %v", groupId, err)
+			return nil, false
+		}
+		if access.role == "" {
+			if access.request, err = groupRepository.GetRequest(groupId, currentUser.Id); err != nil {
+				abortInternalError(c, "查询读书会申请失败, group=%d: %v", groupId, err)
+				return nil, false
+			}
+		}
+	}
+	if group.Visibility == model.GroupInviteOnly && access.role == "" &&
+		(access.request == nil || access.request.Type != model.GroupInvitation) {
+		abortGroupNotExists(c)
+		return nil, false
+	}
+	return access, true
+}
+
+// authorizeGroup 加载读书会并校验当前用户是创建者或管理员, ownerOnly 为 true 时只允许创建者
+func authorizeGroup(c *gin.Context, ownerOnly bool) (*groupAccess, bool) {
+	access, ok := loadGroup(c)
+	if !ok {
+		return nil, false
+	}
+	if access.role == model.GroupOwner || (!ownerOnly && access.role.CanManage()) {
+		return access, true
+	}
+	errMsg := "只有读书会的创建者或管理员可以操作"
+	if ownerOnly {
+		errMsg = "只有读书会的创建者可以操作"
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, model.BaseResp{Code: model.GroupPermissionDenied, ErrMsg: errMsg})
+	return nil, false
+}
+
+// pathUserId 解析路径参数 userId, 不合法时请求已被中断, 返回 false
+func pathUserId(c *gin.Context) (int64, bool) {
+	userId, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+	return userId, true
+}
+
+func abortGroupNotExists(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusNotFound, model.BaseResp{Code: model.GroupNotExists, ErrMsg: "读书会不存在"})
+}
+
+// abortInternalError 记录错误日志并以500中断请求, 不把内部错误返回给客户端
+func abortInternalError(c *gin.Context, format string, args ...interface{}) {
+	log.GetLogger().Errorf(format, args...)
+	c.AbortWithStatusJSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+}
@@ -0,0 +1,173 @@
+package mention
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/biz/notification"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+// 用户名补全的返回数量
+const (
+	suggestDefaultLimit = 10
+	suggestMaxLimit     = 20
+)
+
+// Sync 解析 content 中的 @用户名, 保存为 post 中内容 subjectId 的提及, 并通知这次新被提及的人
+// 提及按用户ID保存, 改名后仍指向同一个人; 看不到该帖子的人(非成员被提到私密读书会的帖子)只保存提及, 不发通知
+// notified 中的人已经因为同一操作收到了其他通知(例如被回复), 不再重复通知; 失败只记日志, 不影响发帖和评论
+func Sync(post *model.PostDO, subject model.MentionSubject, subjectId, authorId int64, content string, notified ...int64) {
+	mentions := resolve(content)
+	now := time.Now()
+	for _, mention := range mentions {
+		mention.Subject = subject
+		mention.SubjectId = subjectId
+		mention.PostId = post.Id
+		mention.AuthorId = authorId
+		mention.CreateTime = now
+	}
+
+	added, err := db.GetMentionRepository().ReplaceMentions(subject, subjectId, mentions)
+	if err != nil {
+		log.GetLogger().Errorf("保存提及失败, subject=%s, id=%d: %v", subject, subjectId, err)
+		return
+	}
+
+	notifyType := model.NotifyPostMention
+	if subject == model.MentionInPostComment {
+		notifyType = model.NotifyCommentMention
+	}
+	for _, userId := range added {
+		if containsId(notified, userId) {
+			continue
+		}
+		visible, err := db.GetGroupRepository().CanViewPosts(post.GroupId, userId)
+		if err != nil {
+			log.GetLogger().Errorf("查询读书会成员失败, group=%d, user=%d: %v", post.GroupId, userId, err)
+			continue
+		}
+		if !visible {
+			continue
+		}
+		notification.Notify(&model.NotificationDO{
+			UserId:    userId,
+			Type:      notifyType,
+			SubjectId: subjectId,
+			PostId:    post.Id,
+			Preview:   content,
+		}, authorId)
+	}
+}
+
+// resolve 把文本中的 @用户名 解析为用户, 不存在的用户名按普通文字处理
+// 只处理前 model.MaxMentionedUsers 个不同的用户名
+func resolve(content string) []*model.MentionDO {
+	cache := make(map[string]*mentionedUser)
+	var mentions []*model.MentionDO
+	for _, token := range utils.ParseMentions(content) {
+		user, seen := cache[token.Name]
+		if !seen {
+			if len(cache) >= model.MaxMentionedUsers {
+				continue
+			}
+			user = lookup(token.Name)
+			cache[token.Name] = user
+		}
+		if user == nil {
+			continue
+		}
+		mentions = append(mentions, &model.MentionDO{
+			UserId: user.id,
+			Start:  token.Start,
+			Length: 1 + utf8.RuneCountInString(user.name),
+		})
+	}
+	return mentions
+}
+
+// mentionedUser 解析出的被提及的人, name 是文本中实际匹配到的用户名
+type mentionedUser struct {
+	id   int64
+	name string
+}
+
+// lookup 按用户名查找被提及的人, 找不到时返回 nil
+// 文本中的用户名后可能紧跟句末标点或中文正文(如 "@张三你好"), 按 utils.MentionNameCandidates
+// 从长到短尝试, 取最长的存在的用户名; 用户名有唯一索引, 结果是确定的
+func lookup(name string) *mentionedUser {
+	candidates := utils.MentionNameCandidates(name, model.MaxUserNameLen)
+	if len(candidates) == 0 {
+		return nil
+	}
+	users, err := db.GetUserRepository().BatchGetUsersByNames(candidates)
+	if err != nil {
+		log.GetLogger().Errorf("查询被提及的用户失败, name=%s: %v", name, err)
+		return nil
+	}
+	// 数据库的排序规则可能不区分大小写, 返回的用户名与候选不一定逐字相同
+	for _, candidate := range candidates {
+		for userName, user := range users {
+			if strings.EqualFold(userName, candidate) {
+				return &mentionedUser{id: user.Id, name: candidate}
+			}
+		}
+	}
+	return nil
+}
+
+func containsId(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// SuggestUsers 按用户名前缀补全, 供客户端输入 @ 时提示; 结果按用户名排序, 不包括拉黑了当前用户的人
+func SuggestUsers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		prefix := strings.TrimPrefix(strings.TrimSpace(c.Query("prefix")), "@")
+		if prefix == "" {
+			c.JSON(http.StatusBadRequest, model.UserSuggestionListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("prefix is required")},
+			})
+			return
+		}
+		_, limit, ok := utils.ParsePage("", c.Query("limit"), suggestDefaultLimit, suggestMaxLimit)
+		if !ok {
+			c.JSON(http.StatusBadRequest, model.UserSuggestionListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid limit")},
+			})
+			return
+		}
+
+		users, err := db.GetUserRepository().SearchUsersByNamePrefix(prefix, currentUser.Id, limit)
+		if err != nil {
+			log.GetLogger().Errorf("按前缀查询用户失败, prefix=%s: %v", prefix, err)
+			c.JSON(http.StatusInternalServerError, model.UserSuggestionListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.UserSuggestionListResponseDTO{
+			BaseResp: model.BaseResp{Code: model.Success},
+			Users:    users,
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package mention
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/model"
+)
+
+func TestLookup(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.UseDB(database)
+	ids := make(map[string]int64)
+	for _, name := range []string{"john", "alice", "bob", "bob.x", "张三", "Tom"} {
+		if ids[name], err = db.GetUserRepository().CreateUser(&model.UserDTO{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		wantUser string // 为空表示不应解析为任何人
+		wantName string
+	}{
+		{name: "john", wantUser: "john", wantName: "john"},
+		{name: "johnny", wantUser: ""},
+		{name: "alice_1", wantUser: ""},
+		{name: "bob.", wantUser: "bob", wantName: "bob"},
+		{name: "bob.x", wantUser: "bob.x", wantName: "bob.x"},
+		{name: "bob.y", wantUser: ""},
+		{name: "张三你好", wantUser: "张三", wantName: "张三"},
+		{name: "张三丰", wantUser: "张三", wantName: "张三"},
+		{name: "Tom你好", wantUser: "Tom", wantName: "Tom"},
+		{name: "Tommy你好", wantUser: ""},
+		{name: "nobody", wantUser: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lookup(tt.name)
+			if tt.wantUser == "" {
+				if got != nil {
+					t.Fatalf("lookup(%q) = %+v, want nil", tt.name, *got)
+				}
+				return
+			}
+			if got == nil || got.id != ids[tt.wantUser] || got.name != tt.wantName {
+				t.Fatalf("lookup(%q) = %+v, want id %d name %q", tt.name, got, ids[tt.wantUser], tt.wantName)
+			}
+		})
+	}
+}
@@ -0,0 +1,402 @@
+package message
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/biz/realtime"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+// 会话和消息列表的分页参数
+const (
+	conversationDefaultLimit = 20
+	conversationMaxLimit     = 50
+	messageDefaultLimit      = 30
+	messageMaxLimit          = 100
+)
+
+// SendToUser 给路径中的用户发私信, 两人之间还没有会话时创建会话
+func SendToUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		recipientId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		send(c, currentUser, recipientId)
+	}
+}
+
+// SendMessage 在路径中的会话里给对方发私信
+func SendMessage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, member, ok := loadMember(c)
+		if !ok {
+			return
+		}
+		send(c, currentUser, member.PeerId)
+	}
+}
+
+// send 校验内容和私信权限后发送, 并把消息推送给双方
+// 推给发送方是为了让同一账号的其他设备也能看到这条消息
+func send(c *gin.Context, sender *model.UserDTO, recipientId int64) {
+	var req model.SendMessageRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	content := strings.TrimSpace(req.Content)
+	if content == "" || utf8.RuneCountInString(content) > model.MaxMessageLen {
+		c.JSON(http.StatusBadRequest, model.SendMessageResponseDTO{
+			BaseResp: model.BaseResp{Code: model.MessageInvalid, ErrMsg: "私信内容不能为空, 且不能超过2000字"},
+		})
+		return
+	}
+	if !checkCanMessage(c, sender.Id, recipientId) {
+		return
+	}
+
+	message, err := db.GetMessageRepository().SendMessage(&model.MessageDTO{
+		SenderId:    sender.Id,
+		RecipientId: recipientId,
+		Content:     content,
+	})
+	if err != nil {
+		log.GetLogger().Errorf("发送私信失败, sender=%d, recipient=%d: %v", sender.Id, recipientId, err)
+		c.JSON(http.StatusInternalServerError, model.SendMessageResponseDTO{
+			BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+		})
+		return
+	}
+
+	messageDTO := message.TransformToDTO(0)
+	realtime.Publish(realtime.MessagesTopic(recipientId), realtime.EventMessage, messageDTO, sender.Id)
+	realtime.Publish(realtime.MessagesTopic(sender.Id), realtime.EventMessage, messageDTO, sender.Id)
+	c.JSON(http.StatusOK, model.SendMessageResponseDTO{Message: messageDTO})
+}
+
+// checkCanMessage 检查 senderId 能否给 recipientId 发私信, 不能时写入错误响应
+// 双方任一方拉黑了对方都不能发送; 接收方的私信设置只限制发起会话, 接收方给发送方发过消息后总是可以回复
+func checkCanMessage(c *gin.Context, senderId, recipientId int64) bool {
+	if senderId == recipientId {
+		c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.CannotMessageSelf, ErrMsg: "不能给自己发私信"})
+		return false
+	}
+	recipient, err := db.GetUserRepository().GetUserById(recipientId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, model.BaseResp{Code: model.UserNotExists, ErrMsg: "用户不存在"})
+		} else {
+			log.GetLogger().Errorf("查询用户失败, id=%d: %v", recipientId, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+		}
+		return false
+	}
+
+	allowed, code, err := canMessage(senderId, recipient)
+	if err != nil {
+		log.GetLogger().Errorf("检查私信权限失败, sender=%d, recipient=%d: %v", senderId, recipientId, err)
+		c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+		return false
+	}
+	if !allowed {
+		errMsg := "对方的私信设置不允许你发起会话"
+		switch code {
+		case model.BlockedByUser:
+			errMsg = "对方已将你拉黑"
+		case model.MessageTargetBlocked:
+			errMsg = "你已拉黑对方, 解除拉黑后才能发私信"
+		}
+		c.JSON(http.StatusForbidden, model.BaseResp{Code: code, ErrMsg: errMsg})
+		return false
+	}
+	return true
+}
+
+// canMessage 按拉黑关系和接收方的私信设置判断能否发送, 不能发送时返回对应的错误码
+func canMessage(senderId int64, recipient *model.UserDTO) (bool, model.ErrorCode, error) {
+	relationRepository := db.GetRelationRepository()
+	blocked, err := relationRepository.IsBlocked(recipient.Id, senderId)
+	if err != nil {
+		return false, 0, err
+	}
+	if blocked {
+		return false, model.BlockedByUser, nil
+	}
+	if blocked, err = relationRepository.IsBlocked(senderId, recipient.Id); err != nil {
+		return false, 0, err
+	}
+	if blocked {
+		return false, model.MessageTargetBlocked, nil
+	}
+
+	switch recipient.MessagePolicy {
+	case "", model.MessageEveryone:
+		return true, 0, nil
+	case model.MessageFollowing:
+		status, err := db.GetFollowRepository().GetFollowStatus(recipient.Id, senderId)
+		if err != nil {
+			return false, 0, err
+		}
+		if status.Following {
+			return true, 0, nil
+		}
+	}
+
+	replied, err := db.GetMessageRepository().HasSentTo(recipient.Id, senderId)
+	if err != nil {
+		return false, 0, err
+	}
+	if replied {
+		return true, 0, nil
+	}
+	return false, model.MessageNotAllowed, nil
+}
+
+// ListConversations 按最近消息倒序获取当前用户的会话, cursor 为上一页最后一个会话的 last_message_id
+// 对方已注销的会话 peer 为空
+func ListConversations() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		cursor, limit, ok := parseCursor(c, conversationDefaultLimit, conversationMaxLimit)
+		if !ok {
+			return
+		}
+
+		messageRepository := db.GetMessageRepository()
+		members, err := messageRepository.ListConversations(currentUser.Id, cursor, limit)
+		if err != nil {
+			log.GetLogger().Errorf("查询会话列表失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.ConversationListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		result, err := renderConversations(currentUser.Id, members)
+		if err != nil {
+			log.GetLogger().Errorf("加载会话详情失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.ConversationListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+
+		resp := model.ConversationListResponseDTO{Conversations: result}
+		if len(members) == limit {
+			resp.NextCursor = strconv.FormatInt(members[len(members)-1].LastMessageId, 10)
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// renderConversations 批量加载会话的对方、最新消息和对方的已读位置
+func renderConversations(userId int64, members []*model.ConversationMemberDO) ([]*model.ConversationDTO, error) {
+	conversationIds := make([]int64, len(members))
+	peerIds := make([]int64, len(members))
+	messageIds := make([]int64, 0, len(members))
+	for i, member := range members {
+		conversationIds[i] = member.ConversationId
+		peerIds[i] = member.PeerId
+		if member.LastMessageId != 0 {
+			messageIds = append(messageIds, member.LastMessageId)
+		}
+	}
+	peers, err := db.GetUserRepository().BatchGetUsers(peerIds)
+	if err != nil {
+		return nil, err
+	}
+	messageRepository := db.GetMessageRepository()
+	messages, err := messageRepository.BatchGetMessages(messageIds)
+	if err != nil {
+		return nil, err
+	}
+	peerLastRead, err := messageRepository.BatchGetPeerLastRead(conversationIds, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.ConversationDTO, len(members))
+	for i, member := range members {
+		conversation := &model.ConversationDTO{
+			Id:                    member.ConversationId,
+			Peer:                  peers[member.PeerId],
+			UnreadCount:           member.UnreadCount,
+			LastReadMessageId:     member.LastReadMessageId,
+			PeerLastReadMessageId: peerLastRead[member.ConversationId],
+			UpdateTime:            member.UpdateTime,
+		}
+		if message, ok := messages[member.LastMessageId]; ok {
+			conversation.LastMessage = message.TransformToDTO(recipientLastRead(message, userId, member.LastReadMessageId, conversation.PeerLastReadMessageId))
+		}
+		result[i] = conversation
+	}
+	return result, nil
+}
+
+// ListMessages 按ID倒序获取会话中的消息, 只有会话成员可以查看
+func ListMessages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, member, ok := loadMember(c)
+		if !ok {
+			return
+		}
+		cursor, limit, ok := parseCursor(c, messageDefaultLimit, messageMaxLimit)
+		if !ok {
+			return
+		}
+
+		messageRepository := db.GetMessageRepository()
+		messages, err := messageRepository.ListMessages(member.ConversationId, cursor, limit)
+		if err != nil {
+			log.GetLogger().Errorf("查询私信失败, conversation=%d: %v", member.ConversationId, err)
+			c.JSON(http.StatusInternalServerError, model.MessageListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		peerLastRead, err := messageRepository.GetPeerLastRead(member.ConversationId, currentUser.Id)
+		if err != nil {
+			log.GetLogger().Errorf("查询对方已读位置失败, conversation=%d: %v", member.ConversationId, err)
+			c.JSON(http.StatusInternalServerError, model.MessageListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+
+		result := make([]*model.MessageDTO, len(messages))
+		for i, message := range messages {
+			result[i] = message.TransformToDTO(recipientLastRead(message, currentUser.Id, member.LastReadMessageId, peerLastRead))
+		}
+		resp := model.MessageListResponseDTO{Messages: result, PeerLastReadMessageId: peerLastRead}
+		if len(messages) == limit {
+			resp.NextCursor = strconv.FormatInt(messages[len(messages)-1].Id, 10)
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// recipientLastRead 返回消息接收方已读到的位置, 接收方是当前用户时取自己的, 否则取对方的
+func recipientLastRead(message *model.MessageDO, userId, ownLastRead, peerLastRead int64) int64 {
+	if message.RecipientId == userId {
+		return ownLastRead
+	}
+	return peerLastRead
+}
+
+// MarkConversationRead 把路径中的会话标记为已读, 请求体可以省略, 省略时标记到最新一条
+// 已读位置变化后推送已读回执给对方, 同时推给自己的其他设备以同步未读数
+func MarkConversationRead() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, member, ok := loadMember(c)
+		if !ok {
+			return
+		}
+		var req model.MarkConversationReadRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		updated, err := db.GetMessageRepository().MarkRead(member.ConversationId, currentUser.Id, req.MessageId)
+		if err != nil {
+			log.GetLogger().Errorf("标记私信已读失败, conversation=%d, user=%d: %v", member.ConversationId, currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		if updated.LastReadMessageId != member.LastReadMessageId {
+			receipt := &model.ConversationReadDTO{
+				ConversationId:    member.ConversationId,
+				UserId:            currentUser.Id,
+				LastReadMessageId: updated.LastReadMessageId,
+			}
+			realtime.Publish(realtime.MessagesTopic(member.PeerId), realtime.EventMessagesRead, receipt, currentUser.Id)
+			realtime.Publish(realtime.MessagesTopic(currentUser.Id), realtime.EventMessagesRead, receipt, currentUser.Id)
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// GetUnreadCount 获取当前用户全部会话的未读私信数
+func GetUnreadCount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		unread, err := db.GetMessageRepository().CountUnread(currentUser.Id)
+		if err != nil {
+			log.GetLogger().Errorf("统计未读私信失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.UnreadCountResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.UnreadCountResponseDTO{UnreadCount: unread})
+	}
+}
+
+// loadMember 获取当前用户在路径中的会话里的状态, 不是会话成员时按会话不存在处理
+func loadMember(c *gin.Context) (*model.UserDTO, *model.ConversationMemberDO, bool) {
+	currentUser, ok := auth.GetCurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+		return nil, nil, false
+	}
+	conversationId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return nil, nil, false
+	}
+	member, err := db.GetMessageRepository().GetMember(conversationId, currentUser.Id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, model.BaseResp{Code: model.ConversationNotExists, ErrMsg: "会话不存在"})
+		} else {
+			log.GetLogger().Errorf("查询会话失败, conversation=%d, user=%d: %v", conversationId, currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+		}
+		return nil, nil, false
+	}
+	return currentUser, member, true
+}
+
+// parseCursor 解析 cursor 和 limit 参数, 不传 cursor 时从最新开始
+func parseCursor(c *gin.Context, defaultLimit, maxLimit int) (int64, int, bool) {
+	var cursor int64
+	if v := c.Query("cursor"); v != "" {
+		var err error
+		if cursor, err = strconv.ParseInt(v, 10, 64); err != nil || cursor <= 0 {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Error: errors.New("invalid cursor")})
+			return 0, 0, false
+		}
+	}
+	_, limit, ok := utils.ParsePage("", c.Query("limit"), defaultLimit, maxLimit)
+	if !ok {
+		c.JSON(http.StatusBadRequest, model.BaseResp{Error: errors.New("invalid limit")})
+		return 0, 0, false
+	}
+	return cursor, limit, true
+}
@@ -0,0 +1,215 @@
+package notification
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/biz/realtime"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+// 通知列表的分页参数和摘要长度, 长度按字符计
+const (
+	notificationDefaultLimit = 20
+	notificationMaxLimit     = 100
+	maxPreviewLen            = 100
+)
+
+// Notify 为 actorId 触发的事件给 notification.UserId 发通知并实时推送, 失败只记日志, 不影响评论、点赞等触发通知的操作
+// 自己触发的、以及接收者拉黑或屏蔽了触发者的事件不发通知
+func Notify(notification *model.NotificationDO, actorId int64) {
+	recipientId := notification.UserId
+	if recipientId == 0 || recipientId == actorId {
+		return
+	}
+	relationRepository := db.GetRelationRepository()
+	blocked, err := relationRepository.IsBlocked(recipientId, actorId)
+	if err == nil && !blocked {
+		blocked, err = relationRepository.IsMuted(recipientId, actorId)
+	}
+	if err != nil {
+		log.GetLogger().Errorf("查询拉黑屏蔽关系失败, user=%d, target=%d: %v", recipientId, actorId, err)
+		return
+	}
+	if blocked {
+		return
+	}
+
+	notification.Preview = utils.Truncate(notification.Preview, maxPreviewLen)
+	saved, err := db.GetNotificationRepository().AddNotification(notification, actorId)
+	if err != nil {
+		log.GetLogger().Errorf("发送通知失败, type=%s, user=%d, actor=%d, subject=%d: %v",
+			notification.Type, recipientId, actorId, notification.SubjectId, err)
+		return
+	}
+	if saved == nil {
+		return
+	}
+
+	// 推送合并后的整条通知, 客户端按ID替换已有的那条
+	rendered, err := RenderNotifications([]*model.NotificationDO{saved})
+	if err != nil {
+		log.GetLogger().Errorf("加载通知触发者失败, id=%d: %v", saved.Id, err)
+		return
+	}
+	realtime.Publish(realtime.NotificationTopic(recipientId), realtime.EventNotification, rendered[0], actorId)
+}
+
+// ListNotifications 分页获取当前用户的通知, unread=true 时只返回未读通知
+func ListNotifications() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		offset, limit, ok := utils.ParsePage(c.Query("offset"), c.Query("limit"), notificationDefaultLimit, notificationMaxLimit)
+		if !ok {
+			c.JSON(http.StatusBadRequest, model.NotificationListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid offset or limit")},
+			})
+			return
+		}
+		unreadOnly := c.Query("unread") == "true"
+
+		notificationRepository := db.GetNotificationRepository()
+		notifications, total, err := notificationRepository.ListNotifications(currentUser.Id, unreadOnly, offset, limit)
+		if err != nil {
+			log.GetLogger().Errorf("查询通知失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.NotificationListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		unread, err := notificationRepository.CountUnread(currentUser.Id)
+		if err != nil {
+			log.GetLogger().Errorf("统计未读通知失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.NotificationListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		result, err := RenderNotifications(notifications)
+		if err != nil {
+			log.GetLogger().Errorf("加载通知触发者失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.NotificationListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.NotificationListResponseDTO{
+			Total:         total,
+			UnreadCount:   unread,
+			Notifications: result,
+		})
+	}
+}
+
+// GetUnreadCount 获取当前用户的未读通知数
+func GetUnreadCount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		unread, err := db.GetNotificationRepository().CountUnread(currentUser.Id)
+		if err != nil {
+			log.GetLogger().Errorf("统计未读通知失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.UnreadCountResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.UnreadCountResponseDTO{UnreadCount: unread})
+	}
+}
+
+// MarkRead 把路径中的通知标为已读
+func MarkRead() gin.HandlerFunc {
+	return setRead(true)
+}
+
+// MarkUnread 把路径中的通知标为未读
+func MarkUnread() gin.HandlerFunc {
+	return setRead(false)
+}
+
+func setRead(read bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+			return
+		}
+
+		// 只能修改自己的通知, 别人的通知按不存在处理
+		if err = db.GetNotificationRepository().SetRead(currentUser.Id, id, read); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, model.BaseResp{Code: model.NotificationNotExists, ErrMsg: "通知不存在"})
+				return
+			}
+			log.GetLogger().Errorf("修改通知已读状态失败, user=%d, id=%d: %v", currentUser.Id, id, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// MarkAllRead 把当前用户的全部未读通知标为已读
+func MarkAllRead() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		updated, err := db.GetNotificationRepository().MarkAllRead(currentUser.Id)
+		if err != nil {
+			log.GetLogger().Errorf("全部标为已读失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.MarkAllReadResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.MarkAllReadResponseDTO{Updated: updated})
+	}
+}
+
+// RenderNotifications 批量加载通知最近的触发者, 已注销的触发者不展示
+func RenderNotifications(notifications []*model.NotificationDO) ([]*model.NotificationDTO, error) {
+	actorIds := make([]int64, 0, len(notifications)*model.MaxRecentActors)
+	for _, notification := range notifications {
+		actorIds = append(actorIds, notification.RecentActors()...)
+	}
+	users, err := db.GetUserRepository().BatchGetUsers(actorIds)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.NotificationDTO, len(notifications))
+	for i, notification := range notifications {
+		var actors []*model.UserDTO
+		for _, id := range notification.RecentActors() {
+			if user, ok := users[id]; ok {
+				actors = append(actors, user)
+			}
+		}
+		result[i] = notification.TransformToDTO(actors)
+	}
+	return result, nil
+}
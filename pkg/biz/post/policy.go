@@ -0,0 +1,130 @@
+package post
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+)
+
+// authorizePost 加载路径参数 id 对应的帖子, 并校验当前用户能否操作它
+// 校验不通过时请求已被中断, 返回 false
+func authorizePost(c *gin.Context, overrides ...model.Permission) (*model.PostDO, bool) {
+	postDO, ok := loadPost(c)
+	if !ok {
+		return nil, false
+	}
+
+	if !auth.AuthorizeOwner(c, postDO.AuthorId, overrides...) {
+		return nil, false
+	}
+	return postDO, true
+}
+
+// authorizePostRemoval 加载路径参数 id 对应的帖子, 并校验当前用户能否删除它
+// 作者本人、有删帖权限的用户, 以及帖子所在读书会的创建者和管理员可以删除
+func authorizePostRemoval(c *gin.Context) (*model.PostDO, bool) {
+	postDO, ok := loadPost(c)
+	if !ok {
+		return nil, false
+	}
+
+	if managesGroup(c, postDO.GroupId) {
+		return postDO, true
+	}
+	if !auth.AuthorizeOwner(c, postDO.AuthorId, model.PermDeleteAnyPost) {
+		return nil, false
+	}
+	return postDO, true
+}
+
+// loadPost 加载路径参数 id 对应的帖子, 不校验归属; 不存在或当前用户看不到时请求已被中断, 返回 false
+func loadPost(c *gin.Context) (*model.PostDO, bool) {
+	postId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return nil, false
+	}
+
+	postDO, err := postBizInstance.postRepo.GetPostDOById(postId)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return nil, false
+	}
+	if !checkGroupAccess(c, postDO.GroupId) {
+		return nil, false
+	}
+	return postDO, true
+}
+
+// authorizePostComment 加载路径参数 commentId 对应的评论及其所在的帖子, 并校验当前用户能否操作该评论
+// 评论必须属于路径参数 id 对应的帖子; groupManagers 为 true 时帖子所在读书会的创建者和管理员也可以操作
+func authorizePostComment(c *gin.Context, groupManagers bool, overrides ...model.Permission) (*model.PostDO, *model.PostCommentDTO, bool) {
+	postDO, comment, ok := loadPostComment(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	if groupManagers && managesGroup(c, postDO.GroupId) {
+		return postDO, comment, true
+	}
+	if !auth.AuthorizeOwner(c, comment.Author.Id, overrides...) {
+		return nil, nil, false
+	}
+	return postDO, comment, true
+}
+
+// loadPostComment 加载路径参数 id 对应的帖子和 commentId 对应的评论, 不校验归属; 评论必须属于该帖子
+func loadPostComment(c *gin.Context) (*model.PostDO, *model.PostCommentDTO, bool) {
+	postDO, ok := loadPost(c)
+	if !ok {
+		return nil, nil, false
+	}
+	commentId, err := strconv.ParseInt(c.Param("commentId"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return nil, nil, false
+	}
+
+	comment, err := postBizInstance.postRepo.GetPostCommentById(commentId)
+	if err != nil || comment.PostId != postDO.Id {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return nil, nil, false
+	}
+	return postDO, comment, true
+}
+
+// checkGroupAccess 校验当前用户能看到读书会 groupId 内的帖子, groupId 为0时直接放行
+// 私密和只邀请的读书会只有成员能看到; 看不到时请求已被中断, 返回 false
+func checkGroupAccess(c *gin.Context, groupId int64) bool {
+	visible, err := db.GetGroupRepository().CanViewPosts(groupId, viewerId(c))
+	if err != nil {
+		log.GetLogger().Errorf("查询读书会成员失败, group=%d: %v", groupId, err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+		return false
+	}
+	if !visible {
+		c.AbortWithStatusJSON(http.StatusForbidden, model.BaseResp{Code: model.GroupMembersOnly, ErrMsg: "只有读书会成员可以查看"})
+		return false
+	}
+	return true
+}
+
+// managesGroup 当前用户是否为读书会 groupId 的创建者或管理员, groupId 为0或查询失败时返回 false
+func managesGroup(c *gin.Context, groupId int64) bool {
+	if groupId == 0 {
+		return false
+	}
+	role, err := db.GetGroupRepository().GetRole(groupId, viewerId(c))
+	if err != nil {
+		log.GetLogger().Errorf("查询读书会成员失败, group=%d: %v", groupId, err)
+		return false
+	}
+	return role.CanManage()
+}
@@ -1,20 +1,29 @@
 package post
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/biz/feed"
+	"yujian-backend/pkg/biz/mention"
+	"yujian-backend/pkg/biz/notification"
+	"yujian-backend/pkg/biz/realtime"
+	"yujian-backend/pkg/biz/reputation"
 	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/es"
 	"yujian-backend/pkg/log"
 	"yujian-backend/pkg/model"
 	"yujian-backend/pkg/utils"
 )
 
-var postBizInstance *PostBiz
+var postBizInstance = &PostBiz{postRepo: db.GetPostRepository()}
 
 // PostBiz 帖子业务逻辑
 type PostBiz struct {
@@ -30,7 +39,18 @@ func CreatePost() gin.HandlerFunc {
 			return
 		}
 
-		// todo 这里外层的逻辑需要修改
+		// 作者取自令牌中的当前用户,不信任请求体
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		req.UserId = currentUser.Id
+		req.UserName = currentUser.Name
+		if req.GroupId != 0 && !checkGroupMember(c, req.GroupId, currentUser.Id) {
+			return
+		}
+
 		resp, err := postBizInstance.CreatePost(&req)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -65,15 +85,17 @@ func (b *PostBiz) CreatePost(req *model.CreatePostRequestDTO) (*model.CreatePost
 		Title:     req.Title,
 		ContentId: contentId,
 		Author: &model.UserDTO{
-			Id: req.UserId,
+			Id:   req.UserId,
+			Name: req.UserName,
 		},
 		EditTime: time.Now(),
 		Comments: []*model.PostCommentDTO{},
+		GroupId:  req.GroupId,
 	}
 
 	// 保存帖子
 	if id, err := b.postRepo.CreatePost(postDTO); err != nil {
-		log.GetLogger().Error("创建帖子失败: %v", err)
+		log.GetLogger().Errorf("创建帖子失败: %v", err)
 		resp.Code = model.UserNotExists
 		resp.ErrMsg = "创建帖子失败"
 		resp.Error = err
@@ -82,9 +104,473 @@ func (b *PostBiz) CreatePost(req *model.CreatePostRequestDTO) (*model.CreatePost
 		resp.PostId = id
 	}
 
+	// 正文以内容ID为文档ID存入ES, 写入失败不影响发帖
+	if err := es.Create(context.Background(), &model.PostEsModel{
+		Id:      contentId,
+		Title:   req.Title,
+		Content: req.Content,
+	}); err != nil {
+		log.GetLogger().Errorf("帖子正文写入ES失败, postId=%d: %v", resp.PostId, err)
+	}
+	mention.Sync(&model.PostDO{Id: resp.PostId, AuthorId: req.UserId, GroupId: req.GroupId},
+		model.MentionInPost, resp.PostId, req.UserId, req.Content)
+
+	// 读书会内的帖子只在读书会里展示, 不进入关注者的动态
+	if req.GroupId == 0 {
+		feed.Publish(&model.ActivityEventDO{
+			ActorId:   req.UserId,
+			Type:      model.ActivityPost,
+			SubjectId: resp.PostId,
+			Title:     req.Title,
+			Summary:   req.Content,
+		})
+	}
+
 	return resp, nil
 }
 
+// checkGroupMember 校验用户是读书会的成员, 不是成员或读书会不存在时已写入响应
+func checkGroupMember(c *gin.Context, groupId, userId int64) bool {
+	groupRepository := db.GetGroupRepository()
+	if _, err := groupRepository.GetGroup(groupId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, model.BaseResp{Code: model.GroupNotExists, ErrMsg: "读书会不存在"})
+		} else {
+			log.GetLogger().Errorf("查询读书会失败, group=%d: %v", groupId, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+		}
+		return false
+	}
+	role, err := groupRepository.GetRole(groupId, userId)
+	if err != nil {
+		log.GetLogger().Errorf("查询读书会成员失败, group=%d, user=%d: %v", groupId, userId, err)
+		c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+		return false
+	}
+	if role == "" {
+		c.JSON(http.StatusForbidden, model.BaseResp{Code: model.GroupMembersOnly, ErrMsg: "只有读书会成员可以发帖"})
+		return false
+	}
+	return true
+}
+
+// UpdatePost 更新帖子的处理函数, 只有作者本人可以修改
+func UpdatePost() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.UpdatePostRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "标题不能为空"})
+			return
+		}
+
+		postDO, ok := authorizePost(c)
+		if !ok {
+			return
+		}
+
+		if err := postBizInstance.UpdatePost(postDO, &req); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Post updated successfully"})
+	}
+}
+
+// DeletePost 删除帖子的处理函数, 作者本人、有删帖权限的用户或帖子所在读书会的管理员可以删除
+func DeletePost() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		postDO, ok := authorizePostRemoval(c)
+		if !ok {
+			return
+		}
+
+		if err := postBizInstance.DeletePost(postDO); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		reputation.PenalizeRemoval(c, model.ReputationPost, postDO.Id, postDO.AuthorId)
+		c.JSON(http.StatusOK, gin.H{"message": "Post deleted successfully"})
+	}
+}
+
+// CreatePostComment 发表帖子评论的处理函数, 被帖子作者拉黑的用户不能评论
+func CreatePostComment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.CreatePostCommentRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Content == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "评论内容不能为空"})
+			return
+		}
+
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		postDO, ok := loadPost(c)
+		if !ok {
+			return
+		}
+
+		blocked, err := db.GetRelationRepository().IsBlocked(postDO.AuthorId, currentUser.Id)
+		if err != nil {
+			log.GetLogger().Errorf("查询拉黑关系失败, user=%d, target=%d: %v", postDO.AuthorId, currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if blocked {
+			c.JSON(http.StatusForbidden, model.CreatePostCommentResponseDTO{
+				BaseResp: model.BaseResp{Code: model.BlockedByUser, ErrMsg: "对方已将你拉黑"},
+			})
+			return
+		}
+
+		// 回复的评论必须在同一个帖子下
+		var replyTo *model.PostCommentDTO
+		if req.ReplyToId != 0 {
+			replyTo, err = postBizInstance.postRepo.GetPostCommentById(req.ReplyToId)
+			if err != nil || replyTo.PostId != postDO.Id {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Reply target not found"})
+				return
+			}
+		}
+
+		commentId, err := postBizInstance.CreatePostComment(postDO, currentUser, &req, replyTo)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, model.CreatePostCommentResponseDTO{CommentId: commentId})
+	}
+}
+
+// UpdatePostComment 更新帖子评论的处理函数, 只有作者本人可以修改
+func UpdatePostComment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.UpdatePostCommentRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Content == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "评论内容不能为空"})
+			return
+		}
+
+		postDO, comment, ok := authorizePostComment(c, false)
+		if !ok {
+			return
+		}
+
+		if err := postBizInstance.UpdatePostComment(postDO, comment, &req); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Comment updated successfully"})
+	}
+}
+
+// DeletePostComment 删除帖子评论的处理函数, 作者本人、有删评论权限的用户或帖子所在读书会的管理员可以删除
+func DeletePostComment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, comment, ok := authorizePostComment(c, true, model.PermDeleteAnyComment)
+		if !ok {
+			return
+		}
+
+		if err := postBizInstance.DeletePostComment(comment); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		reputation.PenalizeRemoval(c, model.ReputationPostComment, comment.Id, comment.Author.Id)
+		c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+	}
+}
+
+// UpdatePost 更新帖子
+func (b *PostBiz) UpdatePost(postDO *model.PostDO, req *model.UpdatePostRequestDTO) error {
+	postDTO := postDO.TransformToDTO(&model.UserDTO{Id: postDO.AuthorId, Name: postDO.AuthorName}, nil)
+	postDTO.Title = req.Title
+	postDTO.EditTime = time.Now()
+	if err := b.postRepo.UpdatePost(postDTO); err != nil {
+		log.GetLogger().Errorf("更新帖子失败: %v", err)
+		return err
+	}
+	return nil
+}
+
+// CreatePostComment 发表帖子评论, replyTo 不为 nil 时是对该评论的回复
+// 评论推送给订阅了该帖子评论流的客户端, 并通知帖子作者有新评论, 回复时还通知被回复的人; 被回复的人就是帖子作者时只发回复通知
+// 评论中 @ 到的其他人收到提及通知
+func (b *PostBiz) CreatePostComment(postDO *model.PostDO, author *model.UserDTO, req *model.CreatePostCommentRequestDTO, replyTo *model.PostCommentDTO) (int64, error) {
+	comment := &model.PostCommentDTO{
+		PostId:   postDO.Id,
+		Author:   model.UserDTO{Id: author.Id, Name: author.Name},
+		EditTime: time.Now(),
+		Content:  req.Content,
+	}
+	if replyTo != nil {
+		comment.ReplyToId = replyTo.Id
+	}
+	id, err := b.postRepo.CreatePostComment(comment)
+	if err != nil {
+		log.GetLogger().Errorf("发表帖子评论失败: %v", err)
+		return 0, err
+	}
+	comment.Id = id
+	realtime.Publish(realtime.PostCommentsTopic(postDO.Id), realtime.EventCommentCreated, comment, author.Id)
+
+	if replyTo != nil {
+		notification.Notify(&model.NotificationDO{
+			UserId:    replyTo.Author.Id,
+			Type:      model.NotifyCommentReply,
+			SubjectId: replyTo.Id,
+			PostId:    postDO.Id,
+			Preview:   req.Content,
+		}, author.Id)
+	}
+	if replyTo == nil || replyTo.Author.Id != postDO.AuthorId {
+		notification.Notify(&model.NotificationDO{
+			UserId:    postDO.AuthorId,
+			Type:      model.NotifyPostComment,
+			SubjectId: postDO.Id,
+			PostId:    postDO.Id,
+			Preview:   req.Content,
+		}, author.Id)
+	}
+	// 帖子作者和被回复的人已经收到了上面的通知, 提到他们时不再重复通知
+	notified := []int64{postDO.AuthorId}
+	if replyTo != nil {
+		notified = append(notified, replyTo.Author.Id)
+	}
+	mention.Sync(postDO, model.MentionInPostComment, id, author.Id, req.Content, notified...)
+	return id, nil
+}
+
+// UpdatePostComment 更新帖子评论, 修改后新提到的人会收到通知
+func (b *PostBiz) UpdatePostComment(postDO *model.PostDO, comment *model.PostCommentDTO, req *model.UpdatePostCommentRequestDTO) error {
+	comment.Content = req.Content
+	comment.EditTime = time.Now()
+	if err := b.postRepo.UpdatePostComment(comment); err != nil {
+		log.GetLogger().Errorf("更新帖子评论失败: %v", err)
+		return err
+	}
+	realtime.Publish(realtime.PostCommentsTopic(comment.PostId), realtime.EventCommentUpdated, comment, comment.Author.Id)
+	mention.Sync(postDO, model.MentionInPostComment, comment.Id, comment.Author.Id, req.Content)
+	return nil
+}
+
+// DeletePost 删除帖子, 并从ES中删除帖子正文
+func (b *PostBiz) DeletePost(postDO *model.PostDO) error {
+	if err := b.postRepo.DeletePost(postDO.Id); err != nil {
+		log.GetLogger().Errorf("删除帖子失败: %v", err)
+		return err
+	}
+	// ES不参与数据库事务, 清理失败只记录日志, 帖子已经删除
+	if postDO.ContentId != "" {
+		if err := es.DeleteDocuments(context.Background(), (&model.PostEsModel{}).GetIndexName(), []string{postDO.ContentId}); err != nil {
+			log.GetLogger().Errorf("清理ES文档失败, postId=%d: %v", postDO.Id, err)
+		}
+	}
+	realtime.Publish(realtime.PostCommentsTopic(postDO.Id), realtime.EventPostDeleted, gin.H{"id": postDO.Id}, 0)
+	return nil
+}
+
+// DeletePostComment 删除帖子评论
+func (b *PostBiz) DeletePostComment(comment *model.PostCommentDTO) error {
+	if err := b.postRepo.DeletePostComment(comment.Id); err != nil {
+		log.GetLogger().Errorf("删除帖子评论失败: %v", err)
+		return err
+	}
+	realtime.Publish(realtime.PostCommentsTopic(comment.PostId), realtime.EventCommentDeleted,
+		gin.H{"id": comment.Id, "post_id": comment.PostId}, comment.Author.Id)
+	return nil
+}
+
 func (b *PostBiz) generateContentId(title string, uid int64) string {
 	return title + strconv.FormatInt(uid, 10) + utils.GenerateUUID()
 }
+
+// 帖子列表的分页参数
+const (
+	postsDefaultLimit = 20
+	postsMaxLimit     = 50
+)
+
+// ListPosts 分页获取帖子列表, 登录用户看不到自己屏蔽的人发布的帖子和评论, 私密读书会的帖子只有成员能看到
+func ListPosts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		offset, limit, ok := utils.ParsePage(c.Query("offset"), c.Query("limit"), postsDefaultLimit, postsMaxLimit)
+		if !ok {
+			c.JSON(http.StatusBadRequest, model.PostListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid offset or limit")},
+			})
+			return
+		}
+
+		posts, err := db.GetPostRepository().ListPosts(viewerId(c), offset, limit)
+		if err != nil {
+			log.GetLogger().Errorf("查询帖子列表失败: %v", err)
+			c.JSON(http.StatusInternalServerError, model.PostListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.PostListResponseDTO{Posts: posts})
+	}
+}
+
+// GetPostComments 获取帖子的评论, 登录用户看不到自己屏蔽的人发表的评论
+func GetPostComments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		postDO, ok := loadPost(c)
+		if !ok {
+			return
+		}
+
+		comments, err := db.GetPostRepository().GetPostCommentsByPostId(postDO.Id, viewerId(c))
+		if err != nil {
+			log.GetLogger().Errorf("查询帖子评论失败, postId=%d: %v", postDO.Id, err)
+			c.JSON(http.StatusInternalServerError, model.PostCommentListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.PostCommentListResponseDTO{Comments: comments})
+	}
+}
+
+// viewerId 当前登录用户的ID, 未登录时为0
+func viewerId(c *gin.Context) int64 {
+	if currentUser, ok := auth.GetCurrentUser(c); ok {
+		return currentUser.Id
+	}
+	return 0
+}
+
+// ListFollowingPosts 分页获取当前用户关注的人发布的帖子, 按发布时间倒序
+func ListFollowingPosts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		offset, limit, ok := utils.ParsePage(c.Query("offset"), c.Query("limit"), postsDefaultLimit, postsMaxLimit)
+		if !ok {
+			c.JSON(http.StatusBadRequest, model.PostListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid offset or limit")},
+			})
+			return
+		}
+
+		posts, err := db.GetPostRepository().ListFollowingPosts(currentUser.Id, offset, limit)
+		if err != nil {
+			log.GetLogger().Errorf("查询关注动态失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.PostListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.PostListResponseDTO{Posts: posts})
+	}
+}
+
+// ListMentionedPosts 分页获取正文或评论中提到了当前用户的帖子, 按发布时间倒序, 分页参数与 ListPosts 相同
+// 不包括当前用户已经看不到的帖子, 例如退出的私密读书会中的帖子
+func ListMentionedPosts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		offset, limit, ok := utils.ParsePage(c.Query("offset"), c.Query("limit"), postsDefaultLimit, postsMaxLimit)
+		if !ok {
+			c.JSON(http.StatusBadRequest, model.PostListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid offset or limit")},
+			})
+			return
+		}
+
+		posts, err := db.GetPostRepository().ListMentionedPosts(currentUser.Id, offset, limit)
+		if err != nil {
+			log.GetLogger().Errorf("查询提到我的帖子失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.PostListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.PostListResponseDTO{Posts: posts})
+	}
+}
+
+// ListGroupPosts 分页获取读书会内的帖子, 分页参数与 ListPosts 相同; 私密和只邀请的读书会只有成员能看到
+func ListGroupPosts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+			return
+		}
+		offset, limit, ok := utils.ParsePage(c.Query("offset"), c.Query("limit"), postsDefaultLimit, postsMaxLimit)
+		if !ok {
+			c.JSON(http.StatusBadRequest, model.PostListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid offset or limit")},
+			})
+			return
+		}
+		group, err := db.GetGroupRepository().GetGroup(groupId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, model.PostListResponseDTO{
+					BaseResp: model.BaseResp{Code: model.GroupNotExists, ErrMsg: "读书会不存在"},
+				})
+			} else {
+				log.GetLogger().Errorf("查询读书会失败, group=%d: %v", groupId, err)
+				c.JSON(http.StatusInternalServerError, model.PostListResponseDTO{
+					BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+				})
+			}
+			return
+		}
+		// 只邀请的读书会对非成员隐藏, 返回不存在而不是无权查看
+		if group.Visibility == model.GroupInviteOnly {
+			role, err := db.GetGroupRepository().GetRole(group.Id, viewerId(c))
+			if err != nil {
+				log.GetLogger().Errorf("查询读书会成员失败, group=%d: %v", group.Id, err)
+				c.JSON(http.StatusInternalServerError, model.PostListResponseDTO{
+					BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+				})
+				return
+			}
+			if role == "" {
+				c.JSON(http.StatusNotFound, model.PostListResponseDTO{
+					BaseResp: model.BaseResp{Code: model.GroupNotExists, ErrMsg: "读书会不存在"},
+				})
+				return
+			}
+		}
+		if !checkGroupAccess(c, group.Id) {
+			return
+		}
+
+		posts, err := db.GetPostRepository().ListGroupPosts(group.Id, viewerId(c), offset, limit)
+		if err != nil {
+			log.GetLogger().Errorf("查询读书会帖子失败, group=%d: %v", group.Id, err)
+			c.JSON(http.StatusInternalServerError, model.PostListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.PostListResponseDTO{Posts: posts})
+	}
+}
@@ -0,0 +1,112 @@
+package post
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/biz/notification"
+	"yujian-backend/pkg/config"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+)
+
+// SetPostReaction 点赞、点踩或取消表态路径中的帖子, 被帖子作者拉黑的用户不能表态
+func SetPostReaction() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		postDO, ok := loadPost(c)
+		if !ok {
+			return
+		}
+		currentUser, reaction, ok := bindReaction(c, postDO.AuthorId)
+		if !ok {
+			return
+		}
+
+		result, err := postBizInstance.postRepo.SetPostReaction(postDO.Id, currentUser.Id, reaction, config.Config.Reputation.Post)
+		if err != nil {
+			log.GetLogger().Errorf("帖子表态失败, post=%d, user=%d: %v", postDO.Id, currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.ReactionResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		if result.Reaction == model.ReactionLike && result.Previous != model.ReactionLike {
+			notification.Notify(&model.NotificationDO{
+				UserId:    postDO.AuthorId,
+				Type:      model.NotifyPostLike,
+				SubjectId: postDO.Id,
+				PostId:    postDO.Id,
+			}, currentUser.Id)
+		}
+		c.JSON(http.StatusOK, model.ReactionResponseDTO{ReactionResultDTO: result})
+	}
+}
+
+// SetPostCommentReaction 点赞、点踩或取消表态路径中的帖子评论, 被评论作者拉黑的用户不能表态
+func SetPostCommentReaction() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, comment, ok := loadPostComment(c)
+		if !ok {
+			return
+		}
+		currentUser, reaction, ok := bindReaction(c, comment.Author.Id)
+		if !ok {
+			return
+		}
+
+		result, err := postBizInstance.postRepo.SetPostCommentReaction(comment.Id, currentUser.Id, reaction, config.Config.Reputation.PostComment)
+		if err != nil {
+			log.GetLogger().Errorf("帖子评论表态失败, comment=%d, user=%d: %v", comment.Id, currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.ReactionResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		if result.Reaction == model.ReactionLike && result.Previous != model.ReactionLike {
+			notification.Notify(&model.NotificationDO{
+				UserId:    comment.Author.Id,
+				Type:      model.NotifyPostCommentLike,
+				SubjectId: comment.Id,
+				PostId:    comment.PostId,
+			}, currentUser.Id)
+		}
+		c.JSON(http.StatusOK, model.ReactionResponseDTO{ReactionResultDTO: result})
+	}
+}
+
+// bindReaction 取当前用户和请求中的表态, 并检查内容作者是否拉黑了当前用户; 校验失败时已写入响应
+func bindReaction(c *gin.Context, authorId int64) (*model.UserDTO, model.Reaction, bool) {
+	currentUser, ok := auth.GetCurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+		return nil, "", false
+	}
+	var req model.SetReactionRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, "", false
+	}
+	if !req.Reaction.Valid() {
+		c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.ReactionInvalid, ErrMsg: "表态只能是 like、dislike 或空"})
+		return nil, "", false
+	}
+
+	// 匿名内容的作者ID为0, 不存在拉黑关系
+	if authorId != 0 {
+		blocked, err := db.GetRelationRepository().IsBlocked(authorId, currentUser.Id)
+		if err != nil {
+			log.GetLogger().Errorf("查询拉黑关系失败, user=%d, target=%d: %v", authorId, currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return nil, "", false
+		}
+		if blocked {
+			c.JSON(http.StatusForbidden, model.BaseResp{Code: model.BlockedByUser, ErrMsg: "对方已将你拉黑"})
+			return nil, "", false
+		}
+	}
+	return currentUser, req.Reaction, true
+}
@@ -0,0 +1,136 @@
+package realtime
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrSlowConsumer 订阅方读得太慢, 队列已满, 订阅被断开
+	ErrSlowConsumer = errors.New("realtime: slow consumer")
+	// ErrSubscriptionClosed 订阅已被订阅方关闭
+	ErrSubscriptionClosed = errors.New("realtime: subscription closed")
+)
+
+// Message 推送给订阅方的一条事件
+type Message struct {
+	Event   string // 事件名, 对应 SSE 的 event 字段
+	Data    []byte // JSON编码后的事件内容, 发布时只编码一次
+	ActorId int64  // 触发事件的用户, 订阅方据此过滤自己屏蔽的人, 系统事件为0
+}
+
+// Subscription 对一个主题的订阅
+type Subscription interface {
+	// C 返回接收事件的通道, 订阅结束时通道被关闭
+	C() <-chan *Message
+	// Err 返回订阅结束的原因, 只在 C 被关闭后有意义
+	Err() error
+	// Close 取消订阅, 可以重复调用
+	Close()
+}
+
+// Broker 事件的发布订阅
+// 单机部署使用 MemoryBroker, 多节点部署需要换成基于共享消息系统的实现, 让各节点的订阅方都能收到事件
+type Broker interface {
+	// Publish 向主题的全部订阅方发布事件, 不能因为某个订阅方读得慢而阻塞
+	Publish(topic string, msg *Message) error
+	// Subscribe 订阅主题, buffer 为待接收事件的队列长度, 队列满时订阅被断开
+	Subscribe(topic string, buffer int) (Subscription, error)
+}
+
+// MemoryBroker 基于内存的 Broker 实现, 只能把事件推给同一进程内的订阅方
+type MemoryBroker struct {
+	mu     sync.RWMutex
+	topics map[string]map[*memorySubscription]struct{}
+}
+
+// NewMemoryBroker 创建内存实现
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{topics: make(map[string]map[*memorySubscription]struct{})}
+}
+
+// Publish 非阻塞地把事件放进每个订阅方的队列, 队列已满的订阅方被断开
+// 断开而不是丢弃单条事件, 客户端重连后通过接口补齐, 不会悄悄漏掉中间的事件
+func (b *MemoryBroker) Publish(topic string, msg *Message) error {
+	var slow []*memorySubscription
+	b.mu.RLock()
+	for sub := range b.topics[topic] {
+		select {
+		case sub.ch <- msg:
+		default:
+			slow = append(slow, sub)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, sub := range slow {
+		b.remove(sub, ErrSlowConsumer)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(topic string, buffer int) (Subscription, error) {
+	if buffer <= 0 {
+		buffer = 1
+	}
+	sub := &memorySubscription{
+		broker: b,
+		topic:  topic,
+		ch:     make(chan *Message, buffer),
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	subs, ok := b.topics[topic]
+	if !ok {
+		subs = make(map[*memorySubscription]struct{})
+		b.topics[topic] = subs
+	}
+	subs[sub] = struct{}{}
+	return sub, nil
+}
+
+// remove 移除订阅并关闭通道; 持有写锁时关闭, 保证 Publish 不会向已关闭的通道发送
+func (b *MemoryBroker) remove(sub *memorySubscription, reason error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	subs, ok := b.topics[sub.topic]
+	if !ok {
+		return
+	}
+	if _, ok = subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.topics, sub.topic)
+	}
+	sub.err = reason
+	close(sub.ch)
+}
+
+// memorySubscription MemoryBroker 的订阅
+type memorySubscription struct {
+	broker *MemoryBroker
+	topic  string
+	ch     chan *Message
+	err    error // 在关闭 ch 之前写入, 读取方在 ch 关闭后读取
+}
+
+func (s *memorySubscription) C() <-chan *Message {
+	return s.ch
+}
+
+func (s *memorySubscription) Err() error {
+	return s.err
+}
+
+func (s *memorySubscription) Close() {
+	s.broker.remove(s, ErrSubscriptionClosed)
+}
+
+var broker Broker = NewMemoryBroker()
+
+// SetBroker 替换事件发布订阅的实现
+func SetBroker(b Broker) {
+	broker = b
+}
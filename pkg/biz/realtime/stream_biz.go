@@ -0,0 +1,249 @@
+package realtime
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/config"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+// 推送的事件名
+const (
+	EventNotification   = "notification"    // 新通知或通知有了新的触发者
+	EventCommentCreated = "comment_created" // 帖子有新评论
+	EventCommentUpdated = "comment_updated"
+	EventCommentDeleted = "comment_deleted"
+	EventPostDeleted    = "post_deleted"  // 帖子被删除, 客户端可以断开评论流
+	EventMessage        = "message"       // 收到私信, 或自己在其他设备上发出了私信
+	EventMessagesRead   = "messages_read" // 会话的已读位置变化, 用于已读回执
+	eventOverflow       = "overflow"      // 客户端读得太慢被断开, 需要重新拉取后再连接
+	eventExpired        = "expired"       // 连接到期, 需要重新连接
+)
+
+// NotificationTopic 用户通知流的主题
+func NotificationTopic(userId int64) string {
+	return "user:" + strconv.FormatInt(userId, 10) + ":notifications"
+}
+
+// MessagesTopic 用户私信流的主题
+func MessagesTopic(userId int64) string {
+	return "user:" + strconv.FormatInt(userId, 10) + ":messages"
+}
+
+// PostCommentsTopic 帖子评论流的主题
+func PostCommentsTopic(postId int64) string {
+	return "post:" + strconv.FormatInt(postId, 10) + ":comments"
+}
+
+// Publish 发布一条事件, 失败只记日志, 不影响触发事件的操作
+func Publish(topic, event string, data interface{}, actorId int64) {
+	bytes, err := utils.ToJSONBytes(data)
+	if err == nil {
+		err = broker.Publish(topic, &Message{Event: event, Data: bytes, ActorId: actorId})
+	}
+	if err != nil {
+		log.GetLogger().Errorf("发布实时事件失败, topic=%s, event=%s: %v", topic, event, err)
+	}
+}
+
+// connLimiter 统计本进程的连接数, 限制每个用户和全部的连接数
+type connLimiter struct {
+	mu      sync.Mutex
+	total   int
+	perUser map[int64]int
+}
+
+var connections = &connLimiter{perUser: make(map[int64]int)}
+
+// acquire 占用一个连接名额, 超过限制时返回 false; 限制不大于0表示不限制
+func (l *connLimiter) acquire(userId int64, maxPerUser, maxTotal int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if maxTotal > 0 && l.total >= maxTotal {
+		return false
+	}
+	if maxPerUser > 0 && l.perUser[userId] >= maxPerUser {
+		return false
+	}
+	l.total++
+	l.perUser[userId]++
+	return true
+}
+
+func (l *connLimiter) release(userId int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total--
+	if l.perUser[userId]--; l.perUser[userId] <= 0 {
+		delete(l.perUser, userId)
+	}
+}
+
+// StreamNotifications 以 Server-Sent Events 推送当前用户的新通知
+func StreamNotifications() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		serveStream(c, currentUser.Id, NotificationTopic(currentUser.Id), nil)
+	}
+}
+
+// StreamMessages 以 Server-Sent Events 推送当前用户的新私信和已读回执
+func StreamMessages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		serveStream(c, currentUser.Id, MessagesTopic(currentUser.Id), nil)
+	}
+}
+
+// StreamPostComments 以 Server-Sent Events 推送帖子的评论变化, 不推送当前用户屏蔽的人发表的评论
+// 私密读书会内的帖子只有成员可以订阅, 连接期间被移出读书会的要等重连后才生效
+func StreamPostComments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		postId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+			return
+		}
+		postDO, err := db.GetPostRepository().GetPostDOById(postId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+			return
+		}
+		visible, err := db.GetGroupRepository().CanViewPosts(postDO.GroupId, currentUser.Id)
+		if err != nil {
+			log.GetLogger().Errorf("查询读书会成员失败, group=%d: %v", postDO.GroupId, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		if !visible {
+			c.JSON(http.StatusForbidden, model.BaseResp{Code: model.GroupMembersOnly, ErrMsg: "只有读书会成员可以查看"})
+			return
+		}
+
+		// 屏蔽列表在连接时加载一次, 连接期间新屏蔽的人要等重连后才生效
+		mutedIds, err := db.GetRelationRepository().MutedIds(currentUser.Id)
+		if err != nil {
+			log.GetLogger().Errorf("查询屏蔽列表失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		muted := make(map[int64]bool, len(mutedIds))
+		for _, id := range mutedIds {
+			muted[id] = true
+		}
+		serveStream(c, currentUser.Id, PostCommentsTopic(postId), func(msg *Message) bool {
+			return !muted[msg.ActorId]
+		})
+	}
+}
+
+// serveStream 订阅主题并把事件写给客户端, 直到客户端断开、连接到期或读得太慢被断开
+// filter 不为 nil 时只推送它返回 true 的事件
+func serveStream(c *gin.Context, userId int64, topic string, filter func(*Message) bool) {
+	realtimeConfig := config.Config.Realtime
+	if !connections.acquire(userId, realtimeConfig.MaxConnsPerUser, realtimeConfig.MaxConns) {
+		c.JSON(http.StatusTooManyRequests, model.BaseResp{Code: model.RealtimeConnLimit, ErrMsg: "实时连接数达到上限"})
+		return
+	}
+	defer connections.release(userId)
+
+	sub, err := broker.Subscribe(topic, realtimeConfig.BufferSize)
+	if err != nil {
+		log.GetLogger().Errorf("订阅实时事件失败, topic=%s: %v", topic, err)
+		c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+		return
+	}
+	defer sub.Close()
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // 关闭 nginx 的响应缓冲
+	c.Status(http.StatusOK)
+	if !writeComment(c, "connected") {
+		return
+	}
+
+	heartbeat := time.NewTicker(positive(realtimeConfig.HeartbeatInterval, 25*time.Second))
+	defer heartbeat.Stop()
+	expire := time.NewTimer(positive(realtimeConfig.MaxConnAge, 30*time.Minute))
+	defer expire.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-expire.C:
+			writeEvent(c, eventExpired, []byte("{}"))
+			return
+		case <-heartbeat.C:
+			if !writeComment(c, "ping") {
+				return
+			}
+		case msg, ok := <-sub.C():
+			if !ok {
+				if errors.Is(sub.Err(), ErrSlowConsumer) {
+					writeEvent(c, eventOverflow, []byte("{}"))
+				}
+				return
+			}
+			if filter != nil && !filter(msg) {
+				continue
+			}
+			if !writeEvent(c, msg.Event, msg.Data) {
+				return
+			}
+		}
+	}
+}
+
+// writeEvent 写入一条 SSE 事件并立即刷出, 写失败说明客户端已断开
+func writeEvent(c *gin.Context, event string, data []byte) bool {
+	if _, err := fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return false
+	}
+	c.Writer.Flush()
+	return true
+}
+
+// writeComment 写入 SSE 注释行, 客户端会忽略, 用于建立连接和心跳
+func writeComment(c *gin.Context, text string) bool {
+	if _, err := fmt.Fprintf(c.Writer, ": %s\n\n", text); err != nil {
+		return false
+	}
+	c.Writer.Flush()
+	return true
+}
+
+// positive 配置缺失或不合法时使用默认值
+func positive(d, fallback time.Duration) time.Duration {
+	if d <= 0 {
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,147 @@
+package reputation
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/config"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+// 声望流水的分页参数
+const (
+	eventDefaultLimit = 20
+	eventMaxLimit     = 100
+)
+
+// PenalizeRemoval 当前用户删除了别人的内容时扣除作者的声望, 失败只记日志, 不影响删除操作
+// 作者自己删除以及匿名内容不扣分
+func PenalizeRemoval(c *gin.Context, subject model.ReputationSubject, subjectId, authorId int64) {
+	currentUser, ok := auth.GetCurrentUser(c)
+	if !ok || authorId == 0 || authorId == currentUser.Id {
+		return
+	}
+	if err := db.GetReputationRepository().RecordRemoval(subject, subjectId, authorId, currentUser.Id,
+		config.Config.Reputation.ModeratorRemoval); err != nil {
+		log.GetLogger().Errorf("记录删除扣分失败, subject=%s, id=%d, author=%d: %v", subject, subjectId, authorId, err)
+	}
+}
+
+// GetReputation 获取用户的声望和徽章
+func GetReputation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		if !checkUser(c, userId) {
+			return
+		}
+
+		breakdown, err := db.GetReputationRepository().GetReputation(userId)
+		if err != nil {
+			log.GetLogger().Errorf("查询声望失败, user=%d: %v", userId, err)
+			c.JSON(http.StatusInternalServerError, model.ReputationResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.ReputationResponseDTO{Reputation: model.NewReputationDTO(breakdown)})
+	}
+}
+
+// RecomputeReputation 按流水重新统计用户的声望, 用于修复汇总与流水不一致
+func RecomputeReputation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		if !checkUser(c, userId) {
+			return
+		}
+
+		breakdown, err := db.GetReputationRepository().Recompute(userId)
+		if err != nil {
+			log.GetLogger().Errorf("重新统计声望失败, user=%d: %v", userId, err)
+			c.JSON(http.StatusInternalServerError, model.ReputationResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.ReputationResponseDTO{Reputation: model.NewReputationDTO(breakdown)})
+	}
+}
+
+// ListReputationEvents 按游标获取当前用户的声望流水, 按时间倒序
+func ListReputationEvents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		var cursor int64
+		if v := c.Query("cursor"); v != "" {
+			var err error
+			if cursor, err = strconv.ParseInt(v, 10, 64); err != nil || cursor <= 0 {
+				c.JSON(http.StatusBadRequest, model.ReputationEventListResponseDTO{
+					BaseResp: model.BaseResp{Error: errors.New("invalid cursor")},
+				})
+				return
+			}
+		}
+		_, limit, ok := utils.ParsePage("", c.Query("limit"), eventDefaultLimit, eventMaxLimit)
+		if !ok {
+			c.JSON(http.StatusBadRequest, model.ReputationEventListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid limit")},
+			})
+			return
+		}
+
+		events, err := db.GetReputationRepository().ListEvents(currentUser.Id, cursor, limit)
+		if err != nil {
+			log.GetLogger().Errorf("查询声望流水失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.ReputationEventListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		resp := model.ReputationEventListResponseDTO{Events: events}
+		if len(events) == limit {
+			resp.NextCursor = strconv.FormatInt(events[len(events)-1].Id, 10)
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// ListBadges 获取全部徽章及获得条件
+func ListBadges() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, model.BadgeListResponseDTO{Badges: model.Badges})
+	}
+}
+
+// checkUser 检查用户是否存在, 不存在时写入错误响应
+func checkUser(c *gin.Context, userId int64) bool {
+	if _, err := db.GetUserRepository().GetUserById(userId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, model.BaseResp{Code: model.UserNotExists, ErrMsg: "用户不存在"})
+		} else {
+			log.GetLogger().Errorf("查询用户失败, id=%d: %v", userId, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+		}
+		return false
+	}
+	return true
+}
@@ -3,6 +3,18 @@ package biz
 import (
 	"github.com/gin-gonic/gin"
 	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/biz/book"
+	"yujian-backend/pkg/biz/export"
+	"yujian-backend/pkg/biz/feed"
+	"yujian-backend/pkg/biz/group"
+	"yujian-backend/pkg/biz/mention"
+	"yujian-backend/pkg/biz/message"
+	"yujian-backend/pkg/biz/notification"
+	"yujian-backend/pkg/biz/post"
+	"yujian-backend/pkg/biz/realtime"
+	"yujian-backend/pkg/biz/reputation"
+	"yujian-backend/pkg/biz/shelf"
+	"yujian-backend/pkg/model"
 
 	"yujian-backend/pkg/biz/user"
 )
@@ -12,14 +24,207 @@ func SetupRouter(r *gin.Engine) {
 	// 用户相关的路由
 	userGroup := r.Group("/users")
 	{
-		userGroup.POST("/", user.CreateUser())
+		userGroup.POST("/", auth.JWTAuth(), auth.RequirePermission(model.PermManageUsers), user.CreateUser())
 		userGroup.GET("/:id", user.GetUserById())
-		userGroup.PUT("/:id", user.UpdateUser())
-		userGroup.DELETE("/:id", user.DeleteUser())
+		// 按用户名前缀补全, 用于输入 @ 时提示
+		userGroup.GET("/autocomplete", auth.JWTAuth(), mention.SuggestUsers())
+		// 普通用户只能修改自己的账号
+		userGroup.PUT("/:id", auth.JWTAuth(), auth.RequireSelfOrPermission("id", model.PermManageUsers), user.UpdateUser())
+		userGroup.DELETE("/:id", auth.JWTAuth(), auth.RequireSelfOrPermission("id", model.PermManageUsers), user.DeleteUser())
+		userGroup.PUT("/:id/role", auth.JWTAuth(), auth.RequireRole(model.RoleAdmin), user.UpdateUserRole())
+		// 资料: 本人和管理员能看到私人信息, public 视图对任何人都只返回公开信息
+		userGroup.GET("/:id/profile", auth.OptionalJWTAuth(), user.GetProfile())
+		userGroup.GET("/:id/profile/public", user.GetPublicProfile())
+		userGroup.PATCH("/:id/profile", auth.JWTAuth(), auth.RequireSelfOrPermission("id", model.PermManageUsers), user.UpdateProfile())
+		// 关注关系, 关注和粉丝列表公开
+		userGroup.POST("/:id/follow", auth.JWTAuth(), user.FollowUser())
+		userGroup.DELETE("/:id/follow", auth.JWTAuth(), user.UnfollowUser())
+		userGroup.GET("/:id/follow/status", auth.JWTAuth(), user.GetFollowStatus())
+		userGroup.GET("/:id/followers", user.GetFollowers())
+		userGroup.GET("/:id/following", user.GetFollowing())
+		// 拉黑和屏蔽
+		userGroup.POST("/:id/block", auth.JWTAuth(), user.BlockUser())
+		userGroup.DELETE("/:id/block", auth.JWTAuth(), user.UnblockUser())
+		userGroup.POST("/:id/mute", auth.JWTAuth(), user.MuteUser())
+		userGroup.DELETE("/:id/mute", auth.JWTAuth(), user.UnmuteUser())
+		// 书架公开可见
+		userGroup.GET("/:id/shelf", shelf.GetUserShelf())
+		userGroup.GET("/:id/shelves", shelf.ListCustomShelves())
+		userGroup.GET("/:id/reading-stats", shelf.GetReadingStats())
+		userGroup.GET("/:id/activity", feed.GetUserActivity())
+		userGroup.GET("/:id/reading-goals", shelf.ListReadingGoals())
+		userGroup.GET("/:id/reading-goals/:year", shelf.GetReadingGoal())
+		userGroup.GET("/:id/reputation", reputation.GetReputation())
+		userGroup.POST("/:id/reputation/recompute", auth.JWTAuth(), auth.RequirePermission(model.PermManageUsers), reputation.RecomputeReputation())
 	}
 
+	// 帖子相关的路由, 需要登录, 也可以使用带 posts:write 的 API Key
+	postGroup := r.Group("/posts", auth.Authenticate(model.ScopePostsWrite))
+	{
+		postGroup.POST("/", post.CreatePost())
+		// 作者本人或版主、管理员可以删除, 归属校验在 post 包中完成
+		postGroup.PUT("/:id", post.UpdatePost())
+		postGroup.DELETE("/:id", post.DeletePost())
+		postGroup.POST("/:id/comments", post.CreatePostComment())
+		postGroup.PUT("/:id/comments/:commentId", post.UpdatePostComment())
+		postGroup.DELETE("/:id/comments/:commentId", post.DeletePostComment())
+		// 点赞点踩, reaction 为空表示取消
+		postGroup.PUT("/:id/reaction", post.SetPostReaction())
+		postGroup.PUT("/:id/comments/:commentId/reaction", post.SetPostCommentReaction())
+	}
+
+	// 帖子和评论列表, 登录后会过滤掉屏蔽的人发布的内容; 使用 API Key 时需要 posts:read
+	r.GET("/posts", auth.OptionalAuthenticate(model.ScopePostsRead), post.ListPosts())
+	r.GET("/posts/:id/comments", auth.OptionalAuthenticate(model.ScopePostsRead), post.GetPostComments())
+	// Server-Sent Events 推送帖子评论的变化, 需要登录, 可以用连接票据代替令牌
+	r.GET("/posts/:id/comments/stream", auth.StreamAuth(), realtime.StreamPostComments())
+	// 关注的人发布的帖子, 只能用登录令牌查看
+	r.GET("/posts/following", auth.JWTAuth(), post.ListFollowingPosts())
+	// 正文或评论中提到了我的帖子
+	r.GET("/posts/mentions", auth.JWTAuth(), post.ListMentionedPosts())
+
+	// 书相关的路由, 增删改需要管理权限, 也可以使用带 books:write 的 API Key; 用 API Key 读取时需要 books:read
+	bookGroup := r.Group("/books")
+	{
+		bookGroup.GET("/:id", auth.OptionalAuthenticate(model.ScopeBooksRead), book.GetBookById())
+		bookGroup.POST("/", auth.Authenticate(model.ScopeBooksWrite), auth.RequirePermission(model.PermManageBooks), book.CreateBook())
+		bookGroup.PUT("/:id", auth.Authenticate(model.ScopeBooksWrite), auth.RequirePermission(model.PermManageBooks), book.UpdateBook())
+		bookGroup.DELETE("/:id", auth.Authenticate(model.ScopeBooksWrite), auth.RequirePermission(model.PermManageBooks), book.DeleteBook())
+
+		bookGroup.GET("/:id/comments", auth.OptionalAuthenticate(model.ScopeBooksRead), book.GetBookComments())
+		bookGroup.POST("/:id/comments", auth.JWTAuth(), book.CreateBookComment())
+		bookGroup.PUT("/:id/comments/:commentId", auth.JWTAuth(), book.UpdateBookComment())
+		bookGroup.DELETE("/:id/comments/:commentId", auth.JWTAuth(), book.DeleteBookComment())
+		bookGroup.PUT("/:id/comments/:commentId/reaction", auth.JWTAuth(), book.SetBookCommentReaction())
+	}
+
+	// API Key 管理, 只能用登录令牌操作, 不能用 API Key 创建新的 API Key
+	apiKeyGroup := r.Group("/apikeys", auth.JWTAuth())
+	{
+		apiKeyGroup.POST("/", auth.CreateAPIKey())
+		apiKeyGroup.GET("/", auth.ListAPIKeys())
+		apiKeyGroup.DELETE("/:id", auth.RevokeAPIKey())
+	}
+
+	// 当前用户的阅读状态书架
+	shelfGroup := r.Group("/shelf/books", auth.JWTAuth())
+	{
+		shelfGroup.GET("/:bookId", shelf.GetShelfEntry())
+		shelfGroup.PUT("/:bookId", shelf.UpdateShelfEntry())
+		shelfGroup.DELETE("/:bookId", shelf.DeleteShelfEntry())
+	}
+
+	// 首页动态: 关注的人的帖子、书评、阅读状态和评分
+	r.GET("/feed", auth.JWTAuth(), feed.GetHomeFeed())
+
+	// 当前用户的年度阅读目标
+	r.PUT("/reading-goals/:year", auth.JWTAuth(), shelf.SetReadingGoal())
+	r.DELETE("/reading-goals/:year", auth.JWTAuth(), shelf.DeleteReadingGoal())
+
+	// 自建书架, 只有书架的主人可以修改
+	customShelfGroup := r.Group("/shelves")
+	{
+		customShelfGroup.POST("/", auth.JWTAuth(), shelf.CreateCustomShelf())
+		customShelfGroup.PATCH("/:id", auth.JWTAuth(), shelf.UpdateCustomShelf())
+		customShelfGroup.DELETE("/:id", auth.JWTAuth(), shelf.DeleteCustomShelf())
+		customShelfGroup.GET("/:id/books", shelf.GetCustomShelfBooks())
+		customShelfGroup.POST("/:id/books", auth.JWTAuth(), shelf.AddCustomShelfBook())
+		customShelfGroup.DELETE("/:id/books/:bookId", auth.JWTAuth(), shelf.RemoveCustomShelfBook())
+	}
+
+	// Server-Sent Events 推送, 浏览器的 EventSource 不能设置请求头, 先用登录令牌换取短期的连接票据, 再放在查询参数 ticket 中连接
+	r.POST("/realtime/ticket", auth.JWTAuth(), auth.IssueStreamTicket())
+	// 推送新通知
+	r.GET("/notifications/stream", auth.StreamAuth(), realtime.StreamNotifications())
+	// 推送新私信和已读回执
+	r.GET("/conversations/stream", auth.StreamAuth(), realtime.StreamMessages())
+
+	// 当前用户的站内通知
+	notificationGroup := r.Group("/notifications", auth.JWTAuth())
+	{
+		notificationGroup.GET("/", notification.ListNotifications())
+		notificationGroup.GET("/unread-count", notification.GetUnreadCount())
+		notificationGroup.POST("/read-all", notification.MarkAllRead())
+		notificationGroup.PUT("/:id/read", notification.MarkRead())
+		notificationGroup.DELETE("/:id/read", notification.MarkUnread())
+	}
+
+	// 私信, 发起会话时受对方的私信设置和拉黑关系限制
+	r.POST("/users/:id/messages", auth.JWTAuth(), message.SendToUser())
+	conversationGroup := r.Group("/conversations", auth.JWTAuth())
+	{
+		conversationGroup.GET("/", message.ListConversations())
+		conversationGroup.GET("/unread-count", message.GetUnreadCount())
+		conversationGroup.GET("/:id/messages", message.ListMessages())
+		conversationGroup.POST("/:id/messages", message.SendMessage())
+		conversationGroup.PUT("/:id/read", message.MarkConversationRead())
+	}
+
+	// 读书会, 只邀请的读书会对非成员隐藏, 私密和只邀请的读书会里的帖子只有成员能看到
+	// 发帖使用 POST /posts 并填写 group_id
+	readingGroup := r.Group("/groups")
+	{
+		readingGroup.GET("/", auth.OptionalJWTAuth(), group.ListGroups())
+		readingGroup.POST("/", auth.JWTAuth(), group.CreateGroup())
+		readingGroup.GET("/joined", auth.JWTAuth(), group.ListJoinedGroups())
+		readingGroup.GET("/invitations", auth.JWTAuth(), group.ListInvitations())
+		readingGroup.GET("/:id", auth.OptionalJWTAuth(), group.GetGroup())
+		// 创建者和管理员可以修改, 只有创建者可以解散
+		readingGroup.PATCH("/:id", auth.JWTAuth(), group.UpdateGroup())
+		readingGroup.DELETE("/:id", auth.JWTAuth(), group.DeleteGroup())
+		readingGroup.GET("/:id/posts", auth.OptionalAuthenticate(model.ScopePostsRead), post.ListGroupPosts())
+		readingGroup.GET("/:id/members", auth.OptionalJWTAuth(), group.ListMembers())
+		readingGroup.POST("/:id/join", auth.JWTAuth(), group.JoinGroup())
+		readingGroup.POST("/:id/leave", auth.JWTAuth(), group.LeaveGroup())
+		// 加入申请和邀请
+		readingGroup.GET("/:id/requests", auth.JWTAuth(), group.ListRequests())
+		readingGroup.POST("/:id/requests/:userId/approve", auth.JWTAuth(), group.ApproveRequest())
+		readingGroup.DELETE("/:id/requests/:userId", auth.JWTAuth(), group.DeleteRequest())
+		readingGroup.POST("/:id/invitations", auth.JWTAuth(), group.InviteMember())
+		// 成员管理, 只有创建者可以修改角色
+		readingGroup.PUT("/:id/members/:userId/role", auth.JWTAuth(), group.SetMemberRole())
+		readingGroup.DELETE("/:id/members/:userId", auth.JWTAuth(), group.RemoveMember())
+	}
+
+	// 当前用户的声望流水, 以及全部徽章的获得条件
+	r.GET("/reputation/events", auth.JWTAuth(), reputation.ListReputationEvents())
+	r.GET("/reputation/badges", reputation.ListBadges())
+
+	// 当前用户的拉黑和屏蔽列表
+	r.GET("/blocks", auth.JWTAuth(), user.ListBlocked())
+	r.GET("/mutes", auth.JWTAuth(), user.ListMuted())
+
+	// 个人数据导出, 下载链接自带签名, 不需要登录
+	r.POST("/account/export", auth.JWTAuth(), export.RequestDataExport())
+	r.GET("/account/export", auth.JWTAuth(), export.GetDataExport())
+	r.GET("/exports/:id/download", export.DownloadDataExport())
+
+	// 审计日志, 仅管理员可查询
+	r.GET("/audit/events", auth.JWTAuth(), auth.RequireRole(model.RoleAdmin), auth.QueryAuditEvents())
+
 	// 登录相关的路由
 	r.POST("/login", auth.UserLogin())
-	r.POST("/register", auth.UserLogin())
+	r.POST("/register", auth.UserRegister())
+	r.POST("/token/refresh", auth.RefreshToken())
+	r.POST("/logout", auth.Logout())
+	r.POST("/login/mfa", auth.LoginMFA())
+
+	// 二次验证
+	mfaGroup := r.Group("/mfa/totp", auth.JWTAuth())
+	{
+		mfaGroup.POST("/enroll", auth.EnrollTOTP())
+		mfaGroup.POST("/confirm", auth.ConfirmTOTP())
+		mfaGroup.POST("/disable", auth.DisableTOTP())
+	}
+
+	// 第三方登录
+	r.GET("/oauth/:provider/login", auth.OIDCLogin())
+	r.GET("/oauth/:provider/callback", auth.OIDCCallback())
+
+	// 邮箱验证和找回密码
+	r.POST("/email/verification", auth.JWTAuth(), auth.RequestEmailVerification())
+	r.POST("/email/verification/confirm", auth.ConfirmEmailVerification())
+	r.POST("/password/reset", auth.RequestPasswordReset())
+	r.POST("/password/reset/confirm", auth.ConfirmPasswordReset())
 
 }
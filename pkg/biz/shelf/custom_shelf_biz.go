@@ -0,0 +1,294 @@
+package shelf
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+// 自建书架的限制, 长度按字符计
+const (
+	maxCustomShelves = 50
+	maxShelfNameLen  = 32
+	maxShelfDescLen  = 200
+)
+
+// ListCustomShelves 获取用户的全部自建书架
+func ListCustomShelves() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		shelves, err := db.GetShelfRepository().ListCustomShelves(userId)
+		if err != nil {
+			log.GetLogger().Errorf("查询自建书架失败, user=%d: %v", userId, err)
+			c.JSON(http.StatusInternalServerError, model.CustomShelfListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.CustomShelfListResponseDTO{Shelves: shelves})
+	}
+}
+
+// CreateCustomShelf 为当前用户创建自建书架, 名称在同一用户下不能重复
+func CreateCustomShelf() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.CustomShelfRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		if req.Name == nil {
+			c.JSON(http.StatusBadRequest, model.CustomShelfResponseDTO{
+				BaseResp: model.BaseResp{Code: model.ShelfInfoInvalid, ErrMsg: "书架名称不能为空"},
+			})
+			return
+		}
+
+		shelf := &model.CustomShelfDO{UserId: currentUser.Id}
+		updates, code, errMsg := customShelfUpdates(shelf, &req)
+		if errMsg != "" {
+			c.JSON(http.StatusBadRequest, model.CustomShelfResponseDTO{
+				BaseResp: model.BaseResp{Code: code, ErrMsg: errMsg},
+			})
+			return
+		}
+		shelf.Name = updates["name"].(string)
+		if description, ok := updates["description"].(string); ok {
+			shelf.Description = description
+		}
+
+		shelfRepository := db.GetShelfRepository()
+		count, err := shelfRepository.CountCustomShelves(currentUser.Id)
+		if err != nil {
+			log.GetLogger().Errorf("统计自建书架失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.CustomShelfResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		if count >= maxCustomShelves {
+			c.JSON(http.StatusBadRequest, model.CustomShelfResponseDTO{
+				BaseResp: model.BaseResp{Code: model.ShelfLimitReached, ErrMsg: "自建书架数量已达上限"},
+			})
+			return
+		}
+		if !checkShelfName(c, shelf) {
+			return
+		}
+
+		if err = shelfRepository.CreateCustomShelf(shelf); err != nil {
+			log.GetLogger().Errorf("创建自建书架失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.CustomShelfResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusCreated, model.CustomShelfResponseDTO{Shelf: shelf.TransformToDTO(0)})
+	}
+}
+
+// UpdateCustomShelf 修改自建书架的名称和描述, 只更新传了的字段
+func UpdateCustomShelf() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.CustomShelfRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		shelf, ok := authorizeCustomShelf(c)
+		if !ok {
+			return
+		}
+
+		updates, code, errMsg := customShelfUpdates(shelf, &req)
+		if errMsg != "" {
+			c.JSON(http.StatusBadRequest, model.CustomShelfResponseDTO{
+				BaseResp: model.BaseResp{Code: code, ErrMsg: errMsg},
+			})
+			return
+		}
+		if name, ok := updates["name"].(string); ok {
+			shelf.Name = name
+			if !checkShelfName(c, shelf) {
+				return
+			}
+		}
+		if description, ok := updates["description"].(string); ok {
+			shelf.Description = description
+		}
+
+		shelfRepository := db.GetShelfRepository()
+		if err := shelfRepository.UpdateCustomShelf(shelf.Id, updates); err != nil {
+			log.GetLogger().Errorf("修改自建书架失败, shelf=%d: %v", shelf.Id, err)
+			c.JSON(http.StatusInternalServerError, model.CustomShelfResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		count, err := shelfRepository.CountShelfBooks(shelf.Id)
+		if err != nil {
+			log.GetLogger().Errorf("统计书架上的书失败, shelf=%d: %v", shelf.Id, err)
+		}
+		c.JSON(http.StatusOK, model.CustomShelfResponseDTO{Shelf: shelf.TransformToDTO(count)})
+	}
+}
+
+// DeleteCustomShelf 删除自建书架, 书架上的书一起移除, 不影响阅读状态
+func DeleteCustomShelf() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		shelf, ok := authorizeCustomShelf(c)
+		if !ok {
+			return
+		}
+
+		if err := db.GetShelfRepository().DeleteCustomShelf(shelf.Id); err != nil {
+			log.GetLogger().Errorf("删除自建书架失败, shelf=%d: %v", shelf.Id, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// GetCustomShelfBooks 分页获取自建书架上的书
+func GetCustomShelfBooks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		shelf, ok := loadCustomShelf(c)
+		if !ok {
+			return
+		}
+		offset, limit, ok := utils.ParsePage(c.Query("offset"), c.Query("limit"), shelfDefaultLimit, shelfMaxLimit)
+		if !ok {
+			c.JSON(http.StatusBadRequest, model.CustomShelfBookListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid offset or limit")},
+			})
+			return
+		}
+
+		books, total, err := db.GetShelfRepository().ListShelfBooks(shelf.Id, offset, limit)
+		if err != nil {
+			log.GetLogger().Errorf("查询书架上的书失败, shelf=%d: %v", shelf.Id, err)
+			c.JSON(http.StatusInternalServerError, model.CustomShelfBookListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.CustomShelfBookListResponseDTO{Total: total, Books: books})
+	}
+}
+
+// AddCustomShelfBook 往自建书架上放书, 重复放入不报错
+func AddCustomShelfBook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.AddShelfBookRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		shelf, ok := authorizeCustomShelf(c)
+		if !ok {
+			return
+		}
+		if _, ok = loadBook(c, req.BookId); !ok {
+			return
+		}
+
+		if err := db.GetShelfRepository().AddShelfBook(shelf.Id, req.BookId); err != nil {
+			log.GetLogger().Errorf("往书架上放书失败, shelf=%d, book=%d: %v", shelf.Id, req.BookId, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// RemoveCustomShelfBook 把书从自建书架上拿下来
+func RemoveCustomShelfBook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bookId, err := strconv.ParseInt(c.Param("bookId"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+			return
+		}
+		shelf, ok := authorizeCustomShelf(c)
+		if !ok {
+			return
+		}
+
+		removed, err := db.GetShelfRepository().RemoveShelfBook(shelf.Id, bookId)
+		if err != nil {
+			log.GetLogger().Errorf("从书架上拿书失败, shelf=%d, book=%d: %v", shelf.Id, bookId, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		if !removed {
+			c.JSON(http.StatusNotFound, model.BaseResp{Code: model.ShelfEntryNotExists, ErrMsg: "书不在书架上"})
+			return
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// customShelfUpdates 校验创建或修改书架的请求, 转换为需要更新的列; 校验失败时返回错误码和错误信息
+func customShelfUpdates(shelf *model.CustomShelfDO, req *model.CustomShelfRequestDTO) (map[string]interface{}, model.ErrorCode, string) {
+	updates := make(map[string]interface{})
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, model.ShelfInfoInvalid, "书架名称不能为空"
+		}
+		if utf8.RuneCountInString(name) > maxShelfNameLen {
+			return nil, model.ShelfInfoInvalid, "书架名称过长"
+		}
+		if name != shelf.Name {
+			updates["name"] = name
+		}
+	}
+	if req.Description != nil {
+		description := strings.TrimSpace(*req.Description)
+		if utf8.RuneCountInString(description) > maxShelfDescLen {
+			return nil, model.ShelfInfoInvalid, "书架描述过长"
+		}
+		updates["description"] = description
+	}
+	return updates, model.Success, ""
+}
+
+// checkShelfName 校验书架名称在同一用户下没有重复, 重复或查询失败时已写入响应
+func checkShelfName(c *gin.Context, shelf *model.CustomShelfDO) bool {
+	exists, err := db.GetShelfRepository().CustomShelfNameExists(shelf.UserId, shelf.Name, shelf.Id)
+	if err != nil {
+		log.GetLogger().Errorf("查询书架名称失败, user=%d: %v", shelf.UserId, err)
+		c.JSON(http.StatusInternalServerError, model.CustomShelfResponseDTO{
+			BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+		})
+		return false
+	}
+	if exists {
+		c.JSON(http.StatusConflict, model.CustomShelfResponseDTO{
+			BaseResp: model.BaseResp{Code: model.ShelfInfoInvalid, ErrMsg: "已有同名书架"},
+		})
+		return false
+	}
+	return true
+}
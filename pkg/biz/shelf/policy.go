@@ -0,0 +1,49 @@
+package shelf
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/model"
+)
+
+// loadCustomShelf 加载路径参数 id 对应的自建书架, 不存在时请求已被中断, 返回 false
+func loadCustomShelf(c *gin.Context) (*model.CustomShelfDO, bool) {
+	shelfId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid shelf ID"})
+		return nil, false
+	}
+	shelf, err := db.GetShelfRepository().GetCustomShelf(shelfId)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, model.BaseResp{Code: model.ShelfNotExists, ErrMsg: "书架不存在"})
+		return nil, false
+	}
+	return shelf, true
+}
+
+// authorizeCustomShelf 加载自建书架并校验当前用户是书架的主人, 管理员也可以操作
+func authorizeCustomShelf(c *gin.Context) (*model.CustomShelfDO, bool) {
+	shelf, ok := loadCustomShelf(c)
+	if !ok {
+		return nil, false
+	}
+	if !auth.AuthorizeOwner(c, shelf.UserId, model.PermManageUsers) {
+		return nil, false
+	}
+	return shelf, true
+}
+
+// loadBook 校验书存在, 不存在时请求已被中断, 返回 false
+func loadBook(c *gin.Context, bookId int64) (*model.BookInfoDTO, bool) {
+	book, err := db.GetBookRepository().GetBookById(bookId)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, model.BaseResp{Code: model.BookNotExists, ErrMsg: "书不存在"})
+		return nil, false
+	}
+	return book, true
+}
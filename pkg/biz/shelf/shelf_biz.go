@@ -0,0 +1,288 @@
+package shelf
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/biz/feed"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+// 书架列表的分页参数
+const (
+	shelfDefaultLimit = 20
+	shelfMaxLimit     = 100
+)
+
+// GetUserShelf 分页获取用户书架上的书, 可以用 status 参数只看某种阅读状态
+func GetUserShelf() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		status := model.ShelfStatus(c.Query("status"))
+		if status != "" && !status.Valid() {
+			c.JSON(http.StatusBadRequest, model.ShelfEntryListResponseDTO{
+				BaseResp: model.BaseResp{Code: model.ShelfEntryInvalid, ErrMsg: "invalid status"},
+			})
+			return
+		}
+		offset, limit, ok := utils.ParsePage(c.Query("offset"), c.Query("limit"), shelfDefaultLimit, shelfMaxLimit)
+		if !ok {
+			c.JSON(http.StatusBadRequest, model.ShelfEntryListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid offset or limit")},
+			})
+			return
+		}
+
+		entries, total, err := db.GetShelfRepository().ListShelfEntries(userId, status, offset, limit)
+		if err != nil {
+			log.GetLogger().Errorf("查询书架失败, user=%d: %v", userId, err)
+			c.JSON(http.StatusInternalServerError, model.ShelfEntryListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.ShelfEntryListResponseDTO{Total: total, Entries: entries})
+	}
+}
+
+// GetShelfEntry 获取当前用户书架上某本书的阅读状态
+func GetShelfEntry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, book, ok := shelfBook(c)
+		if !ok {
+			return
+		}
+
+		entry, err := db.GetShelfRepository().GetShelfEntry(currentUser.Id, book.Id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, model.ShelfEntryResponseDTO{
+				BaseResp: model.BaseResp{Code: model.ShelfEntryNotExists, ErrMsg: "书不在书架上"},
+			})
+			return
+		}
+		if err != nil {
+			log.GetLogger().Errorf("查询书架条目失败, user=%d, book=%d: %v", currentUser.Id, book.Id, err)
+			c.JSON(http.StatusInternalServerError, model.ShelfEntryResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.ShelfEntryResponseDTO{Entry: entry.TransformToDTO(book)})
+	}
+}
+
+// UpdateShelfEntry 把书放上当前用户的书架, 或修改已在书架上的书的状态、日期和进度
+func UpdateShelfEntry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.UpdateShelfEntryRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		currentUser, book, ok := shelfBook(c)
+		if !ok {
+			return
+		}
+
+		shelfRepository := db.GetShelfRepository()
+		entry, err := shelfRepository.GetShelfEntry(currentUser.Id, book.Id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			entry = &model.ShelfEntryDO{UserId: currentUser.Id, BookId: book.Id}
+		} else if err != nil {
+			log.GetLogger().Errorf("查询书架条目失败, user=%d, book=%d: %v", currentUser.Id, book.Id, err)
+			c.JSON(http.StatusInternalServerError, model.ShelfEntryResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+
+		previous := *entry
+		if errMsg := applyShelfUpdate(entry, &req, time.Now()); errMsg != "" {
+			c.JSON(http.StatusBadRequest, model.ShelfEntryResponseDTO{
+				BaseResp: model.BaseResp{Code: model.ShelfEntryInvalid, ErrMsg: errMsg},
+			})
+			return
+		}
+		if err = shelfRepository.SaveShelfEntry(entry); err != nil {
+			log.GetLogger().Errorf("保存书架条目失败, user=%d, book=%d: %v", currentUser.Id, book.Id, err)
+			c.JSON(http.StatusInternalServerError, model.ShelfEntryResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		publishShelfActivity(&previous, entry)
+		c.JSON(http.StatusOK, model.ShelfEntryResponseDTO{Entry: entry.TransformToDTO(book)})
+	}
+}
+
+// publishShelfActivity 阅读状态变化或给出新评分时记录动态, 只改日期和进度不产生动态
+func publishShelfActivity(previous, entry *model.ShelfEntryDO) {
+	if entry.Status != previous.Status {
+		feed.Publish(&model.ActivityEventDO{
+			ActorId:   entry.UserId,
+			Type:      model.ActivityShelfUpdate,
+			SubjectId: entry.Id,
+			BookId:    entry.BookId,
+			Status:    entry.Status,
+		})
+	}
+	if entry.Rating != previous.Rating && entry.Rating > 0 {
+		feed.Publish(&model.ActivityEventDO{
+			ActorId:   entry.UserId,
+			Type:      model.ActivityRating,
+			SubjectId: entry.Id,
+			BookId:    entry.BookId,
+			Rating:    entry.Rating,
+		})
+	}
+}
+
+// DeleteShelfEntry 把书从当前用户的书架上拿下来
+func DeleteShelfEntry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		bookId, err := strconv.ParseInt(c.Param("bookId"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+			return
+		}
+
+		removed, err := db.GetShelfRepository().DeleteShelfEntry(currentUser.Id, bookId)
+		if err != nil {
+			log.GetLogger().Errorf("删除书架条目失败, user=%d, book=%d: %v", currentUser.Id, bookId, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		if !removed {
+			c.JSON(http.StatusNotFound, model.BaseResp{Code: model.ShelfEntryNotExists, ErrMsg: "书不在书架上"})
+			return
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// shelfBook 取当前用户和路径参数 bookId 对应的书, 校验失败时已写入响应
+func shelfBook(c *gin.Context) (*model.UserDTO, *model.BookInfoDTO, bool) {
+	currentUser, ok := auth.GetCurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+		return nil, nil, false
+	}
+	bookId, err := strconv.ParseInt(c.Param("bookId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return nil, nil, false
+	}
+	book, ok := loadBook(c, bookId)
+	if !ok {
+		return nil, nil, false
+	}
+	return currentUser, book, true
+}
+
+// applyShelfUpdate 把请求中的字段合并到书架条目上, 校验失败时返回错误信息
+// 开始在读时默认开始日期为今天, 读完时默认读完日期为今天, 按百分比记录的进度记为100
+func applyShelfUpdate(entry *model.ShelfEntryDO, req *model.UpdateShelfEntryRequestDTO, now time.Time) string {
+	if req.Status != nil {
+		if !req.Status.Valid() {
+			return "invalid status"
+		}
+		entry.Status = *req.Status
+	}
+	if entry.Status == "" {
+		return "status is required"
+	}
+
+	var err error
+	if req.StartDate != nil {
+		if entry.StartDate, err = parseShelfDate(*req.StartDate); err != nil {
+			return "invalid start_date"
+		}
+	}
+	if req.FinishDate != nil {
+		if entry.FinishDate, err = parseShelfDate(*req.FinishDate); err != nil {
+			return "invalid finish_date"
+		}
+	}
+
+	if req.ProgressUnit != nil {
+		if !req.ProgressUnit.Valid() {
+			return "invalid progress_unit"
+		}
+		if *req.ProgressUnit != entry.ProgressUnit {
+			// 换了单位, 原来的进度没有意义
+			entry.ProgressUnit = *req.ProgressUnit
+			entry.Progress = 0
+		}
+	}
+	if req.Progress != nil {
+		entry.Progress = *req.Progress
+	}
+	if req.Rating != nil {
+		if *req.Rating < 0 || *req.Rating > model.MaxShelfRating {
+			return "rating must be between 0 and 5"
+		}
+		entry.Rating = *req.Rating
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if req.Status != nil {
+		switch *req.Status {
+		case model.ShelfReading:
+			if entry.StartDate == nil {
+				entry.StartDate = &today
+			}
+		case model.ShelfRead:
+			if entry.FinishDate == nil {
+				entry.FinishDate = &today
+			}
+			if req.Progress == nil && entry.ProgressUnit != model.ProgressPage {
+				entry.ProgressUnit = model.ProgressPercent
+				entry.Progress = 100
+			}
+		}
+	}
+
+	if entry.Progress < 0 {
+		return "progress must not be negative"
+	}
+	if entry.Progress > 0 && entry.ProgressUnit == "" {
+		return "progress_unit is required"
+	}
+	if entry.ProgressUnit == model.ProgressPercent && entry.Progress > 100 {
+		return "percent progress must not exceed 100"
+	}
+	if entry.StartDate != nil && entry.FinishDate != nil && entry.FinishDate.Before(*entry.StartDate) {
+		return "finish_date is before start_date"
+	}
+	return ""
+}
+
+// parseShelfDate 解析日期, 空字符串表示清空
+func parseShelfDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation(model.ShelfDateLayout, value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,239 @@
+package shelf
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+)
+
+// 阅读统计和阅读目标的限制
+const (
+	statsTopN      = 10
+	minStatsYear   = 1900
+	maxStatsYear   = 9999
+	maxTargetBooks = 10000
+)
+
+// GetReadingStats 获取用户的阅读统计, 带 year 参数时统计该年并按月给出数据, 否则统计全部年份
+func GetReadingStats() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		year := 0
+		if v := c.Query("year"); v != "" {
+			if year, err = parseYear(v); err != nil {
+				c.JSON(http.StatusBadRequest, model.ReadingStatsResponseDTO{
+					BaseResp: model.BaseResp{Error: errors.New("invalid year")},
+				})
+				return
+			}
+		}
+
+		stats, err := db.GetStatsRepository().GetReadingStats(userId, year, statsTopN)
+		if err != nil {
+			log.GetLogger().Errorf("统计阅读数据失败, user=%d, year=%d: %v", userId, year, err)
+			c.JSON(http.StatusInternalServerError, model.ReadingStatsResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.ReadingStatsResponseDTO{Stats: stats})
+	}
+}
+
+// ListReadingGoals 获取用户的全部年度阅读目标及完成情况
+func ListReadingGoals() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		goals, err := db.GetStatsRepository().ListReadingGoals(userId)
+		if err != nil {
+			log.GetLogger().Errorf("查询阅读目标失败, user=%d: %v", userId, err)
+			c.JSON(http.StatusInternalServerError, model.ReadingGoalListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		result := make([]*model.ReadingGoalDTO, len(goals))
+		for i, goal := range goals {
+			if result[i], err = goalProgress(goal, time.Now()); err != nil {
+				log.GetLogger().Errorf("统计阅读目标进度失败, user=%d, year=%d: %v", userId, goal.Year, err)
+				c.JSON(http.StatusInternalServerError, model.ReadingGoalListResponseDTO{
+					BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+				})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, model.ReadingGoalListResponseDTO{Goals: result})
+	}
+}
+
+// GetReadingGoal 获取用户某一年的阅读目标及完成情况
+func GetReadingGoal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		year, err := parseYear(c.Param("year"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ReadingGoalResponseDTO{
+				BaseResp: model.BaseResp{Code: model.ReadingGoalInvalid, ErrMsg: "invalid year"},
+			})
+			return
+		}
+
+		goal, err := db.GetStatsRepository().GetReadingGoal(userId, year)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, model.ReadingGoalResponseDTO{
+				BaseResp: model.BaseResp{Code: model.ReadingGoalNotExists, ErrMsg: "没有设置该年的阅读目标"},
+			})
+			return
+		}
+		var progress *model.ReadingGoalDTO
+		if err == nil {
+			progress, err = goalProgress(goal, time.Now())
+		}
+		if err != nil {
+			log.GetLogger().Errorf("查询阅读目标失败, user=%d, year=%d: %v", userId, year, err)
+			c.JSON(http.StatusInternalServerError, model.ReadingGoalResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.ReadingGoalResponseDTO{Goal: progress})
+	}
+}
+
+// SetReadingGoal 设置当前用户某一年的阅读目标, 已有目标时覆盖
+func SetReadingGoal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.SetReadingGoalRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		year, err := parseYear(c.Param("year"))
+		if err != nil || req.TargetBooks <= 0 || req.TargetBooks > maxTargetBooks {
+			c.JSON(http.StatusBadRequest, model.ReadingGoalResponseDTO{
+				BaseResp: model.BaseResp{Code: model.ReadingGoalInvalid, ErrMsg: "阅读目标的年份或数量不合法"},
+			})
+			return
+		}
+
+		goal := &model.ReadingGoalDO{UserId: currentUser.Id, Year: year, TargetBooks: req.TargetBooks}
+		if err = db.GetStatsRepository().SaveReadingGoal(goal); err != nil {
+			log.GetLogger().Errorf("保存阅读目标失败, user=%d, year=%d: %v", currentUser.Id, year, err)
+			c.JSON(http.StatusInternalServerError, model.ReadingGoalResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		progress, err := goalProgress(goal, time.Now())
+		if err != nil {
+			log.GetLogger().Errorf("统计阅读目标进度失败, user=%d, year=%d: %v", currentUser.Id, year, err)
+			c.JSON(http.StatusInternalServerError, model.ReadingGoalResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.ReadingGoalResponseDTO{Goal: progress})
+	}
+}
+
+// DeleteReadingGoal 删除当前用户某一年的阅读目标
+func DeleteReadingGoal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		year, err := parseYear(c.Param("year"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.BaseResp{Code: model.ReadingGoalInvalid, ErrMsg: "invalid year"})
+			return
+		}
+
+		removed, err := db.GetStatsRepository().DeleteReadingGoal(currentUser.Id, year)
+		if err != nil {
+			log.GetLogger().Errorf("删除阅读目标失败, user=%d, year=%d: %v", currentUser.Id, year, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		if !removed {
+			c.JSON(http.StatusNotFound, model.BaseResp{Code: model.ReadingGoalNotExists, ErrMsg: "没有设置该年的阅读目标"})
+			return
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// goalProgress 统计阅读目标的完成情况
+// 按时间进度计算到 now 应读完的数量: 过去的年份按全年计, 未来的年份为0
+func goalProgress(goal *model.ReadingGoalDO, now time.Time) (*model.ReadingGoalDTO, error) {
+	start := time.Date(goal.Year, time.January, 1, 0, 0, 0, 0, time.Local)
+	end := start.AddDate(1, 0, 0)
+	booksRead, err := db.GetStatsRepository().CountBooksRead(goal.UserId, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	elapsed := 0.0
+	switch {
+	case !now.Before(end):
+		elapsed = 1
+	case now.After(start):
+		elapsed = now.Sub(start).Hours() / end.Sub(start).Hours()
+	}
+	expected := roundTenth(float64(goal.TargetBooks) * elapsed)
+
+	return &model.ReadingGoalDTO{
+		Year:          goal.Year,
+		TargetBooks:   goal.TargetBooks,
+		BooksRead:     booksRead,
+		Percent:       roundTenth(float64(booksRead) * 100 / float64(goal.TargetBooks)),
+		ExpectedByNow: expected,
+		AheadBy:       roundTenth(float64(booksRead) - expected),
+	}, nil
+}
+
+// roundTenth 保留一位小数
+func roundTenth(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+// parseYear 解析年份参数
+func parseYear(value string) (int, error) {
+	year, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if year < minStatsYear || year > maxStatsYear {
+		return 0, errors.New("year out of range")
+	}
+	return year, nil
+}
@@ -0,0 +1,167 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/biz/notification"
+	"yujian-backend/pkg/config"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+// 关注、拉黑和屏蔽列表的分页参数
+const (
+	followDefaultLimit = 20
+	followMaxLimit     = 100
+)
+
+// FollowUser 关注路径中的用户, 重复关注不报错, 被对方拉黑时不能关注
+func FollowUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, targetId, ok := relationTarget(c, model.CannotFollowSelf, "不能关注自己")
+		if !ok {
+			return
+		}
+
+		blocked, err := db.GetRelationRepository().IsBlocked(targetId, currentUser.Id)
+		if err != nil {
+			log.GetLogger().Errorf("查询拉黑关系失败, user=%d, target=%d: %v", targetId, currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		if blocked {
+			c.JSON(http.StatusForbidden, model.BaseResp{Code: model.BlockedByUser, ErrMsg: "对方已将你拉黑"})
+			return
+		}
+
+		created, err := db.GetFollowRepository().Follow(currentUser.Id, targetId, config.Config.Feed.BackfillOnFollow)
+		if err != nil {
+			log.GetLogger().Errorf("关注用户失败, follower=%d, followee=%d: %v", currentUser.Id, targetId, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		if created {
+			notification.Notify(&model.NotificationDO{UserId: targetId, Type: model.NotifyFollow}, currentUser.Id)
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// UnfollowUser 取消关注路径中的用户, 原本没有关注也返回成功
+func UnfollowUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, targetId, ok := relationTarget(c, model.CannotFollowSelf, "不能关注自己")
+		if !ok {
+			return
+		}
+
+		if _, err := db.GetFollowRepository().Unfollow(currentUser.Id, targetId); err != nil {
+			log.GetLogger().Errorf("取消关注失败, follower=%d, followee=%d: %v", currentUser.Id, targetId, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// relationTarget 取当前用户和路径中的目标用户, 目标必须存在且不能是自己, 是自己时返回 selfCode;
+// 校验失败时已写入响应
+func relationTarget(c *gin.Context, selfCode model.ErrorCode, selfMsg string) (*model.UserDTO, int64, bool) {
+	currentUser, ok := auth.GetCurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+		return nil, 0, false
+	}
+
+	targetId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, 0, false
+	}
+	if targetId == currentUser.Id {
+		c.JSON(http.StatusBadRequest, model.BaseResp{Code: selfCode, ErrMsg: selfMsg})
+		return nil, 0, false
+	}
+
+	if _, err = db.GetUserRepository().GetUserById(targetId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, model.BaseResp{Code: model.UserNotExists, ErrMsg: "用户不存在"})
+		} else {
+			log.GetLogger().Errorf("查询用户失败, id=%d: %v", targetId, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+		}
+		return nil, 0, false
+	}
+	return currentUser, targetId, true
+}
+
+// GetFollowers 分页获取用户的粉丝列表
+func GetFollowers() gin.HandlerFunc {
+	return listFollows(db.GetFollowRepository().ListFollowers)
+}
+
+// GetFollowing 分页获取用户关注的人
+func GetFollowing() gin.HandlerFunc {
+	return listFollows(db.GetFollowRepository().ListFollowing)
+}
+
+func listFollows(list func(userId int64, offset, limit int) ([]*model.FollowUserDTO, int64, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		offset, limit, ok := utils.ParsePage(c.Query("offset"), c.Query("limit"), followDefaultLimit, followMaxLimit)
+		if !ok {
+			c.JSON(http.StatusBadRequest, model.FollowListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid offset or limit")},
+			})
+			return
+		}
+
+		users, total, err := list(userId, offset, limit)
+		if err != nil {
+			log.GetLogger().Errorf("查询关注列表失败, user=%d: %v", userId, err)
+			c.JSON(http.StatusInternalServerError, model.FollowListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.FollowListResponseDTO{Total: total, Users: users})
+	}
+}
+
+// GetFollowStatus 查询当前用户与路径中用户的关注关系
+func GetFollowStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		targetId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		status, err := db.GetFollowRepository().GetFollowStatus(currentUser.Id, targetId)
+		if err != nil {
+			log.GetLogger().Errorf("查询关注关系失败, user=%d, target=%d: %v", currentUser.Id, targetId, err)
+			c.JSON(http.StatusInternalServerError, model.FollowStatusResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.FollowStatusResponseDTO{Status: status})
+	}
+}
@@ -0,0 +1,208 @@
+package user
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+// 资料字段的长度限制, 按字符计
+const (
+	maxDisplayNameLen = 32
+	maxBioLen         = 500
+	maxAvatarLen      = 255
+	maxRegionLen      = 64
+	maxGenres         = 10
+	maxGenreLen       = 32
+)
+
+// GetProfile 获取用户资料, 本人或管理员可以看到私人信息, 其他人只能看到公开资料
+func GetProfile() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		userDTO, err := db.GetUserRepository().GetUserById(userId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, model.UserProfileResponseDTO{
+				BaseResp: model.BaseResp{Code: model.UserNotExists, ErrMsg: "用户不存在"},
+			})
+			return
+		}
+
+		profile := userDTO.PublicProfile()
+		if canViewPrivateProfile(c, userId) {
+			profile = userDTO.PrivateProfile()
+		}
+		c.JSON(http.StatusOK, model.UserProfileResponseDTO{Profile: withProfileStats(profile)})
+	}
+}
+
+// GetPublicProfile 获取公开资料, 无论谁查看都不包含私人信息
+func GetPublicProfile() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		userDTO, err := db.GetUserRepository().GetUserById(userId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, model.UserProfileResponseDTO{
+				BaseResp: model.BaseResp{Code: model.UserNotExists, ErrMsg: "用户不存在"},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.UserProfileResponseDTO{Profile: withProfileStats(userDTO.PublicProfile())})
+	}
+}
+
+// UpdateProfile 部分更新用户资料, 请求中没有出现的字段保持不变
+func UpdateProfile() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRepository := db.GetUserRepository()
+
+		userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		var req model.UpdateProfileRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updates, errMsg := profileUpdates(&req)
+		if errMsg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+			return
+		}
+
+		if _, err := userRepository.GetUserById(userId); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err := userRepository.UpdateProfile(userId, updates); err != nil {
+			log.GetLogger().Errorf("更新用户资料失败, userId=%d: %v", userId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		userDTO, err := userRepository.GetUserById(userId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, model.UserProfileResponseDTO{Profile: withProfileStats(userDTO.PrivateProfile())})
+	}
+}
+
+// withProfileStats 为资料填上各阅读状态的书的数量和声望, 查询失败时不返回对应字段
+func withProfileStats(profile *model.UserProfileDTO) *model.UserProfileDTO {
+	counts, err := db.GetShelfRepository().CountShelfEntries(profile.Id)
+	if err != nil {
+		log.GetLogger().Errorf("统计书架失败, userId=%d: %v", profile.Id, err)
+	} else {
+		profile.ShelfCounts = counts
+	}
+	breakdown, err := db.GetReputationRepository().GetReputation(profile.Id)
+	if err != nil {
+		log.GetLogger().Errorf("查询声望失败, userId=%d: %v", profile.Id, err)
+	} else {
+		profile.Reputation = model.NewReputationDTO(breakdown)
+	}
+	return profile
+}
+
+// canViewPrivateProfile 当前请求是否来自资料的主人或拥有用户管理权限的用户
+func canViewPrivateProfile(c *gin.Context, userId int64) bool {
+	currentUser, ok := auth.GetCurrentUser(c)
+	if !ok {
+		return false
+	}
+	return currentUser.Id == userId || currentUser.Role.HasPermission(model.PermManageUsers)
+}
+
+// profileUpdates 校验修改资料的请求, 转换为需要更新的列; 校验失败时返回错误信息
+func profileUpdates(req *model.UpdateProfileRequestDTO) (map[string]interface{}, string) {
+	updates := make(map[string]interface{})
+
+	if req.DisplayName != nil {
+		displayName := strings.TrimSpace(*req.DisplayName)
+		if utf8.RuneCountInString(displayName) > maxDisplayNameLen {
+			return nil, "display_name is too long"
+		}
+		updates["display_name"] = displayName
+	}
+	if req.Bio != nil {
+		bio := strings.TrimSpace(*req.Bio)
+		if utf8.RuneCountInString(bio) > maxBioLen {
+			return nil, "bio is too long"
+		}
+		updates["bio"] = bio
+	}
+	if req.Avatar != nil {
+		avatar := strings.TrimSpace(*req.Avatar)
+		if avatar != "" {
+			u, err := url.Parse(avatar)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(avatar) > maxAvatarLen {
+				return nil, "avatar must be an http(s) url"
+			}
+		}
+		updates["avatar"] = avatar
+	}
+	if req.Gender != nil {
+		if !req.Gender.Valid() {
+			return nil, "invalid gender"
+		}
+		updates["gender"] = *req.Gender
+	}
+	if req.Region != nil {
+		region := strings.TrimSpace(*req.Region)
+		if utf8.RuneCountInString(region) > maxRegionLen {
+			return nil, "region is too long"
+		}
+		updates["region"] = region
+	}
+	if req.FavoriteGenres != nil {
+		genres := make([]string, 0, len(*req.FavoriteGenres))
+		seen := make(map[string]bool)
+		for _, genre := range *req.FavoriteGenres {
+			genre = strings.TrimSpace(genre)
+			if genre == "" || seen[genre] {
+				continue
+			}
+			if utf8.RuneCountInString(genre) > maxGenreLen {
+				return nil, "genre is too long"
+			}
+			seen[genre] = true
+			genres = append(genres, genre)
+		}
+		if len(genres) > maxGenres {
+			return nil, "too many favorite genres"
+		}
+		updates["favorite_genres"] = utils.MustToJSONString(genres)
+	}
+	if req.MessagePolicy != nil {
+		if !req.MessagePolicy.Valid() {
+			return nil, "invalid message_policy"
+		}
+		updates["message_policy"] = *req.MessagePolicy
+	}
+	return updates, ""
+}
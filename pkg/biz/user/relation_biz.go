@@ -0,0 +1,88 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+// BlockUser 拉黑路径中的用户, 同时解除双方的关注关系
+func BlockUser() gin.HandlerFunc {
+	return updateRelation("拉黑用户", db.GetRelationRepository().Block)
+}
+
+// UnblockUser 取消拉黑路径中的用户
+func UnblockUser() gin.HandlerFunc {
+	return updateRelation("取消拉黑", db.GetRelationRepository().Unblock)
+}
+
+// MuteUser 屏蔽路径中的用户, 之后不再看到对方的帖子和评论
+func MuteUser() gin.HandlerFunc {
+	return updateRelation("屏蔽用户", db.GetRelationRepository().Mute)
+}
+
+// UnmuteUser 取消屏蔽路径中的用户
+func UnmuteUser() gin.HandlerFunc {
+	return updateRelation("取消屏蔽", db.GetRelationRepository().Unmute)
+}
+
+// updateRelation 当前用户对路径中的用户执行 update, 重复操作不报错
+func updateRelation(action string, update func(userId, targetId int64) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, targetId, ok := relationTarget(c, model.CannotBlockSelf, "不能拉黑或屏蔽自己")
+		if !ok {
+			return
+		}
+
+		if err := update(currentUser.Id, targetId); err != nil {
+			log.GetLogger().Errorf("%s失败, user=%d, target=%d: %v", action, currentUser.Id, targetId, err)
+			c.JSON(http.StatusInternalServerError, model.BaseResp{Error: errors.New("internal server error")})
+			return
+		}
+		c.JSON(http.StatusOK, model.BaseResp{Code: model.Success})
+	}
+}
+
+// ListBlocked 分页获取当前用户拉黑的人
+func ListBlocked() gin.HandlerFunc {
+	return listRelations(db.GetRelationRepository().ListBlocked)
+}
+
+// ListMuted 分页获取当前用户屏蔽的人
+func ListMuted() gin.HandlerFunc {
+	return listRelations(db.GetRelationRepository().ListMuted)
+}
+
+func listRelations(list func(userId int64, offset, limit int) ([]*model.RelationUserDTO, int64, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser, ok := auth.GetCurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, model.BaseResp{Code: model.Unauthorized, ErrMsg: "未登录"})
+			return
+		}
+		offset, limit, ok := utils.ParsePage(c.Query("offset"), c.Query("limit"), followDefaultLimit, followMaxLimit)
+		if !ok {
+			c.JSON(http.StatusBadRequest, model.RelationListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("invalid offset or limit")},
+			})
+			return
+		}
+
+		users, total, err := list(currentUser.Id, offset, limit)
+		if err != nil {
+			log.GetLogger().Errorf("查询拉黑/屏蔽列表失败, user=%d: %v", currentUser.Id, err)
+			c.JSON(http.StatusInternalServerError, model.RelationListResponseDTO{
+				BaseResp: model.BaseResp{Error: errors.New("internal server error")},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, model.RelationListResponseDTO{Total: total, Users: users})
+	}
+}
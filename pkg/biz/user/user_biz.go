@@ -1,12 +1,19 @@
 package user
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 
+	"yujian-backend/pkg/biz/auth"
+	"yujian-backend/pkg/biz/export"
 	"yujian-backend/pkg/db"
+	"yujian-backend/pkg/es"
+	"yujian-backend/pkg/log"
 	"yujian-backend/pkg/model"
 )
 
@@ -19,10 +26,42 @@ func CreateUser() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		if id, err := userRepository.CreateUser(&userDTO); err != nil {
+		if userDTO.Name == "" || userDTO.Password == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Username and password are required"})
+			return
+		}
+		if len([]rune(userDTO.Name)) > model.MaxUserNameLen {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Username is too long"})
+			return
+		}
+		if userDTO.Role == "" {
+			userDTO.Role = model.RoleUser
+		} else if !userDTO.Role.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+			return
+		}
+		passwordHash, err := auth.HashPassword(userDTO.Password)
+		if errors.Is(err, auth.ErrPasswordTooLong) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Password is too long"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		userDTO.Password = passwordHash
+		userDTO.EmailVerified = false
+		if id, err := userRepository.CreateUser(&userDTO); errors.Is(err, gorm.ErrDuplicatedKey) {
+			if _, nameErr := userRepository.GetUserByName(userDTO.Name); nameErr == nil {
+				c.JSON(http.StatusConflict, gin.H{"error": "User already exists", "code": model.UserExists})
+			} else {
+				c.JSON(http.StatusConflict, gin.H{"error": "邮箱不可用", "code": model.EmailExists})
+			}
+			return
+		} else if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		} else {
+			auth.RecordAudit(c, model.AuditRegister, id, userDTO.Name, "created by admin")
 			c.JSON(http.StatusCreated, gin.H{"message": "User created successfully", "id": id})
 		}
 	}
@@ -39,13 +78,13 @@ func GetUserById() gin.HandlerFunc {
 			return
 		}
 
-		userDO, err := userRepository.GetUserById(userId)
+		userDTO, err := userRepository.GetUserById(userId)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"user": userDO})
+		c.JSON(http.StatusOK, gin.H{"user": userDTO.Public()})
 	}
 }
 
@@ -61,24 +100,99 @@ func UpdateUser() gin.HandlerFunc {
 			return
 		}
 
-		var userDTO model.UserDTO
-		if err := c.ShouldBindJSON(&userDTO); err != nil {
+		var req model.UpdateUserRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		userDTO := req.UserDTO
+
+		// 这里只修改用户名和密码; 角色通过 UpdateUserRole, 邮箱通过验证流程, 资料通过 UpdateProfile 修改
+		existingUser, err := userRepository.GetUserById(userId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if userDTO.Name == "" {
+			userDTO.Name = existingUser.Name
+		}
+		if len([]rune(userDTO.Name)) > model.MaxUserNameLen {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Username is too long"})
+			return
+		}
+		// @提及按用户名解析, 不能改成其他人正在使用的用户名
+		if userDTO.Name != existingUser.Name {
+			other, err := userRepository.GetUserByName(userDTO.Name)
+			if err == nil && other.Id != userId {
+				c.JSON(http.StatusConflict, gin.H{"error": "User already exists", "code": model.UserExists})
+				return
+			} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if userDTO.Password != "" {
+			// 本人修改已设置的密码需要提交当前密码, 防止短期的访问令牌泄露后账号被永久接管
+			// 管理员重置他人密码、第三方登录创建的账号首次设置密码不需要
+			currentUser, ok := auth.GetCurrentUser(c)
+			if !ok {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+				return
+			}
+			if currentUser.Id == userId {
+				credential, err := userRepository.GetUserCredentialById(userId)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				if credential.Password != "" {
+					if code, wait, ok := auth.VerifyCurrentPassword(c, credential, req.CurrentPassword); !ok && wait > 0 {
+						c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+						c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many attempts, try again later", "code": code})
+						return
+					} else if !ok {
+						c.JSON(http.StatusForbidden, gin.H{"error": "Current password is incorrect", "code": code})
+						return
+					}
+				}
+			}
+
+			passwordHash, err := auth.HashPassword(userDTO.Password)
+			if errors.Is(err, auth.ErrPasswordTooLong) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Password is too long"})
+				return
+			} else if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			userDTO.Password = passwordHash
+		}
 
 		userDO := userDTO.Transfer()
 		userDO.Id = userId
-		if err := userRepository.UpdateUser(userDO); err != nil {
+		if err := userRepository.UpdateUser(userDO); errors.Is(err, gorm.ErrDuplicatedKey) {
+			// 检查之后用户名被其他人抢先使用
+			c.JSON(http.StatusConflict, gin.H{"error": "User already exists", "code": model.UserExists})
+			return
+		} else if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		if userDTO.Password != "" {
+			auth.RecordAudit(c, model.AuditPasswordChange, userId, existingUser.Name, "")
+			// 改密码后让所有已登录的会话失效, 包括可能已被他人持有的刷新令牌
+			if err := db.GetSessionRepository().RevokeUserSessions(userId); err != nil {
+				log.GetLogger().Errorf("吊销会话失败, userId=%d: %v", userId, err)
+			}
+		}
 
 		c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
 	}
 }
 
-// DeleteUser 删除用户的处理函数
+// DeleteUser 注销用户的处理函数
+// 请求体中的 mode 决定用户内容的处理方式: erase 删除, anonymize 匿名化保留, 默认匿名化
 func DeleteUser() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRepository := db.GetUserRepository()
@@ -90,11 +204,88 @@ func DeleteUser() gin.HandlerFunc {
 			return
 		}
 
-		if err := userRepository.DeleteUser(userId); err != nil {
+		var req model.DeleteUserRequestDTO
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if req.Mode == "" {
+			req.Mode = model.DeletionModeAnonymize
+		} else if !req.Mode.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deletion mode"})
+			return
+		}
+
+		existingUser, err := userRepository.GetUserById(userId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		contentIds, err := userRepository.DeleteUserAccount(userId, req.Mode)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		} else if err != nil {
+			log.GetLogger().Errorf("注销用户失败, userId=%d: %v", userId, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		auth.RecordAudit(c, model.AuditUserDelete, userId, existingUser.Name, string(req.Mode))
+
+		// 已生成的导出包同样属于用户数据
+		export.RemoveUserExports(userId)
+
+		// ES不参与数据库事务, 清理失败只记录日志, 账号注销已经生效
+		if len(contentIds) > 0 {
+			if err := es.DeleteDocuments(c.Request.Context(), (&model.PostEsModel{}).GetIndexName(), contentIds); err != nil {
+				log.GetLogger().Errorf("清理ES文档失败, userId=%d: %v", userId, err)
+			}
+		}
 
 		c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 	}
 }
+
+// UpdateUserRole 修改用户角色的处理函数
+func UpdateUserRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRepository := db.GetUserRepository()
+
+		id := c.Param("id")
+		userId, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		var req model.UpdateRoleRequestDTO
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !req.Role.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+			return
+		}
+
+		existingUser, err := userRepository.GetUserById(userId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err := userRepository.UpdateUserRole(userId, req.Role); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		auth.RecordAudit(c, model.AuditRoleChange, userId, existingUser.Name,
+			fmt.Sprintf("%s -> %s", existingUser.Role, req.Role))
+		// 访问令牌中的角色以数据库为准, 同时让刷新令牌失效, 用新角色重新登录
+		if err := db.GetSessionRepository().RevokeUserSessions(userId); err != nil {
+			log.GetLogger().Errorf("吊销会话失败, userId=%d: %v", userId, err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "User role updated successfully"})
+	}
+}
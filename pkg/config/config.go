@@ -8,7 +8,23 @@ import (
 	"yujian-backend/pkg/model"
 )
 
-var Config model.AppConfig
+var Config = model.AppConfig{
+	DB:         &model.DBConfig{},
+	Log:        &model.LogConfig{},
+	Server:     &model.ServerConfig{},
+	ES:         &model.ESConfig{},
+	JWT:        &model.JWTConfig{},
+	Password:   &model.PasswordConfig{},
+	Login:      &model.LoginConfig{},
+	Mail:       &model.MailConfig{},
+	OIDC:       &model.OIDCConfig{},
+	MFA:        &model.MFAConfig{},
+	APIKey:     &model.APIKeyConfig{},
+	Export:     &model.ExportConfig{},
+	Feed:       &model.FeedConfig{},
+	Realtime:   &model.RealtimeConfig{},
+	Reputation: &model.ReputationConfig{},
+}
 
 // initDBConfig 初始化数据库配置。
 func initDBConfig() {
@@ -34,8 +50,169 @@ func initServerConfig() {
 
 func initESConfig() {
 	esConfig := Config.ES
-	esConfig.Host = viper.GetString("es.host")
-	esConfig.Port = viper.GetString("es.port")
+	esConfig.Addresses = viper.GetStringSlice("es.addresses")
+	esConfig.Username = viper.GetString("es.username")
+	esConfig.Password = viper.GetString("es.password")
+}
+
+// initJWTConfig 初始化JWT配置。
+func initJWTConfig() {
+	viper.SetDefault("jwt.algorithm", "HS256")
+	viper.SetDefault("jwt.issuer", "yujian")
+	viper.SetDefault("jwt.expire", "15m")
+	viper.SetDefault("jwt.refresh_expire", "720h")
+	viper.SetDefault("jwt.verify_expire", "24h")
+	viper.SetDefault("jwt.reset_expire", "30m")
+
+	jwtConfig := Config.JWT
+	jwtConfig.Algorithm = viper.GetString("jwt.algorithm")
+	jwtConfig.Secret = viper.GetString("jwt.secret")
+	jwtConfig.PrivateKeyFile = viper.GetString("jwt.private_key_file")
+	jwtConfig.PublicKeyFile = viper.GetString("jwt.public_key_file")
+	jwtConfig.Issuer = viper.GetString("jwt.issuer")
+	jwtConfig.Expire = viper.GetDuration("jwt.expire")
+	jwtConfig.RefreshExpire = viper.GetDuration("jwt.refresh_expire")
+	jwtConfig.VerifyExpire = viper.GetDuration("jwt.verify_expire")
+	jwtConfig.ResetExpire = viper.GetDuration("jwt.reset_expire")
+}
+
+// initPasswordConfig 初始化密码哈希配置。
+func initPasswordConfig() {
+	viper.SetDefault("password.bcrypt_cost", 10)
+
+	passwordConfig := Config.Password
+	passwordConfig.BcryptCost = viper.GetInt("password.bcrypt_cost")
+}
+
+// initLoginConfig 初始化登录防爆破配置。
+func initLoginConfig() {
+	viper.SetDefault("login.max_failures", 5)
+	viper.SetDefault("login.ip_max_failures", 20)
+	viper.SetDefault("login.lock_duration", "15m")
+	viper.SetDefault("login.base_delay", "1s")
+	viper.SetDefault("login.max_delay", "30s")
+	viper.SetDefault("login.window", "15m")
+
+	loginConfig := Config.Login
+	loginConfig.MaxFailures = viper.GetInt("login.max_failures")
+	loginConfig.IPMaxFailures = viper.GetInt("login.ip_max_failures")
+	loginConfig.LockDuration = viper.GetDuration("login.lock_duration")
+	loginConfig.BaseDelay = viper.GetDuration("login.base_delay")
+	loginConfig.MaxDelay = viper.GetDuration("login.max_delay")
+	loginConfig.Window = viper.GetDuration("login.window")
+}
+
+// initMailConfig 初始化邮件配置。
+func initMailConfig() {
+	viper.SetDefault("mail.driver", "smtp")
+	viper.SetDefault("mail.port", 587)
+	viper.SetDefault("mail.dir", "mails/")
+
+	mailConfig := Config.Mail
+	mailConfig.Driver = viper.GetString("mail.driver")
+	mailConfig.Host = viper.GetString("mail.host")
+	mailConfig.Port = viper.GetInt("mail.port")
+	mailConfig.Username = viper.GetString("mail.username")
+	mailConfig.Password = viper.GetString("mail.password")
+	mailConfig.From = viper.GetString("mail.from")
+	mailConfig.Dir = viper.GetString("mail.dir")
+	mailConfig.LinkBaseURL = viper.GetString("mail.link_base_url")
+}
+
+// initOIDCConfig 初始化第三方登录配置。
+func initOIDCConfig() {
+	oidcConfig := Config.OIDC
+	if err := viper.UnmarshalKey("oidc.providers", &oidcConfig.Providers); err != nil {
+		log.Fatalf("Error reading oidc providers: %v", err)
+	}
+}
+
+// initMFAConfig 初始化二次验证配置。
+func initMFAConfig() {
+	viper.SetDefault("mfa.issuer", "遇荐")
+	viper.SetDefault("mfa.pending_expire", "5m")
+	viper.SetDefault("mfa.recovery_codes", 10)
+
+	mfaConfig := Config.MFA
+	mfaConfig.Issuer = viper.GetString("mfa.issuer")
+	mfaConfig.PendingExpire = viper.GetDuration("mfa.pending_expire")
+	mfaConfig.RecoveryCodes = viper.GetInt("mfa.recovery_codes")
+}
+
+// initAPIKeyConfig 初始化 API Key 配置。
+func initAPIKeyConfig() {
+	viper.SetDefault("apikey.default_expire", "2160h")
+	viper.SetDefault("apikey.max_expire", "8760h")
+	viper.SetDefault("apikey.max_per_user", 20)
+
+	apiKeyConfig := Config.APIKey
+	apiKeyConfig.DefaultExpire = viper.GetDuration("apikey.default_expire")
+	apiKeyConfig.MaxExpire = viper.GetDuration("apikey.max_expire")
+	apiKeyConfig.MaxPerUser = viper.GetInt("apikey.max_per_user")
+}
+
+// initExportConfig 初始化个人数据导出配置。
+func initExportConfig() {
+	viper.SetDefault("export.dir", "exports/")
+	viper.SetDefault("export.link_expire", "24h")
+	viper.SetDefault("export.max_concurrent", 2)
+	viper.SetDefault("export.cleanup_interval", "1h")
+
+	exportConfig := Config.Export
+	exportConfig.Dir = viper.GetString("export.dir")
+	exportConfig.LinkExpire = viper.GetDuration("export.link_expire")
+	exportConfig.MaxConcurrent = viper.GetInt("export.max_concurrent")
+	exportConfig.CleanupInterval = viper.GetDuration("export.cleanup_interval")
+}
+
+// initFeedConfig 初始化首页动态配置。
+func initFeedConfig() {
+	viper.SetDefault("feed.fan_out_max_followers", 10000)
+	viper.SetDefault("feed.backfill_on_follow", 50)
+
+	feedConfig := Config.Feed
+	feedConfig.FanOutMaxFollowers = viper.GetInt64("feed.fan_out_max_followers")
+	feedConfig.BackfillOnFollow = viper.GetInt("feed.backfill_on_follow")
+}
+
+// initRealtimeConfig 初始化实时推送配置。
+func initRealtimeConfig() {
+	viper.SetDefault("realtime.buffer_size", 64)
+	viper.SetDefault("realtime.max_conns_per_user", 5)
+	viper.SetDefault("realtime.max_conns", 10000)
+	viper.SetDefault("realtime.heartbeat_interval", "25s")
+	viper.SetDefault("realtime.max_conn_age", "30m")
+
+	realtimeConfig := Config.Realtime
+	realtimeConfig.BufferSize = viper.GetInt("realtime.buffer_size")
+	realtimeConfig.MaxConnsPerUser = viper.GetInt("realtime.max_conns_per_user")
+	realtimeConfig.MaxConns = viper.GetInt("realtime.max_conns")
+	realtimeConfig.HeartbeatInterval = viper.GetDuration("realtime.heartbeat_interval")
+	realtimeConfig.MaxConnAge = viper.GetDuration("realtime.max_conn_age")
+}
+
+// initReputationConfig 初始化声望配置。
+func initReputationConfig() {
+	viper.SetDefault("reputation.post.like", 5)
+	viper.SetDefault("reputation.post.dislike", -2)
+	viper.SetDefault("reputation.post_comment.like", 2)
+	viper.SetDefault("reputation.post_comment.dislike", -1)
+	viper.SetDefault("reputation.book_comment.like", 10)
+	viper.SetDefault("reputation.book_comment.dislike", -2)
+	viper.SetDefault("reputation.moderator_removal", -20)
+
+	reputationConfig := Config.Reputation
+	reputationConfig.Post = votePoints("reputation.post")
+	reputationConfig.PostComment = votePoints("reputation.post_comment")
+	reputationConfig.BookComment = votePoints("reputation.book_comment")
+	reputationConfig.ModeratorRemoval = viper.GetInt64("reputation.moderator_removal")
+}
+
+func votePoints(key string) model.VotePoints {
+	return model.VotePoints{
+		Like:    viper.GetInt64(key + ".like"),
+		Dislike: viper.GetInt64(key + ".dislike"),
+	}
 }
 
 func InitConfig() {
@@ -57,4 +234,25 @@ func InitConfig() {
 	initLogConfig()
 
 	initServerConfig()
+
+	initESConfig()
+
+	initJWTConfig()
+
+	initPasswordConfig()
+
+	initLoginConfig()
+
+	initMailConfig()
+
+	initOIDCConfig()
+
+	initMFAConfig()
+
+	initAPIKeyConfig()
+
+	initExportConfig()
+	initFeedConfig()
+	initRealtimeConfig()
+	initReputationConfig()
 }
@@ -0,0 +1,224 @@
+package db
+
+import (
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+// reactionRow 点赞点踩列表所在的行, 只取清理需要的列
+type reactionRow struct {
+	Id             int64
+	AuthorId       int64
+	LikeUserIds    string
+	DislikeUserIds string
+}
+
+// DeleteUserAccount 在事务中注销用户账号
+// 按 mode 删除或匿名化用户发布的帖子、帖子评论和书评, 清理用户的点赞点踩记录和声望、关注、拉黑和屏蔽关系、书架和阅读目标、动态、通知、私信会话、读书会成员身份、提及以及登录会话、第三方身份、二次验证和 API Key;
+// 返回被删除帖子的内容ID, 包括没有其他成员而被解散的读书会中的帖子, 调用方在事务提交后据此清理ES中的文档
+func (r *UserRepository) DeleteUserAccount(userId int64, mode model.DeletionMode) ([]string, error) {
+	var contentIds []string
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		// 先删用户本身, 不存在时直接返回, 避免误处理作者ID为0的匿名内容
+		result := tx.Delete(&model.UserDO{}, userId)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := removeUserReactions(tx, &model.PostDO{}, userId, nil); err != nil {
+			return err
+		}
+		if err := removeUserReactions(tx, &model.PostCommentDO{}, userId, postCommentScore); err != nil {
+			return err
+		}
+		if err := removeUserReactions(tx, &model.BookCommentDO{}, userId, bookCommentCounts); err != nil {
+			return err
+		}
+		if err := removeUserReputation(tx, userId); err != nil {
+			return err
+		}
+
+		switch mode {
+		case model.DeletionModeErase:
+			var posts []model.PostDO
+			if err := tx.Select("id", "content_id").Where("author_id = ?", userId).Find(&posts).Error; err != nil {
+				return err
+			}
+			postIds := make([]int64, len(posts))
+			for i, post := range posts {
+				postIds[i] = post.Id
+				contentIds = append(contentIds, post.ContentId)
+			}
+			if len(postIds) > 0 {
+				if err := tx.Where("post_id IN ?", postIds).Delete(&model.PostCommentDO{}).Error; err != nil {
+					return err
+				}
+				if err := removePostMentions(tx, postIds...); err != nil {
+					return err
+				}
+				if err := tx.Where("id IN ?", postIds).Delete(&model.PostDO{}).Error; err != nil {
+					return err
+				}
+				if err := removeNotifications(tx, tx.Model(&model.NotificationDO{}).Where("post_id IN ?", postIds)); err != nil {
+					return err
+				}
+			}
+			if err := tx.Where("author_id = ?", userId).Delete(&model.PostCommentDO{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("author_id = ?", userId).Delete(&model.BookCommentDO{}).Error; err != nil {
+				return err
+			}
+		default:
+			anonymous := map[string]interface{}{"author_id": 0, "author_name": model.DeletedUserName}
+			if err := tx.Model(&model.PostDO{}).Where("author_id = ?", userId).Updates(anonymous).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&model.PostCommentDO{}).Where("author_id = ?", userId).Updates(anonymous).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&model.BookCommentDO{}).Where("author_id = ?", userId).Updates(anonymous).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := removeUserMentions(tx, userId, mode); err != nil {
+			return err
+		}
+		if err := removeUserFollows(tx, userId); err != nil {
+			return err
+		}
+		if err := removeUserRelations(tx, userId); err != nil {
+			return err
+		}
+		if err := removeUserShelves(tx, userId); err != nil {
+			return err
+		}
+		if err := removeUserActivity(tx, userId); err != nil {
+			return err
+		}
+		if err := removeUserNotifications(tx, userId); err != nil {
+			return err
+		}
+		if err := removeUserConversations(tx, userId, mode); err != nil {
+			return err
+		}
+		groupContentIds, err := removeUserGroups(tx, userId)
+		if err != nil {
+			return err
+		}
+		contentIds = append(contentIds, groupContentIds...)
+
+		for _, value := range []interface{}{
+			&model.SessionDO{},
+			&model.UserIdentityDO{},
+			&model.UserMFADO{},
+			&model.RecoveryCodeDO{},
+			&model.APIKeyDO{},
+		} {
+			if err := tx.Where("user_id = ?", userId).Delete(value).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return contentIds, nil
+}
+
+// removeUserReactions 从 value 对应表的所有点赞点踩列表里去掉指定用户
+// 与 setReaction 一样先锁住要修改的行再读改写, 避免覆盖并发的表态; counts 不为 nil 时按新的列表长度同步计数列
+func removeUserReactions(tx *gorm.DB, value interface{}, userId int64, counts reactionCounts) error {
+	rows, err := findReactionRows(tx.Clauses(clause.Locking{Strength: "UPDATE"}), value, userId)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		likes, likeRemoved := removeUserId(row.LikeUserIds, userId)
+		dislikes, dislikeRemoved := removeUserId(row.DislikeUserIds, userId)
+		if !likeRemoved && !dislikeRemoved {
+			continue
+		}
+		updates := map[string]interface{}{
+			"like_user_ids":    utils.MustToJSONString(likes),
+			"dislike_user_ids": utils.MustToJSONString(dislikes),
+		}
+		if counts != nil {
+			counts(updates, len(likes), len(dislikes))
+		}
+		if err := tx.Model(value).Where("id = ?", row.Id).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findReactionRows 查询点赞点踩列表中包含指定用户的行
+// 列表以JSON数组存储, 用 JSON_CONTAINS 按整个元素匹配, 不会像子串匹配那样把ID包含该数字的其他用户也算进来
+func findReactionRows(tx *gorm.DB, value interface{}, userId int64) ([]reactionRow, error) {
+	element := strconv.FormatInt(userId, 10)
+	var rows []reactionRow
+	err := tx.Model(value).Select("id", "like_user_ids", "dislike_user_ids").
+		Where("JSON_CONTAINS(like_user_ids, ?) OR JSON_CONTAINS(dislike_user_ids, ?)", element, element).
+		Find(&rows).Error
+	return rows, err
+}
+
+// findReactedIds 查询用户点赞和点踩过的行ID
+func findReactedIds(tx *gorm.DB, value interface{}, userId int64) (liked []int64, disliked []int64, err error) {
+	rows, err := findReactionRows(tx, value, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	liked, disliked = []int64{}, []int64{}
+	for _, row := range rows {
+		if _, ok := removeUserId(row.LikeUserIds, userId); ok {
+			liked = append(liked, row.Id)
+		}
+		if _, ok := removeUserId(row.DislikeUserIds, userId); ok {
+			disliked = append(disliked, row.Id)
+		}
+	}
+	return liked, disliked, nil
+}
+
+// GetUserReactions 查询用户对帖子、帖子评论和书评的全部点赞点踩
+func (r *UserRepository) GetUserReactions(userId int64) (*model.UserReactionsDTO, error) {
+	reactions := &model.UserReactionsDTO{}
+	var err error
+	if reactions.LikedPosts, reactions.DislikedPosts, err = findReactedIds(r.DB, &model.PostDO{}, userId); err != nil {
+		return nil, err
+	}
+	if reactions.LikedPostComments, reactions.DislikedPostComments, err = findReactedIds(r.DB, &model.PostCommentDO{}, userId); err != nil {
+		return nil, err
+	}
+	if reactions.LikedBookComments, reactions.DislikedBookComments, err = findReactedIds(r.DB, &model.BookCommentDO{}, userId); err != nil {
+		return nil, err
+	}
+	return reactions, nil
+}
+
+// removeUserId 从JSON数组中去掉指定用户ID, 返回新的列表以及是否有改动
+func removeUserId(ids string, userId int64) ([]int64, bool) {
+	var list []int64
+	if ids == "" || utils.FromJSONString(ids, &list) != nil {
+		return []int64{}, false
+	}
+	filtered := make([]int64, 0, len(list))
+	for _, id := range list {
+		if id != userId {
+			filtered = append(filtered, id)
+		}
+	}
+	return filtered, len(filtered) != len(list)
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"sort"
+
+	"gorm.io/gorm"
+	"yujian-backend/pkg/model"
+)
+
+var activityRepository ActivityRepository
+
+// ActivityRepository 用户动态和首页动态收件箱
+//
+// 首页动态采用推拉结合: 粉丝不多的用户发布动态时, 在同一事务里用一条 INSERT ... SELECT 写入全部粉丝的收件箱;
+// 粉丝很多的用户只记录动态本身, 粉丝读取首页时按关注关系从动态表拉取, 再与收件箱按ID合并。
+// 是否推送在发布时决定并记在动态上, 用户的粉丝数跨过阈值前后发布的动态都不会重复或遗漏。
+type ActivityRepository struct {
+	DB *gorm.DB
+}
+
+func GetActivityRepository() *ActivityRepository {
+	return &activityRepository
+}
+
+// Publish 记录动态, 发布者的粉丝数低于 fanOutMaxFollowers 时同时写入全部粉丝的收件箱
+func (r *ActivityRepository) Publish(event *model.ActivityEventDO, fanOutMaxFollowers int64) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var actor model.UserDO
+		if err := tx.Select("id", "follower_count").First(&actor, event.ActorId).Error; err != nil {
+			return err
+		}
+		event.FannedOut = actor.FollowerCount < fanOutMaxFollowers
+		if err := tx.Create(event).Error; err != nil {
+			return err
+		}
+		if !event.FannedOut {
+			return nil
+		}
+		return tx.Exec("INSERT INTO "+model.TimelineEntryDO{}.TableName()+" (user_id, event_id, actor_id, create_time) "+
+			"SELECT follower_id, ?, ?, ? FROM "+model.FollowDO{}.TableName()+" WHERE followee_id = ?",
+			event.Id, event.ActorId, event.CreateTime, event.ActorId).Error
+	})
+}
+
+// ListTimeline 按游标获取用户的首页动态, 只返回ID小于 cursor 的动态, cursor 为0时从最新开始
+// 收件箱和拉取两部分各取 limit 条后合并, 屏蔽的人的动态不出现
+func (r *ActivityRepository) ListTimeline(userId int64, cursor int64, limit int) ([]*model.ActivityEventDO, error) {
+	var inboxIds []int64
+	inbox := excludeMutedBy(r.DB.Model(&model.TimelineEntryDO{}).Where("user_id = ?", userId), "actor_id", userId)
+	if cursor > 0 {
+		inbox = inbox.Where("event_id < ?", cursor)
+	}
+	if err := inbox.Order("event_id DESC").Limit(limit).Pluck("event_id", &inboxIds).Error; err != nil {
+		return nil, err
+	}
+
+	var pulledIds []int64
+	pulled := excludeMutedBy(r.DB.Model(&model.ActivityEventDO{}).
+		Where("fanned_out = ? AND actor_id IN (?)", false, followRepository.FolloweeIdsQuery(userId)), "actor_id", userId)
+	if cursor > 0 {
+		pulled = pulled.Where("id < ?", cursor)
+	}
+	if err := pulled.Order("id DESC").Limit(limit).Pluck("id", &pulledIds).Error; err != nil {
+		return nil, err
+	}
+
+	ids := mergeEventIds(inboxIds, pulledIds, limit)
+	if len(ids) == 0 {
+		return []*model.ActivityEventDO{}, nil
+	}
+	var events []*model.ActivityEventDO
+	if err := r.DB.Where("id IN ?", ids).Order("id DESC").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// mergeEventIds 合并两组动态ID, 去重后按ID倒序取前 limit 个
+func mergeEventIds(a, b []int64, limit int) []int64 {
+	seen := make(map[int64]bool, len(a)+len(b))
+	ids := make([]int64, 0, len(a)+len(b))
+	for _, id := range append(a, b...) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids
+}
+
+// ListUserActivity 按游标获取用户自己发布的动态
+func (r *ActivityRepository) ListUserActivity(actorId int64, cursor int64, limit int) ([]*model.ActivityEventDO, error) {
+	query := r.DB.Where("actor_id = ?", actorId)
+	if cursor > 0 {
+		query = query.Where("id < ?", cursor)
+	}
+	var events []*model.ActivityEventDO
+	if err := query.Order("id DESC").Limit(limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// removeShelfEvents 删除用户关于某本书的阅读状态和评分动态, 用于把书从书架上拿下来
+func removeShelfEvents(tx *gorm.DB, actorId, bookId int64) error {
+	return removeEvents(tx, tx.Model(&model.ActivityEventDO{}).Where("actor_id = ? AND book_id = ? AND type IN ?",
+		actorId, bookId, []model.ActivityType{model.ActivityShelfUpdate, model.ActivityRating}))
+}
+
+// removeBookEvents 删除与某本书有关的全部动态, 用于删除书
+func removeBookEvents(tx *gorm.DB, bookId int64) error {
+	return removeEvents(tx, tx.Model(&model.ActivityEventDO{}).Where("book_id = ?", bookId))
+}
+
+// removeSubjectEvents 删除某个帖子或书评对应的动态
+func removeSubjectEvents(tx *gorm.DB, activityType model.ActivityType, subjectId int64) error {
+	return removeEvents(tx, tx.Model(&model.ActivityEventDO{}).Where("type = ? AND subject_id = ?", activityType, subjectId))
+}
+
+// removeEvents 删除 query 选中的动态及其在收件箱中的记录
+func removeEvents(tx *gorm.DB, query *gorm.DB) error {
+	var ids []int64
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Where("event_id IN ?", ids).Delete(&model.TimelineEntryDO{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("id IN ?", ids).Delete(&model.ActivityEventDO{}).Error
+}
+
+// backfillTimeline 把 followeeId 最近推送过的 n 条动态补进 followerId 的收件箱, 用于新关注
+// 没有推送过的动态本来就在读取时拉取, 不需要补
+func backfillTimeline(tx *gorm.DB, followerId, followeeId int64, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	return tx.Exec("INSERT INTO "+model.TimelineEntryDO{}.TableName()+" (user_id, event_id, actor_id, create_time) "+
+		"SELECT ?, id, actor_id, create_time FROM "+model.ActivityEventDO{}.TableName()+
+		" WHERE actor_id = ? AND fanned_out = ? ORDER BY id DESC LIMIT ?",
+		followerId, followeeId, true, n).Error
+}
+
+// removeUserActivity 删除用户的全部动态、收件箱以及动态在别人收件箱中的记录, 用于注销账号
+func removeUserActivity(tx *gorm.DB, userId int64) error {
+	if err := tx.Where("user_id = ? OR actor_id = ?", userId, userId).Delete(&model.TimelineEntryDO{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("actor_id = ?", userId).Delete(&model.ActivityEventDO{}).Error
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"yujian-backend/pkg/model"
+)
+
+var apiKeyRepository APIKeyRepository
+
+type APIKeyRepository struct {
+	DB *gorm.DB
+}
+
+func GetAPIKeyRepository() *APIKeyRepository {
+	return &apiKeyRepository
+}
+
+// CreateAPIKey 创建 API Key
+func (r *APIKeyRepository) CreateAPIKey(key *model.APIKeyDO) error {
+	return r.DB.Create(key).Error
+}
+
+// GetAPIKeyById 根据ID获取 API Key
+func (r *APIKeyRepository) GetAPIKeyById(id int64) (*model.APIKeyDO, error) {
+	var key model.APIKeyDO
+	if err := r.DB.Where("id = ?", id).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetAPIKeyByHash 根据密钥哈希获取 API Key
+func (r *APIKeyRepository) GetAPIKeyByHash(keyHash string) (*model.APIKeyDO, error) {
+	var key model.APIKeyDO
+	if err := r.DB.Where("key_hash = ?", keyHash).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListAPIKeysByUserId 获取用户的全部 API Key, 包括已吊销和已过期的
+func (r *APIKeyRepository) ListAPIKeysByUserId(userId int64) ([]*model.APIKeyDTO, error) {
+	var keys []model.APIKeyDO
+	if err := r.DB.Where("user_id = ?", userId).Order("id DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	keyDTOs := make([]*model.APIKeyDTO, len(keys))
+	for i := range keys {
+		keyDTOs[i] = keys[i].Transfer()
+	}
+	return keyDTOs, nil
+}
+
+// CountActiveAPIKeys 统计用户未吊销且未过期的 API Key 数量
+func (r *APIKeyRepository) CountActiveAPIKeys(userId int64, now time.Time) (int64, error) {
+	var count int64
+	err := r.DB.Model(&model.APIKeyDO{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userId, now).
+		Count(&count).Error
+	return count, err
+}
+
+// RevokeAPIKey 吊销 API Key, 已吊销的不重复处理
+func (r *APIKeyRepository) RevokeAPIKey(id int64) error {
+	return r.DB.Model(&model.APIKeyDO{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+// TouchAPIKey 更新最近使用时间
+func (r *APIKeyRepository) TouchAPIKey(id int64, usedAt time.Time) error {
+	return r.DB.Model(&model.APIKeyDO{}).Where("id = ?", id).Update("last_used_at", usedAt).Error
+}
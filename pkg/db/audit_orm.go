@@ -0,0 +1,60 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"yujian-backend/pkg/model"
+)
+
+var auditRepository AuditRepository
+
+// AuditRepository 审计事件仓库, 只提供追加和查询, 不提供修改和删除
+type AuditRepository struct {
+	DB *gorm.DB
+}
+
+func GetAuditRepository() *AuditRepository {
+	return &auditRepository
+}
+
+// RecordEvent 追加一条审计事件, 未设置时间时使用当前时间
+func (r *AuditRepository) RecordEvent(event *model.AuditEventDO) error {
+	if event.CreateTime.IsZero() {
+		event.CreateTime = time.Now()
+	}
+	return r.DB.Create(event).Error
+}
+
+// QueryEvents 按条件查询审计事件, 按时间倒序, 同时返回满足条件的总数
+func (r *AuditRepository) QueryEvents(query *model.AuditQuery) ([]*model.AuditEventDTO, int64, error) {
+	tx := r.DB.Model(&model.AuditEventDO{})
+	if query.UserId != 0 {
+		tx = tx.Where("user_id = ?", query.UserId)
+	}
+	if query.EventType != "" {
+		tx = tx.Where("event_type = ?", query.EventType)
+	}
+	if !query.Start.IsZero() {
+		tx = tx.Where("create_time >= ?", query.Start)
+	}
+	if !query.End.IsZero() {
+		tx = tx.Where("create_time < ?", query.End)
+	}
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var events []model.AuditEventDO
+	if err := tx.Order("create_time DESC, id DESC").Offset(query.Offset).Limit(query.Limit).Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+
+	eventDTOs := make([]*model.AuditEventDTO, len(events))
+	for i := range events {
+		eventDTOs[i] = events[i].Transfer()
+	}
+	return eventDTOs, total, nil
+}
@@ -11,8 +11,8 @@ type BookRepository struct {
 
 var bookRepository BookRepository
 
-func GetBookRepository() BookRepository {
-	return bookRepository
+func GetBookRepository() *BookRepository {
+	return &bookRepository
 }
 
 // 书
@@ -41,9 +41,33 @@ func (r *BookRepository) UpdateBook(bookDTO *model.BookInfoDTO) error {
 	return r.DB.Save(bookDO).Error
 }
 
-// DeleteBook 删除书
+// BatchGetBooks 批量获取书, 返回以书ID为键的映射, 不存在的书不在结果中
+func (r *BookRepository) BatchGetBooks(ids []int64) (map[int64]*model.BookInfoDTO, error) {
+	result := make(map[int64]*model.BookInfoDTO, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+	var books []model.BookInfoDO
+	if err := r.DB.Where("id IN ?", ids).Find(&books).Error; err != nil {
+		return nil, err
+	}
+	for i := range books {
+		result[books[i].Id] = books[i].Transfer()
+	}
+	return result, nil
+}
+
+// DeleteBook 删除书, 同时把书从所有人的书架上拿下来, 正在读这本书的读书会清空当前书籍
 func (r *BookRepository) DeleteBook(id int64) error {
-	return r.DB.Delete(&model.BookInfoDO{}, id).Error
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := removeBookFromShelves(tx, id); err != nil {
+			return err
+		}
+		if err := clearGroupBook(tx, id); err != nil {
+			return err
+		}
+		return tx.Delete(&model.BookInfoDO{}, id).Error
+	})
 }
 
 // 书评
@@ -79,12 +103,33 @@ func (r *BookRepository) GetBookCommentsByBookId(bookId int64) ([]*model.BookCom
 	return commentDTOs, nil
 }
 
-// UpdateBookComment 更新书评
+// GetBookCommentsByAuthorId 获取作者发表的全部书评
+func (r *BookRepository) GetBookCommentsByAuthorId(authorId int64) ([]*model.BookCommentDTO, error) {
+	var commentDOs []*model.BookCommentDO
+	if err := r.DB.Where("author_id = ?", authorId).Order("id").Find(&commentDOs).Error; err != nil {
+		return nil, err
+	}
+	commentDTOs := make([]*model.BookCommentDTO, len(commentDOs))
+	for i, commentDO := range commentDOs {
+		commentDTOs[i] = commentDO.TransformToDTO()
+	}
+	return commentDTOs, nil
+}
+
+// UpdateBookComment 更新书评内容, 只写 content 列, 不覆盖点赞点踩期间并发写入的计数和列表
 func (r *BookRepository) UpdateBookComment(comment *model.BookCommentDO) error {
-	return r.DB.Save(comment).Error
+	return r.DB.Model(comment).Select("content").Updates(comment).Error
 }
 
-// DeleteBookComment 删除书评
+// DeleteBookComment 删除书评及其动态和通知
 func (r *BookRepository) DeleteBookComment(id int64) error {
-	return r.DB.Delete(&model.BookCommentDO{}, id).Error
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := removeSubjectEvents(tx, model.ActivityBookReview, id); err != nil {
+			return err
+		}
+		if err := removeSubjectNotifications(tx, id, model.NotifyBookCommentLike); err != nil {
+			return err
+		}
+		return tx.Delete(&model.BookCommentDO{}, id).Error
+	})
 }
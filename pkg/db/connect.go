@@ -1,6 +1,8 @@
 package db
 
 import (
+	"fmt"
+
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 
@@ -9,15 +11,37 @@ import (
 )
 
 func InitDB(config model.DBConfig) {
-	db := createConnect(config)
+	UseDB(createConnect(config))
+}
+
+// UseDB 在已建立的连接上同步表结构并初始化各仓库, 测试中可以传入其他数据库的连接
+func UseDB(db *gorm.DB) {
+	autoMigrate(db)
 	userRepository = UserRepository{DB: db}
 	postRepository = PostRepository{DB: db}
 	bookRepository = BookRepository{DB: db}
+	sessionRepository = SessionRepository{DB: db}
+	identityRepository = IdentityRepository{DB: db}
+	mfaRepository = MFARepository{DB: db}
+	auditRepository = AuditRepository{DB: db}
+	apiKeyRepository = APIKeyRepository{DB: db}
+	exportRepository = ExportRepository{DB: db}
+	followRepository = FollowRepository{DB: db}
+	relationRepository = RelationRepository{DB: db}
+	shelfRepository = ShelfRepository{DB: db}
+	statsRepository = StatsRepository{DB: db}
+	activityRepository = ActivityRepository{DB: db}
+	notificationRepository = NotificationRepository{DB: db}
+	messageRepository = MessageRepository{DB: db}
+	reputationRepository = ReputationRepository{DB: db}
+	groupRepository = GroupRepository{DB: db}
+	mentionRepository = MentionRepository{DB: db}
 }
 
 func createConnect(config model.DBConfig) *gorm.DB {
 	logger := log.GetLogger()
-	db, err := gorm.Open(mysql.Open(config.CreateDsn()), &gorm.Config{})
+	// 把唯一索引冲突等数据库错误转换为 gorm 的通用错误, 例如 gorm.ErrDuplicatedKey
+	db, err := gorm.Open(mysql.Open(config.CreateDsn()), &gorm.Config{TranslateError: true})
 	if err != nil {
 		logger.Fatalf("failed to connect database: %s", err)
 		return nil
@@ -26,3 +50,73 @@ func createConnect(config model.DBConfig) *gorm.DB {
 		return db
 	}
 }
+
+// autoMigrate 同步表结构, 只会新增表和列, 不会删除已有数据
+func autoMigrate(db *gorm.DB) {
+	logger := log.GetLogger()
+	// 邮箱改为唯一索引之前, 未设置邮箱的用户存的是空字符串, 先改为 NULL 以免互相冲突
+	if db.Migrator().HasTable(&model.UserDO{}) {
+		if err := db.Model(&model.UserDO{}).Where("email = ?", "").Update("email", nil).Error; err != nil {
+			logger.Fatalf("failed to migrate database: %s", err)
+		}
+		if err := dedupeUserNames(db); err != nil {
+			logger.Fatalf("failed to migrate database: %s", err)
+		}
+	}
+	if err := db.AutoMigrate(
+		&model.UserDO{},
+		&model.PostDO{},
+		&model.PostCommentDO{},
+		&model.BookInfoDO{},
+		&model.BookCommentDO{},
+		&model.SessionDO{},
+		&model.UserIdentityDO{},
+		&model.UserMFADO{},
+		&model.RecoveryCodeDO{},
+		&model.AuditEventDO{},
+		&model.APIKeyDO{},
+		&model.DataExportDO{},
+		&model.FollowDO{},
+		&model.BlockDO{},
+		&model.MuteDO{},
+		&model.ShelfEntryDO{},
+		&model.CustomShelfDO{},
+		&model.CustomShelfBookDO{},
+		&model.ReadingGoalDO{},
+		&model.ActivityEventDO{},
+		&model.TimelineEntryDO{},
+		&model.NotificationDO{},
+		&model.NotificationActorDO{},
+		&model.ConversationDO{},
+		&model.ConversationMemberDO{},
+		&model.MessageDO{},
+		&model.ReputationEventDO{},
+		&model.UserReputationDO{},
+		&model.GroupDO{},
+		&model.GroupMemberDO{},
+		&model.GroupRequestDO{},
+		&model.MentionDO{},
+	); err != nil {
+		logger.Fatalf("failed to migrate database: %s", err)
+	}
+}
+
+// dedupeUserNames 用户名改为唯一索引之前, 重名用户中除最早注册的以外都在用户名后追加 _用户ID
+func dedupeUserNames(db *gorm.DB) error {
+	var names []string
+	if err := db.Model(&model.UserDO{}).Group("name").Having("COUNT(*) > 1").Pluck("name", &names).Error; err != nil {
+		return err
+	}
+	for _, name := range names {
+		var ids []int64
+		if err := db.Model(&model.UserDO{}).Where("name = ?", name).Order("id").Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		for _, id := range ids[1:] {
+			if err := db.Model(&model.UserDO{}).Where("id = ?", id).Update("name", fmt.Sprintf("%s_%d", name, id)).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"yujian-backend/pkg/model"
+)
+
+var exportRepository ExportRepository
+
+type ExportRepository struct {
+	DB *gorm.DB
+}
+
+func GetExportRepository() *ExportRepository {
+	return &exportRepository
+}
+
+// CreateExport 创建导出任务
+func (r *ExportRepository) CreateExport(export *model.DataExportDO) error {
+	return r.DB.Create(export).Error
+}
+
+// GetExportById 根据ID获取导出任务
+func (r *ExportRepository) GetExportById(id int64) (*model.DataExportDO, error) {
+	var export model.DataExportDO
+	if err := r.DB.First(&export, id).Error; err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// GetLatestExport 获取用户最近一次的导出任务
+func (r *ExportRepository) GetLatestExport(userId int64) (*model.DataExportDO, error) {
+	var export model.DataExportDO
+	if err := r.DB.Where("user_id = ?", userId).Order("id DESC").First(&export).Error; err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// UpdateExport 更新导出任务
+func (r *ExportRepository) UpdateExport(export *model.DataExportDO) error {
+	return r.DB.Save(export).Error
+}
+
+// ListExpiredExports 获取下载期限已过但文件还没清理的任务
+func (r *ExportRepository) ListExpiredExports(now time.Time) ([]*model.DataExportDO, error) {
+	var exports []*model.DataExportDO
+	if err := r.DB.Where("status = ? AND expires_at < ?", model.ExportDone, now).Find(&exports).Error; err != nil {
+		return nil, err
+	}
+	return exports, nil
+}
+
+// DeleteExportsByUserId 删除用户的全部导出任务, 返回被删除任务的文件名
+func (r *ExportRepository) DeleteExportsByUserId(userId int64) ([]string, error) {
+	var exports []*model.DataExportDO
+	if err := r.DB.Where("user_id = ?", userId).Find(&exports).Error; err != nil {
+		return nil, err
+	}
+	if err := r.DB.Where("user_id = ?", userId).Delete(&model.DataExportDO{}).Error; err != nil {
+		return nil, err
+	}
+	var fileNames []string
+	for _, export := range exports {
+		if export.FileName != "" {
+			fileNames = append(fileNames, export.FileName)
+		}
+	}
+	return fileNames, nil
+}
+
+// FailStaleExports 把长时间停留在排队或打包状态的任务标记为失败, 用于服务重启后的清理
+func (r *ExportRepository) FailStaleExports(before time.Time) error {
+	return r.DB.Model(&model.DataExportDO{}).
+		Where("status IN ? AND create_time < ?", []model.ExportStatus{model.ExportPending, model.ExportRunning}, before).
+		Updates(map[string]interface{}{"status": model.ExportFailed, "err_msg": "interrupted"}).Error
+}
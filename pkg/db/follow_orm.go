@@ -0,0 +1,203 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"yujian-backend/pkg/model"
+)
+
+var followRepository FollowRepository
+
+type FollowRepository struct {
+	DB *gorm.DB
+}
+
+func GetFollowRepository() *FollowRepository {
+	return &followRepository
+}
+
+// Follow 关注用户, 已经关注时返回 false; 关系和双方的计数在同一事务中更新
+// 新关注时把对方最近 backfill 条动态补进自己的首页收件箱
+func (r *FollowRepository) Follow(followerId, followeeId int64, backfill int) (bool, error) {
+	created := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.FollowDO{
+			FollowerId: followerId,
+			FolloweeId: followeeId,
+			CreateTime: time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		created = true
+		if err := adjustFollowCounts(tx, followerId, followeeId, 1); err != nil {
+			return err
+		}
+		return backfillTimeline(tx, followerId, followeeId, backfill)
+	})
+	return created, err
+}
+
+// Unfollow 取消关注, 原本没有关注时返回 false
+func (r *FollowRepository) Unfollow(followerId, followeeId int64) (bool, error) {
+	removed := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		removed, err = unfollowTx(tx, followerId, followeeId)
+		return err
+	})
+	return removed, err
+}
+
+// unfollowTx 在事务中删除关注关系并扣减双方计数, 同时清掉收件箱里对方的动态; 返回原本是否关注
+func unfollowTx(tx *gorm.DB, followerId, followeeId int64) (bool, error) {
+	result := tx.Where("follower_id = ? AND followee_id = ?", followerId, followeeId).Delete(&model.FollowDO{})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	if err := adjustFollowCounts(tx, followerId, followeeId, -1); err != nil {
+		return false, err
+	}
+	return true, tx.Where("user_id = ? AND actor_id = ?", followerId, followeeId).Delete(&model.TimelineEntryDO{}).Error
+}
+
+// adjustFollowCounts 调整关注者的关注数和被关注者的粉丝数
+func adjustFollowCounts(tx *gorm.DB, followerId, followeeId int64, delta int) error {
+	if err := incrUserCounter(tx, followerId, "following_count", delta); err != nil {
+		return err
+	}
+	return incrUserCounter(tx, followeeId, "follower_count", delta)
+}
+
+// incrUserCounter 增减用户的冗余计数, 不会减到负数
+func incrUserCounter(tx *gorm.DB, userId int64, column string, delta int) error {
+	query := tx.Model(&model.UserDO{}).Where("id = ?", userId)
+	if delta < 0 {
+		query = query.Where(column+" >= ?", -delta)
+	}
+	return query.Update(column, gorm.Expr(column+" + ?", delta)).Error
+}
+
+// removeUserFollows 删除用户的全部关注关系, 并扣减对方的粉丝数或关注数, 用于注销账号
+func removeUserFollows(tx *gorm.DB, userId int64) error {
+	var follows []model.FollowDO
+	if err := tx.Where("follower_id = ? OR followee_id = ?", userId, userId).Find(&follows).Error; err != nil {
+		return err
+	}
+	for _, follow := range follows {
+		var err error
+		if follow.FollowerId == userId {
+			err = incrUserCounter(tx, follow.FolloweeId, "follower_count", -1)
+		} else {
+			err = incrUserCounter(tx, follow.FollowerId, "following_count", -1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Where("follower_id = ? OR followee_id = ?", userId, userId).Delete(&model.FollowDO{}).Error
+}
+
+// GetFollowStatus 查询 userId 与 targetId 之间的双向关注关系
+func (r *FollowRepository) GetFollowStatus(userId, targetId int64) (*model.FollowStatusDTO, error) {
+	var follows []model.FollowDO
+	if err := r.DB.Where("(follower_id = ? AND followee_id = ?) OR (follower_id = ? AND followee_id = ?)",
+		userId, targetId, targetId, userId).Find(&follows).Error; err != nil {
+		return nil, err
+	}
+	status := &model.FollowStatusDTO{}
+	for _, follow := range follows {
+		if follow.FollowerId == userId {
+			status.Following = true
+		} else {
+			status.FollowedBy = true
+		}
+	}
+	status.Mutual = status.Following && status.FollowedBy
+	return status, nil
+}
+
+// ListFollowers 分页获取用户的粉丝, 按关注时间倒序, Mutual 表示用户也关注了对方
+func (r *FollowRepository) ListFollowers(userId int64, offset, limit int) ([]*model.FollowUserDTO, int64, error) {
+	return r.listFollows("followee_id", "follower_id", userId, offset, limit)
+}
+
+// ListFollowing 分页获取用户关注的人, 按关注时间倒序, Mutual 表示对方也关注了用户
+// limit 为 -1 时不分页
+func (r *FollowRepository) ListFollowing(userId int64, offset, limit int) ([]*model.FollowUserDTO, int64, error) {
+	return r.listFollows("follower_id", "followee_id", userId, offset, limit)
+}
+
+// listFollows 按 ownerColumn = userId 分页查询关注关系, otherColumn 为列表中展示的用户
+func (r *FollowRepository) listFollows(ownerColumn, otherColumn string, userId int64, offset, limit int) ([]*model.FollowUserDTO, int64, error) {
+	var total int64
+	if err := r.DB.Model(&model.FollowDO{}).Where(ownerColumn+" = ?", userId).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var follows []model.FollowDO
+	if err := r.DB.Where(ownerColumn+" = ?", userId).
+		Order("create_time DESC, id DESC").Offset(offset).Limit(limit).
+		Find(&follows).Error; err != nil {
+		return nil, 0, err
+	}
+	if len(follows) == 0 {
+		return []*model.FollowUserDTO{}, total, nil
+	}
+
+	otherIds := make([]int64, len(follows))
+	for i, follow := range follows {
+		otherIds[i] = followOther(&follow, otherColumn)
+	}
+
+	var users []model.UserDO
+	if err := r.DB.Where("id IN ?", otherIds).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	userMap := make(map[int64]*model.UserDTO, len(users))
+	for i := range users {
+		userMap[users[i].Id] = users[i].Transfer().Public()
+	}
+
+	// 反向关系: 列表里的用户是否也被 userId 关注或关注了 userId
+	var reverse []model.FollowDO
+	if err := r.DB.Where(otherColumn+" = ? AND "+ownerColumn+" IN ?", userId, otherIds).Find(&reverse).Error; err != nil {
+		return nil, 0, err
+	}
+	mutual := make(map[int64]bool, len(reverse))
+	for i := range reverse {
+		mutual[followOther(&reverse[i], ownerColumn)] = true
+	}
+
+	result := make([]*model.FollowUserDTO, 0, len(follows))
+	for i, follow := range follows {
+		user, ok := userMap[otherIds[i]]
+		if !ok {
+			continue
+		}
+		result = append(result, &model.FollowUserDTO{
+			User:       user,
+			FollowTime: follow.CreateTime,
+			Mutual:     mutual[otherIds[i]],
+		})
+	}
+	return result, total, nil
+}
+
+// followOther 取关注关系中指定列的用户ID
+func followOther(follow *model.FollowDO, column string) int64 {
+	if column == "follower_id" {
+		return follow.FollowerId
+	}
+	return follow.FolloweeId
+}
+
+// FolloweeIdsQuery 返回用户关注的人的ID子查询, 用于在其他查询中按关注关系过滤
+func (r *FollowRepository) FolloweeIdsQuery(userId int64) *gorm.DB {
+	return r.DB.Model(&model.FollowDO{}).Select("followee_id").Where("follower_id = ?", userId)
+}
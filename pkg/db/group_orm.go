@@ -0,0 +1,466 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"yujian-backend/pkg/model"
+)
+
+var groupRepository GroupRepository
+
+// GroupRepository 读书会、成员和加入申请
+type GroupRepository struct {
+	DB *gorm.DB
+}
+
+func GetGroupRepository() *GroupRepository {
+	return &groupRepository
+}
+
+// CreateGroup 创建读书会, 创建者同时成为成员, 角色为 owner
+func (r *GroupRepository) CreateGroup(group *model.GroupDO) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		group.MemberCount = 1
+		if err := tx.Create(group).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.GroupMemberDO{
+			GroupId:  group.Id,
+			UserId:   group.OwnerId,
+			Role:     model.GroupOwner,
+			JoinTime: group.CreateTime,
+		}).Error
+	})
+}
+
+// GetGroup 根据ID获取读书会
+func (r *GroupRepository) GetGroup(id int64) (*model.GroupDO, error) {
+	var group model.GroupDO
+	if err := r.DB.First(&group, id).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// UpdateGroup 部分更新读书会的信息
+func (r *GroupRepository) UpdateGroup(id int64, updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	updates["update_time"] = time.Now()
+	return r.DB.Model(&model.GroupDO{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// DeleteGroup 解散读书会, 删除成员、申请以及读书会内的帖子和评论
+// 返回被删除帖子的内容ID, 调用方在事务提交后据此清理ES中的文档
+func (r *GroupRepository) DeleteGroup(id int64) ([]string, error) {
+	var contentIds []string
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		contentIds, err = removeGroup(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return contentIds, nil
+}
+
+// removeGroup 删除读书会及其成员、申请和帖子, 返回被删除帖子的内容ID
+func removeGroup(tx *gorm.DB, groupId int64) ([]string, error) {
+	var posts []model.PostDO
+	if err := tx.Select("id", "content_id").Where("group_id = ?", groupId).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	contentIds := make([]string, 0, len(posts))
+	postIds := make([]int64, len(posts))
+	for i, post := range posts {
+		postIds[i] = post.Id
+		contentIds = append(contentIds, post.ContentId)
+	}
+	if len(postIds) > 0 {
+		if err := tx.Where("post_id IN ?", postIds).Delete(&model.PostCommentDO{}).Error; err != nil {
+			return nil, err
+		}
+		if err := removePostMentions(tx, postIds...); err != nil {
+			return nil, err
+		}
+		if err := removeNotifications(tx, tx.Model(&model.NotificationDO{}).Where("post_id IN ?", postIds)); err != nil {
+			return nil, err
+		}
+		if err := tx.Where("id IN ?", postIds).Delete(&model.PostDO{}).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Where("group_id = ?", groupId).Delete(&model.GroupRequestDO{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("group_id = ?", groupId).Delete(&model.GroupMemberDO{}).Error; err != nil {
+		return nil, err
+	}
+	return contentIds, tx.Delete(&model.GroupDO{}, groupId).Error
+}
+
+// ListGroups 分页获取读书会, 按创建时间倒序; 只邀请的读书会只有成员能看到
+// bookId 不为0时只返回正在读这本书的读书会
+func (r *GroupRepository) ListGroups(viewerId, bookId int64, offset, limit int) ([]*model.GroupDO, int64, error) {
+	query := r.DB.Model(&model.GroupDO{})
+	if viewerId == 0 {
+		query = query.Where("visibility <> ?", model.GroupInviteOnly)
+	} else {
+		query = query.Where("visibility <> ? OR id IN (?)", model.GroupInviteOnly, r.memberGroupIdsQuery(viewerId))
+	}
+	if bookId != 0 {
+		query = query.Where("current_book_id = ?", bookId)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var groups []*model.GroupDO
+	if err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&groups).Error; err != nil {
+		return nil, 0, err
+	}
+	return groups, total, nil
+}
+
+// ListUserGroups 获取用户加入的全部读书会, 按加入时间倒序
+func (r *GroupRepository) ListUserGroups(userId int64) ([]*model.GroupDO, error) {
+	var groups []*model.GroupDO
+	err := r.DB.Model(&model.GroupDO{}).
+		Joins("JOIN reading_group_member ON reading_group_member.group_id = reading_group.id").
+		Where("reading_group_member.user_id = ?", userId).
+		Order("reading_group_member.join_time DESC, reading_group_member.id DESC").
+		Find(&groups).Error
+	return groups, err
+}
+
+// BatchGetGroups 批量获取读书会, 返回以ID为键的映射, 不存在的读书会不在结果中
+func (r *GroupRepository) BatchGetGroups(ids []int64) (map[int64]*model.GroupDO, error) {
+	result := make(map[int64]*model.GroupDO, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+	var groups []*model.GroupDO
+	if err := r.DB.Where("id IN ?", ids).Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		result[group.Id] = group
+	}
+	return result, nil
+}
+
+// memberGroupIdsQuery 用户加入的读书会ID的子查询
+func (r *GroupRepository) memberGroupIdsQuery(userId int64) *gorm.DB {
+	return r.DB.Model(&model.GroupMemberDO{}).Select("group_id").Where("user_id = ?", userId)
+}
+
+// 成员
+
+// GetRole 获取用户在读书会中的角色, 不是成员时返回空字符串
+func (r *GroupRepository) GetRole(groupId, userId int64) (model.GroupRole, error) {
+	if userId == 0 {
+		return "", nil
+	}
+	var member model.GroupMemberDO
+	err := r.DB.Where("group_id = ? AND user_id = ?", groupId, userId).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return member.Role, nil
+}
+
+// BatchGetRoles 获取用户在多个读书会中的角色, 返回以读书会ID为键的映射, 不是成员的读书会不在结果中
+func (r *GroupRepository) BatchGetRoles(groupIds []int64, userId int64) (map[int64]model.GroupRole, error) {
+	result := make(map[int64]model.GroupRole, len(groupIds))
+	if len(groupIds) == 0 || userId == 0 {
+		return result, nil
+	}
+	var members []model.GroupMemberDO
+	if err := r.DB.Where("group_id IN ? AND user_id = ?", groupIds, userId).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		result[member.GroupId] = member.Role
+	}
+	return result, nil
+}
+
+// CanViewPosts 用户能否查看读书会内的帖子: 公开读书会所有人可见, 私密和只邀请的读书会只有成员可见
+// groupId 为0表示不属于任何读书会的帖子; 读书会已不存在时不可见
+func (r *GroupRepository) CanViewPosts(groupId, viewerId int64) (bool, error) {
+	if groupId == 0 {
+		return true, nil
+	}
+	group, err := r.GetGroup(groupId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if group.Visibility == model.GroupPublic {
+		return true, nil
+	}
+	role, err := r.GetRole(groupId, viewerId)
+	if err != nil {
+		return false, err
+	}
+	return role != "", nil
+}
+
+// visiblePosts 过滤掉 viewerId 看不到的读书会帖子, 要求表中有 group_id 列; viewerId 为0时只保留公开的帖子
+func visiblePosts(query *gorm.DB, viewerId int64) *gorm.DB {
+	publicGroups := groupRepository.DB.Model(&model.GroupDO{}).Select("id").Where("visibility = ?", model.GroupPublic)
+	if viewerId == 0 {
+		return query.Where("group_id = 0 OR group_id IN (?)", publicGroups)
+	}
+	return query.Where("group_id = 0 OR group_id IN (?) OR group_id IN (?)",
+		publicGroups, groupRepository.memberGroupIdsQuery(viewerId))
+}
+
+// AddMember 把用户加入读书会并删除其待处理的申请或邀请, 已经是成员时返回 false
+func (r *GroupRepository) AddMember(groupId, userId int64, role model.GroupRole) (bool, error) {
+	added := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.GroupMemberDO{
+			GroupId:  groupId,
+			UserId:   userId,
+			Role:     role,
+			JoinTime: time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if err := tx.Where("group_id = ? AND user_id = ?", groupId, userId).Delete(&model.GroupRequestDO{}).Error; err != nil {
+			return err
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		added = true
+		return tx.Model(&model.GroupDO{}).Where("id = ?", groupId).
+			Update("member_count", gorm.Expr("member_count + 1")).Error
+	})
+	return added, err
+}
+
+// RemoveMember 把用户移出读书会, 用户原本不是成员时返回 false
+func (r *GroupRepository) RemoveMember(groupId, userId int64) (bool, error) {
+	removed := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("group_id = ? AND user_id = ?", groupId, userId).Delete(&model.GroupMemberDO{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		removed = true
+		return tx.Model(&model.GroupDO{}).Where("id = ?", groupId).
+			Update("member_count", gorm.Expr("member_count - 1")).Error
+	})
+	return removed, err
+}
+
+// SetRole 修改成员的角色, 不能用于设置 owner, 转让读书会用 TransferOwnership
+func (r *GroupRepository) SetRole(groupId, userId int64, role model.GroupRole) error {
+	return r.DB.Model(&model.GroupMemberDO{}).Where("group_id = ? AND user_id = ?", groupId, userId).
+		Update("role", role).Error
+}
+
+// TransferOwnership 把读书会转让给另一个成员, 原创建者变为管理员
+func (r *GroupRepository) TransferOwnership(groupId, fromUserId, toUserId int64) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		return transferGroup(tx, groupId, fromUserId, toUserId)
+	})
+}
+
+// transferGroup 把读书会的 owner 角色转给 toUserId, fromUserId 不为0时降为管理员
+func transferGroup(tx *gorm.DB, groupId, fromUserId, toUserId int64) error {
+	if fromUserId != 0 {
+		if err := tx.Model(&model.GroupMemberDO{}).Where("group_id = ? AND user_id = ?", groupId, fromUserId).
+			Update("role", model.GroupAdmin).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Model(&model.GroupMemberDO{}).Where("group_id = ? AND user_id = ?", groupId, toUserId).
+		Update("role", model.GroupOwner).Error; err != nil {
+		return err
+	}
+	return tx.Model(&model.GroupDO{}).Where("id = ?", groupId).
+		Updates(map[string]interface{}{"owner_id": toUserId, "update_time": time.Now()}).Error
+}
+
+// ListMembers 分页获取读书会成员, 按加入时间排序, 带成员的公开信息; 已注销的成员不在结果中
+func (r *GroupRepository) ListMembers(groupId int64, offset, limit int) ([]*model.GroupMemberDTO, int64, error) {
+	var total int64
+	if err := r.DB.Model(&model.GroupMemberDO{}).Where("group_id = ?", groupId).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var members []model.GroupMemberDO
+	if err := r.DB.Where("group_id = ?", groupId).Order("join_time, id").
+		Offset(offset).Limit(limit).Find(&members).Error; err != nil {
+		return nil, 0, err
+	}
+
+	userIds := make([]int64, len(members))
+	for i, member := range members {
+		userIds[i] = member.UserId
+	}
+	users, err := userRepository.BatchGetUsers(userIds)
+	if err != nil {
+		return nil, 0, err
+	}
+	result := make([]*model.GroupMemberDTO, 0, len(members))
+	for _, member := range members {
+		if user, ok := users[member.UserId]; ok {
+			result = append(result, &model.GroupMemberDTO{User: user, Role: member.Role, JoinTime: member.JoinTime})
+		}
+	}
+	return result, total, nil
+}
+
+// 加入申请和邀请
+
+// GetRequest 获取用户在读书会的待处理申请或邀请, 没有时返回 nil
+func (r *GroupRepository) GetRequest(groupId, userId int64) (*model.GroupRequestDO, error) {
+	var request model.GroupRequestDO
+	err := r.DB.Where("group_id = ? AND user_id = ?", groupId, userId).First(&request).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// BatchGetRequestTypes 获取用户在多个读书会的待处理申请或邀请的类型, 没有的读书会不在结果中
+func (r *GroupRepository) BatchGetRequestTypes(groupIds []int64, userId int64) (map[int64]model.GroupRequestType, error) {
+	result := make(map[int64]model.GroupRequestType, len(groupIds))
+	if len(groupIds) == 0 || userId == 0 {
+		return result, nil
+	}
+	var requests []model.GroupRequestDO
+	if err := r.DB.Select("group_id", "type").Where("group_id IN ? AND user_id = ?", groupIds, userId).
+		Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	for _, request := range requests {
+		result[request.GroupId] = request.Type
+	}
+	return result, nil
+}
+
+// SaveRequest 保存加入申请或邀请, 已有待处理的记录时覆盖
+func (r *GroupRepository) SaveRequest(request *model.GroupRequestDO) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "group_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"type", "inviter_id", "message", "create_time"}),
+	}).Create(request).Error
+}
+
+// DeleteRequest 删除用户在读书会的待处理申请或邀请, 没有时返回 false
+func (r *GroupRepository) DeleteRequest(groupId, userId int64) (bool, error) {
+	result := r.DB.Where("group_id = ? AND user_id = ?", groupId, userId).Delete(&model.GroupRequestDO{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListRequests 获取读书会的全部待处理申请和邀请, 按时间倒序
+func (r *GroupRepository) ListRequests(groupId int64) ([]*model.GroupRequestDO, error) {
+	var requests []*model.GroupRequestDO
+	err := r.DB.Where("group_id = ?", groupId).Order("id DESC").Find(&requests).Error
+	return requests, err
+}
+
+// ListInvitations 获取用户收到的全部邀请, 按时间倒序
+func (r *GroupRepository) ListInvitations(userId int64) ([]*model.GroupRequestDO, error) {
+	var requests []*model.GroupRequestDO
+	err := r.DB.Where("user_id = ? AND type = ?", userId, model.GroupInvitation).Order("id DESC").Find(&requests).Error
+	return requests, err
+}
+
+// ListUserMemberships 获取用户的全部成员身份和待处理的申请或邀请, 用于导出个人数据
+func (r *GroupRepository) ListUserMemberships(userId int64) ([]*model.GroupMemberDO, []*model.GroupRequestDO, error) {
+	var members []*model.GroupMemberDO
+	if err := r.DB.Where("user_id = ?", userId).Order("id").Find(&members).Error; err != nil {
+		return nil, nil, err
+	}
+	var requests []*model.GroupRequestDO
+	if err := r.DB.Where("user_id = ?", userId).Order("id").Find(&requests).Error; err != nil {
+		return nil, nil, err
+	}
+	return members, requests, nil
+}
+
+// clearGroupBook 清空正在读某本书的读书会的当前书籍, 用于删除书
+func clearGroupBook(tx *gorm.DB, bookId int64) error {
+	return tx.Model(&model.GroupDO{}).Where("current_book_id = ?", bookId).Update("current_book_id", 0).Error
+}
+
+// removeUserGroups 注销账号时把用户移出所有读书会并删除其申请, 用户发出的邀请保留, 只是不再关联到该用户
+// 用户创建的读书会转让给最早加入的管理员, 没有管理员时转让给最早加入的成员, 没有其他成员时解散;
+// 返回被解散的读书会中帖子的内容ID
+func removeUserGroups(tx *gorm.DB, userId int64) ([]string, error) {
+	if err := tx.Where("user_id = ?", userId).Delete(&model.GroupRequestDO{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&model.GroupRequestDO{}).Where("inviter_id = ?", userId).Update("inviter_id", 0).Error; err != nil {
+		return nil, err
+	}
+
+	var memberships []model.GroupMemberDO
+	if err := tx.Where("user_id = ?", userId).Find(&memberships).Error; err != nil {
+		return nil, err
+	}
+	var contentIds []string
+	for _, membership := range memberships {
+		if membership.Role == model.GroupOwner {
+			successorId, err := groupSuccessor(tx, membership.GroupId, userId)
+			if err != nil {
+				return nil, err
+			}
+			if successorId == 0 {
+				ids, err := removeGroup(tx, membership.GroupId)
+				if err != nil {
+					return nil, err
+				}
+				contentIds = append(contentIds, ids...)
+				continue
+			}
+			if err = transferGroup(tx, membership.GroupId, 0, successorId); err != nil {
+				return nil, err
+			}
+		}
+		if err := tx.Delete(&model.GroupMemberDO{}, membership.Id).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&model.GroupDO{}).Where("id = ?", membership.GroupId).
+			Update("member_count", gorm.Expr("member_count - 1")).Error; err != nil {
+			return nil, err
+		}
+	}
+	return contentIds, nil
+}
+
+// groupSuccessor 创建者注销时接手读书会的成员: 最早加入的管理员, 没有管理员时为最早加入的成员, 都没有时返回0
+func groupSuccessor(tx *gorm.DB, groupId, ownerId int64) (int64, error) {
+	for _, role := range []model.GroupRole{model.GroupAdmin, model.GroupMember} {
+		var successor model.GroupMemberDO
+		err := tx.Where("group_id = ? AND user_id <> ? AND role = ?", groupId, ownerId, role).
+			Order("join_time, id").First(&successor).Error
+		if err == nil {
+			return successor.UserId, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, err
+		}
+	}
+	return 0, nil
+}
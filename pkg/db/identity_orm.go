@@ -0,0 +1,45 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"yujian-backend/pkg/model"
+)
+
+var identityRepository IdentityRepository
+
+type IdentityRepository struct {
+	DB *gorm.DB
+}
+
+func GetIdentityRepository() *IdentityRepository {
+	return &identityRepository
+}
+
+// GetIdentity 根据提供方和 subject 获取绑定关系
+func (r *IdentityRepository) GetIdentity(provider, subject string) (*model.UserIdentityDO, error) {
+	var identity model.UserIdentityDO
+	if err := r.DB.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// CreateUserWithIdentity 在事务中创建用户及其第三方身份绑定
+func (r *IdentityRepository) CreateUserWithIdentity(user *model.UserDO, identity *model.UserIdentityDO) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserId = user.Id
+		return tx.Create(identity).Error
+	})
+}
+
+// GetIdentitiesByUserId 获取用户绑定的全部第三方身份
+func (r *IdentityRepository) GetIdentitiesByUserId(userId int64) ([]*model.UserIdentityDO, error) {
+	var identities []*model.UserIdentityDO
+	if err := r.DB.Where("user_id = ?", userId).Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"yujian-backend/pkg/model"
+)
+
+var mentionRepository MentionRepository
+
+// MentionRepository 帖子和评论中对用户的提及
+type MentionRepository struct {
+	DB *gorm.DB
+}
+
+func GetMentionRepository() *MentionRepository {
+	return &mentionRepository
+}
+
+// ReplaceMentions 用 mentions 替换一条内容原有的提及, 返回这次新被提及的用户ID, 去重
+func (r *MentionRepository) ReplaceMentions(subject model.MentionSubject, subjectId int64, mentions []*model.MentionDO) ([]int64, error) {
+	var added []int64
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var existing []int64
+		if err := tx.Model(&model.MentionDO{}).Where("subject = ? AND subject_id = ?", subject, subjectId).
+			Pluck("user_id", &existing).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("subject = ? AND subject_id = ?", subject, subjectId).Delete(&model.MentionDO{}).Error; err != nil {
+			return err
+		}
+		if len(mentions) > 0 {
+			if err := tx.Create(&mentions).Error; err != nil {
+				return err
+			}
+		}
+
+		seen := make(map[int64]bool, len(existing)+len(mentions))
+		for _, userId := range existing {
+			seen[userId] = true
+		}
+		for _, mention := range mentions {
+			if !seen[mention.UserId] {
+				seen[mention.UserId] = true
+				added = append(added, mention.UserId)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// MentionedPostIdsQuery 正文或评论中提到了用户的帖子ID的子查询
+func (r *MentionRepository) MentionedPostIdsQuery(userId int64) *gorm.DB {
+	return r.DB.Model(&model.MentionDO{}).Select("post_id").Where("user_id = ?", userId)
+}
+
+// loadMentions 批量加载内容中的提及, 带被提及的人当前的公开信息, 按位置排序; 返回以内容ID为键的映射
+func loadMentions(subject model.MentionSubject, subjectIds []int64) (map[int64][]*model.MentionDTO, error) {
+	result := make(map[int64][]*model.MentionDTO, len(subjectIds))
+	if len(subjectIds) == 0 {
+		return result, nil
+	}
+	var mentions []model.MentionDO
+	if err := mentionRepository.DB.Where("subject = ? AND subject_id IN ?", subject, subjectIds).
+		Order("subject_id, start").Find(&mentions).Error; err != nil {
+		return nil, err
+	}
+	if len(mentions) == 0 {
+		return result, nil
+	}
+
+	userIds := make([]int64, len(mentions))
+	for i, mention := range mentions {
+		userIds[i] = mention.UserId
+	}
+	users, err := userRepository.BatchGetUsers(userIds)
+	if err != nil {
+		return nil, err
+	}
+	for _, mention := range mentions {
+		if user, ok := users[mention.UserId]; ok {
+			result[mention.SubjectId] = append(result[mention.SubjectId], &model.MentionDTO{
+				User:   user,
+				Start:  mention.Start,
+				Length: mention.Length,
+			})
+		}
+	}
+	return result, nil
+}
+
+// removeSubjectMentions 删除一条内容中的提及, 用于删除评论
+func removeSubjectMentions(tx *gorm.DB, subject model.MentionSubject, subjectId int64) error {
+	return tx.Where("subject = ? AND subject_id = ?", subject, subjectId).Delete(&model.MentionDO{}).Error
+}
+
+// removePostMentions 删除帖子正文及其评论中的提及, 用于删除帖子
+func removePostMentions(tx *gorm.DB, postIds ...int64) error {
+	if len(postIds) == 0 {
+		return nil
+	}
+	return tx.Where("post_id IN ?", postIds).Delete(&model.MentionDO{}).Error
+}
+
+// removeUserMentions 注销账号时删除对该用户的提及; 用户写下的提及在 erase 模式下随内容删除, 否则保留并不再关联到该用户
+func removeUserMentions(tx *gorm.DB, userId int64, mode model.DeletionMode) error {
+	if err := tx.Where("user_id = ?", userId).Delete(&model.MentionDO{}).Error; err != nil {
+		return err
+	}
+	if mode == model.DeletionModeErase {
+		return tx.Where("author_id = ?", userId).Delete(&model.MentionDO{}).Error
+	}
+	return tx.Model(&model.MentionDO{}).Where("author_id = ?", userId).Update("author_id", 0).Error
+}
@@ -0,0 +1,269 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"yujian-backend/pkg/model"
+)
+
+var messageRepository MessageRepository
+
+// MessageRepository 私信会话和消息
+type MessageRepository struct {
+	DB *gorm.DB
+}
+
+func GetMessageRepository() *MessageRepository {
+	return &messageRepository
+}
+
+// conversationPair 把两个用户ID排成会话表中的顺序
+func conversationPair(a, b int64) (int64, int64) {
+	if a < b {
+		return a, b
+	}
+	return b, a
+}
+
+// SendMessage 发送私信, 两人之间还没有会话时先创建会话
+// 消息、双方的最新消息和接收方的未读数在同一事务中更新; 发送方自己发的消息视为已读
+func (r *MessageRepository) SendMessage(messageDTO *model.MessageDTO) (*model.MessageDO, error) {
+	message := messageDTO.TransformToDO()
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		low, high := conversationPair(message.SenderId, message.RecipientId)
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.ConversationDO{UserLowId: low, UserHighId: high, CreateTime: now}).Error; err != nil {
+			return err
+		}
+		// 会话已存在时插入被忽略, 统一按用户对查出会话ID
+		var conversation model.ConversationDO
+		if err := tx.Where("user_low_id = ? AND user_high_id = ?", low, high).Take(&conversation).Error; err != nil {
+			return err
+		}
+		for _, member := range []*model.ConversationMemberDO{
+			{ConversationId: conversation.Id, UserId: message.SenderId, PeerId: message.RecipientId, UpdateTime: now},
+			{ConversationId: conversation.Id, UserId: message.RecipientId, PeerId: message.SenderId, UpdateTime: now},
+		} {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(member).Error; err != nil {
+				return err
+			}
+		}
+
+		message.ConversationId = conversation.Id
+		message.CreateTime = now
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&model.ConversationMemberDO{}).
+			Where("conversation_id = ? AND user_id = ?", conversation.Id, message.SenderId).
+			Updates(map[string]interface{}{
+				"last_message_id":      message.Id,
+				"last_read_message_id": message.Id,
+				"unread_count":         0,
+				"update_time":          now,
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.ConversationMemberDO{}).
+			Where("conversation_id = ? AND user_id = ?", conversation.Id, message.RecipientId).
+			Updates(map[string]interface{}{
+				"last_message_id": message.Id,
+				"unread_count":    gorm.Expr("unread_count + 1"),
+				"update_time":     now,
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// HasSentTo senderId 是否给 recipientId 发过私信
+func (r *MessageRepository) HasSentTo(senderId, recipientId int64) (bool, error) {
+	var count int64
+	err := r.DB.Model(&model.MessageDO{}).Where("sender_id = ? AND recipient_id = ?", senderId, recipientId).
+		Limit(1).Count(&count).Error
+	return count > 0, err
+}
+
+// GetMember 获取用户在会话中的状态, 不是会话成员时返回 gorm.ErrRecordNotFound
+func (r *MessageRepository) GetMember(conversationId, userId int64) (*model.ConversationMemberDO, error) {
+	var member model.ConversationMemberDO
+	if err := r.DB.Where("conversation_id = ? AND user_id = ?", conversationId, userId).Take(&member).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// GetPeerLastRead 获取会话中另一方已读到的消息ID, 对方已注销时为0
+func (r *MessageRepository) GetPeerLastRead(conversationId, userId int64) (int64, error) {
+	var lastRead []int64
+	err := r.DB.Model(&model.ConversationMemberDO{}).
+		Where("conversation_id = ? AND user_id <> ?", conversationId, userId).
+		Limit(1).Pluck("last_read_message_id", &lastRead).Error
+	if err != nil || len(lastRead) == 0 {
+		return 0, err
+	}
+	return lastRead[0], nil
+}
+
+// ListConversations 按最近消息倒序获取用户的会话, cursor 为上一页最后一个会话的 last_message_id, 为0时从最新开始
+func (r *MessageRepository) ListConversations(userId int64, cursor int64, limit int) ([]*model.ConversationMemberDO, error) {
+	query := r.DB.Where("user_id = ?", userId)
+	if cursor > 0 {
+		query = query.Where("last_message_id < ?", cursor)
+	}
+	var members []*model.ConversationMemberDO
+	if err := query.Order("last_message_id DESC").Limit(limit).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// BatchGetPeerLastRead 批量获取会话中另一方已读到的消息ID, 返回以会话ID为键的映射
+func (r *MessageRepository) BatchGetPeerLastRead(conversationIds []int64, userId int64) (map[int64]int64, error) {
+	result := make(map[int64]int64, len(conversationIds))
+	if len(conversationIds) == 0 {
+		return result, nil
+	}
+	var peers []model.ConversationMemberDO
+	if err := r.DB.Select("conversation_id", "last_read_message_id").
+		Where("conversation_id IN ? AND user_id <> ?", conversationIds, userId).
+		Find(&peers).Error; err != nil {
+		return nil, err
+	}
+	for _, peer := range peers {
+		result[peer.ConversationId] = peer.LastReadMessageId
+	}
+	return result, nil
+}
+
+// BatchGetMessages 批量获取消息, 返回以消息ID为键的映射, 不存在的消息不在结果中
+func (r *MessageRepository) BatchGetMessages(ids []int64) (map[int64]*model.MessageDO, error) {
+	result := make(map[int64]*model.MessageDO, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+	var messages []*model.MessageDO
+	if err := r.DB.Where("id IN ?", ids).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	for _, message := range messages {
+		result[message.Id] = message
+	}
+	return result, nil
+}
+
+// ListMessages 按ID倒序获取会话中的消息, cursor 为上一页最后一条消息的ID, 为0时从最新开始
+func (r *MessageRepository) ListMessages(conversationId int64, cursor int64, limit int) ([]*model.MessageDO, error) {
+	query := r.DB.Where("conversation_id = ?", conversationId)
+	if cursor > 0 {
+		query = query.Where("id < ?", cursor)
+	}
+	var messages []*model.MessageDO
+	if err := query.Order("id DESC").Limit(limit).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// MarkRead 把会话标记为已读到 messageId, messageId 为0时标记到最新一条; 已读位置只前进不后退
+// 未读数按已读位置之后对方发来的消息重新统计, 返回更新后的状态
+func (r *MessageRepository) MarkRead(conversationId, userId, messageId int64) (*model.ConversationMemberDO, error) {
+	var member model.ConversationMemberDO
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("conversation_id = ? AND user_id = ?", conversationId, userId).Take(&member).Error; err != nil {
+			return err
+		}
+		if messageId <= 0 || messageId > member.LastMessageId {
+			messageId = member.LastMessageId
+		}
+		if messageId > member.LastReadMessageId {
+			member.LastReadMessageId = messageId
+		}
+		if err := tx.Model(&model.MessageDO{}).
+			Where("conversation_id = ? AND recipient_id = ? AND id > ?", conversationId, userId, member.LastReadMessageId).
+			Count(&member.UnreadCount).Error; err != nil {
+			return err
+		}
+		return tx.Model(&member).Updates(map[string]interface{}{
+			"last_read_message_id": member.LastReadMessageId,
+			"unread_count":         member.UnreadCount,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// CountUnread 统计用户全部会话的未读消息数
+func (r *MessageRepository) CountUnread(userId int64) (int64, error) {
+	var total int64
+	err := r.DB.Model(&model.ConversationMemberDO{}).Where("user_id = ?", userId).
+		Select("COALESCE(SUM(unread_count), 0)").Scan(&total).Error
+	return total, err
+}
+
+// ListUserMessages 获取用户发出和收到的全部私信, 按ID排序, 用于导出
+func (r *MessageRepository) ListUserMessages(userId int64) ([]*model.MessageDTO, error) {
+	var messages []model.MessageDO
+	if err := r.DB.Where("sender_id = ? OR recipient_id = ?", userId, userId).Order("id").
+		Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	messageDTOs := make([]*model.MessageDTO, len(messages))
+	for i, message := range messages {
+		messageDTOs[i] = message.TransformToDTO(0)
+	}
+	return messageDTOs, nil
+}
+
+// removeUserConversations 注销账号时退出用户的全部会话
+// erase 模式删除用户发出的消息, 否则保留给对方查看; 双方都已退出的会话连同消息一起删除
+func removeUserConversations(tx *gorm.DB, userId int64, mode model.DeletionMode) error {
+	var conversationIds []int64
+	if err := tx.Model(&model.ConversationMemberDO{}).Where("user_id = ?", userId).
+		Pluck("conversation_id", &conversationIds).Error; err != nil {
+		return err
+	}
+	if len(conversationIds) == 0 {
+		return nil
+	}
+	if err := tx.Where("user_id = ?", userId).Delete(&model.ConversationMemberDO{}).Error; err != nil {
+		return err
+	}
+	if mode == model.DeletionModeErase {
+		if err := tx.Where("sender_id = ?", userId).Delete(&model.MessageDO{}).Error; err != nil {
+			return err
+		}
+	}
+
+	var remaining []int64
+	if err := tx.Model(&model.ConversationMemberDO{}).Where("conversation_id IN ?", conversationIds).
+		Distinct().Pluck("conversation_id", &remaining).Error; err != nil {
+		return err
+	}
+	alive := make(map[int64]bool, len(remaining))
+	for _, id := range remaining {
+		alive[id] = true
+	}
+	var empty []int64
+	for _, id := range conversationIds {
+		if !alive[id] {
+			empty = append(empty, id)
+		}
+	}
+	if len(empty) == 0 {
+		return nil
+	}
+	if err := tx.Where("conversation_id IN ?", empty).Delete(&model.MessageDO{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("id IN ?", empty).Delete(&model.ConversationDO{}).Error
+}
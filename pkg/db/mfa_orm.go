@@ -0,0 +1,77 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"yujian-backend/pkg/model"
+)
+
+var mfaRepository MFARepository
+
+type MFARepository struct {
+	DB *gorm.DB
+}
+
+func GetMFARepository() *MFARepository {
+	return &mfaRepository
+}
+
+// GetMFA 获取用户的二次验证设置
+func (r *MFARepository) GetMFA(userId int64) (*model.UserMFADO, error) {
+	var mfa model.UserMFADO
+	if err := r.DB.Where("user_id = ?", userId).First(&mfa).Error; err != nil {
+		return nil, err
+	}
+	return &mfa, nil
+}
+
+// SaveMFA 保存二次验证设置, 已存在时覆盖
+func (r *MFARepository) SaveMFA(mfa *model.UserMFADO) error {
+	return r.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(mfa).Error
+}
+
+// EnableMFA 启用二次验证并替换全部恢复码
+func (r *MFARepository) EnableMFA(userId int64, step int64, codeHashes []string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.UserMFADO{}).Where("user_id = ?", userId).
+			Updates(map[string]interface{}{"enabled": true, "last_step": step}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userId).Delete(&model.RecoveryCodeDO{}).Error; err != nil {
+			return err
+		}
+		codes := make([]*model.RecoveryCodeDO, len(codeHashes))
+		for i, hash := range codeHashes {
+			codes[i] = &model.RecoveryCodeDO{UserId: userId, CodeHash: hash}
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// DeleteMFA 关闭二次验证, 删除密钥和恢复码
+func (r *MFARepository) DeleteMFA(userId int64) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userId).Delete(&model.RecoveryCodeDO{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userId).Delete(&model.UserMFADO{}).Error
+	})
+}
+
+// AdvanceStep 记录通过校验的时间步, 时间步没有前进时返回 false, 说明验证码已被使用过
+func (r *MFARepository) AdvanceStep(userId int64, step int64) (bool, error) {
+	result := r.DB.Model(&model.UserMFADO{}).
+		Where("user_id = ? AND last_step < ?", userId, step).
+		Update("last_step", step)
+	return result.RowsAffected > 0, result.Error
+}
+
+// UseRecoveryCode 消耗一个恢复码, 不存在或已使用时返回 false
+func (r *MFARepository) UseRecoveryCode(userId int64, codeHash string) (bool, error) {
+	result := r.DB.Model(&model.RecoveryCodeDO{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userId, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+var notificationRepository NotificationRepository
+
+// NotificationRepository 站内通知
+type NotificationRepository struct {
+	DB *gorm.DB
+}
+
+func GetNotificationRepository() *NotificationRepository {
+	return &notificationRepository
+}
+
+// AddNotification 为 actorId 触发的事件生成通知, notification 给出接收者、类型、对象和摘要
+// 接收者有同类型、同对象的未读通知时合并进去, 否则新建一条; 同一个人重复触发只计一次
+// 返回写入后的通知, 通知没有变化时(例如取消后重新点赞)返回 nil
+func (r *NotificationRepository) AddNotification(notification *model.NotificationDO, actorId int64) (*model.NotificationDO, error) {
+	var saved *model.NotificationDO
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var existing model.NotificationDO
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND type = ? AND subject_id = ? AND is_read = ?",
+				notification.UserId, notification.Type, notification.SubjectId, false).
+			Order("id DESC").Take(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			notification.ActorCount = 1
+			notification.RecentActorIds = utils.MustToJSONString([]int64{actorId})
+			notification.Read = false
+			notification.CreateTime = now
+			notification.UpdateTime = now
+			if err := tx.Create(notification).Error; err != nil {
+				return err
+			}
+			saved = notification
+			return tx.Create(&model.NotificationActorDO{
+				NotificationId: notification.Id,
+				ActorId:        actorId,
+				CreateTime:     now,
+			}).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.NotificationActorDO{
+			NotificationId: existing.Id,
+			ActorId:        actorId,
+			CreateTime:     now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		newActor := result.RowsAffected > 0
+		// 已经计入的人再次点赞不算新消息; 再次评论则刷新摘要
+		if !newActor && notification.Preview == "" {
+			return nil
+		}
+
+		updates := map[string]interface{}{
+			"recent_actor_ids": utils.MustToJSONString(pushRecentActor(existing.RecentActors(), actorId)),
+			"update_time":      now,
+		}
+		if newActor {
+			updates["actor_count"] = gorm.Expr("actor_count + 1")
+		}
+		if notification.Preview != "" {
+			updates["preview"] = notification.Preview
+		}
+		if err := tx.Model(&existing).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&existing, existing.Id).Error; err != nil {
+			return err
+		}
+		saved = &existing
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// pushRecentActor 把 actorId 放到最近触发者的最前面, 最多保留 model.MaxRecentActors 个
+func pushRecentActor(ids []int64, actorId int64) []int64 {
+	recent := make([]int64, 0, model.MaxRecentActors)
+	recent = append(recent, actorId)
+	for _, id := range ids {
+		if len(recent) == model.MaxRecentActors {
+			break
+		}
+		if id != actorId {
+			recent = append(recent, id)
+		}
+	}
+	return recent
+}
+
+// ListNotifications 分页获取用户的通知, 按最近更新时间倒序; unreadOnly 为 true 时只返回未读通知, limit 为 -1 时不分页
+func (r *NotificationRepository) ListNotifications(userId int64, unreadOnly bool, offset, limit int) ([]*model.NotificationDO, int64, error) {
+	query := r.DB.Model(&model.NotificationDO{}).Where("user_id = ?", userId)
+	if unreadOnly {
+		query = query.Where("is_read = ?", false)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var notifications []*model.NotificationDO
+	if err := query.Order("update_time DESC, id DESC").Offset(offset).Limit(limit).
+		Find(&notifications).Error; err != nil {
+		return nil, 0, err
+	}
+	return notifications, total, nil
+}
+
+// CountUnread 统计用户的未读通知数
+func (r *NotificationRepository) CountUnread(userId int64) (int64, error) {
+	var count int64
+	err := r.DB.Model(&model.NotificationDO{}).Where("user_id = ? AND is_read = ?", userId, false).Count(&count).Error
+	return count, err
+}
+
+// SetRead 把用户的一条通知标为已读或未读, 通知不存在或不属于该用户时返回 gorm.ErrRecordNotFound
+func (r *NotificationRepository) SetRead(userId, id int64, read bool) error {
+	var notification model.NotificationDO
+	if err := r.DB.Select("id").Where("id = ? AND user_id = ?", id, userId).Take(&notification).Error; err != nil {
+		return err
+	}
+	return r.DB.Model(&notification).Update("is_read", read).Error
+}
+
+// MarkAllRead 把用户的全部未读通知标为已读, 返回更新的条数
+func (r *NotificationRepository) MarkAllRead(userId int64) (int64, error) {
+	result := r.DB.Model(&model.NotificationDO{}).Where("user_id = ? AND is_read = ?", userId, false).Update("is_read", true)
+	return result.RowsAffected, result.Error
+}
+
+// removePostNotifications 删除与帖子及其评论有关的通知, 用于删除帖子
+func removePostNotifications(tx *gorm.DB, postId int64) error {
+	return removeNotifications(tx, tx.Model(&model.NotificationDO{}).Where("post_id = ?", postId))
+}
+
+// removeSubjectNotifications 删除指向某个对象的通知, 用于删除评论和书评
+func removeSubjectNotifications(tx *gorm.DB, subjectId int64, types ...model.NotificationType) error {
+	return removeNotifications(tx, tx.Model(&model.NotificationDO{}).Where("type IN ? AND subject_id = ?", types, subjectId))
+}
+
+// removeNotifications 删除 query 选中的通知及其触发者记录
+func removeNotifications(tx *gorm.DB, query *gorm.DB) error {
+	var ids []int64
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Where("notification_id IN ?", ids).Delete(&model.NotificationActorDO{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("id IN ?", ids).Delete(&model.NotificationDO{}).Error
+}
+
+// removeUserNotifications 删除用户收到的通知, 并把用户从别人的通知中去掉, 用于注销账号
+// 去掉后没有触发者的通知一并删除; 摘要可能来自该用户, 统一清空
+func removeUserNotifications(tx *gorm.DB, userId int64) error {
+	if err := removeNotifications(tx, tx.Model(&model.NotificationDO{}).Where("user_id = ?", userId)); err != nil {
+		return err
+	}
+
+	var ids []int64
+	if err := tx.Model(&model.NotificationActorDO{}).Where("actor_id = ?", userId).
+		Pluck("notification_id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Where("actor_id = ?", userId).Delete(&model.NotificationActorDO{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&model.NotificationDO{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+		"actor_count": gorm.Expr("actor_count - 1"),
+		"preview":     "",
+	}).Error; err != nil {
+		return err
+	}
+	return removeNotifications(tx, tx.Model(&model.NotificationDO{}).Where("id IN ? AND actor_count <= 0", ids))
+}
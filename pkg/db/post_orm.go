@@ -1,6 +1,8 @@
 package db
 
 import (
+	"errors"
+
 	"gorm.io/gorm"
 	"yujian-backend/pkg/model"
 )
@@ -31,58 +33,170 @@ func (r *PostRepository) GetPostById(id int64) (*model.PostDTO, error) {
 		return nil, err
 	}
 
-	userDTO, err := userRepository.GetUserById(post.AuthorId)
+	author, err := postAuthor(&post)
 	if err != nil {
 		return nil, err
 	}
 
-	comments, err := r.GetPostCommentsByPostId(post.Id)
+	comments, err := r.GetPostCommentsByPostId(post.Id, 0)
 	if err != nil {
 		return nil, err
 	}
 
-	return post.TransformToDTO(userDTO, comments), nil
+	mentions, err := loadMentions(model.MentionInPost, []int64{post.Id})
+	if err != nil {
+		return nil, err
+	}
+	postDTO := post.TransformToDTO(author, comments)
+	postDTO.Mentions = mentions[post.Id]
+	return postDTO, nil
 }
 
-// UpdatePost 更新帖子
+// postAuthor 获取帖子作者的公开信息, 作者已注销或已匿名时返回占位用户
+func postAuthor(post *model.PostDO) (*model.UserDTO, error) {
+	if post.AuthorId == 0 {
+		return model.DeletedUser(), nil
+	}
+	userDTO, err := userRepository.GetUserById(post.AuthorId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.DeletedUser(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return userDTO.Public(), nil
+}
+
+// GetPostDOById 根据ID获取帖子本身, 不加载作者和评论
+func (r *PostRepository) GetPostDOById(id int64) (*model.PostDO, error) {
+	var post model.PostDO
+	if err := r.DB.First(&post, id).Error; err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+// UpdatePost 更新帖子, 只更新标题、内容和编辑时间
 func (r *PostRepository) UpdatePost(postDTO *model.PostDTO) error {
 	postDO := postDTO.TransformToDO()
-	return r.DB.Save(postDO).Error
+	return r.DB.Model(postDO).Select("title", "content_id", "edit_time").Updates(postDO).Error
 }
 
-// DeletePost 删除帖子
+// DeletePost 删除帖子及其评论、动态、通知和提及
 func (r *PostRepository) DeletePost(id int64) error {
-	return r.DB.Delete(&model.PostDO{}, id).Error
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("post_id = ?", id).Delete(&model.PostCommentDO{}).Error; err != nil {
+			return err
+		}
+		if err := removePostMentions(tx, id); err != nil {
+			return err
+		}
+		if err := removeSubjectEvents(tx, model.ActivityPost, id); err != nil {
+			return err
+		}
+		if err := removePostNotifications(tx, id); err != nil {
+			return err
+		}
+		return tx.Delete(&model.PostDO{}, id).Error
+	})
+}
+
+// ListPosts 获取帖子列表, viewerId 不为0时过滤掉该用户屏蔽的人发布的帖子和评论
+// 私密和只邀请的读书会里的帖子只有成员能看到
+func (r *PostRepository) ListPosts(viewerId int64, offset, limit int) ([]*model.PostDTO, error) {
+	return r.listPosts(visiblePosts(r.DB, viewerId), viewerId, offset, limit)
+}
+
+// ListGroupPosts 获取读书会内的帖子列表, 分页和屏蔽过滤与 ListPosts 相同; 调用方负责校验 viewerId 能否查看
+func (r *PostRepository) ListGroupPosts(groupId, viewerId int64, offset, limit int) ([]*model.PostDTO, error) {
+	return r.listPosts(r.DB.Where("group_id = ?", groupId), viewerId, offset, limit)
 }
 
-// ListPosts 获取帖子列表
-func (r *PostRepository) ListPosts(offset, limit int) ([]*model.PostDTO, error) {
+// ListMentionedPosts 分页获取正文或评论中提到了用户的帖子, 按发布时间倒序; 分页、屏蔽和读书会可见性过滤与 ListPosts 相同
+func (r *PostRepository) ListMentionedPosts(userId int64, offset, limit int) ([]*model.PostDTO, error) {
+	query := r.DB.Where("id IN (?)", mentionRepository.MentionedPostIdsQuery(userId)).Order("id DESC")
+	return r.listPosts(visiblePosts(query, userId), userId, offset, limit)
+}
+
+// listPosts 分页获取 query 选中的帖子, 过滤掉 viewerId 屏蔽的人发布的帖子和评论
+func (r *PostRepository) listPosts(query *gorm.DB, viewerId int64, offset, limit int) ([]*model.PostDTO, error) {
 	var posts []model.PostDO
-	if err := r.DB.Offset(offset).Limit(limit).Find(&posts).Error; err != nil {
+	if err := excludeMuted(query, viewerId).Offset(offset).Limit(limit).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+
+	return r.assemblePosts(posts, viewerId)
+}
+
+// ListFollowingPosts 分页获取用户关注的人发布的帖子, 按发布时间倒序, 同样过滤屏蔽的人和看不到的读书会帖子
+// 通过关注关系子查询和 post.author_id 索引过滤, 不需要先把关注列表取到内存
+func (r *PostRepository) ListFollowingPosts(userId int64, offset, limit int) ([]*model.PostDTO, error) {
+	var posts []model.PostDO
+	query := visiblePosts(r.DB.Where("author_id IN (?)", followRepository.FolloweeIdsQuery(userId)), userId)
+	if err := excludeMuted(query, userId).
+		Order("id DESC").Offset(offset).Limit(limit).
+		Find(&posts).Error; err != nil {
+		return nil, err
+	}
+
+	return r.assemblePosts(posts, userId)
+}
+
+// assemblePosts 为帖子加载作者、评论和正文中的提及, 评论按 viewerId 的屏蔽关系过滤
+func (r *PostRepository) assemblePosts(posts []model.PostDO, viewerId int64) ([]*model.PostDTO, error) {
+	postIds := make([]int64, len(posts))
+	for i, post := range posts {
+		postIds[i] = post.Id
+	}
+	mentions, err := loadMentions(model.MentionInPost, postIds)
+	if err != nil {
 		return nil, err
 	}
 
 	postDTOs := make([]*model.PostDTO, len(posts))
 	for i, post := range posts {
-		userDTO, err := userRepository.GetUserById(post.AuthorId)
+		author, err := postAuthor(&post)
 		if err != nil {
 			return nil, err
 		}
 
-		comments, err := r.GetPostCommentsByPostId(post.Id)
+		comments, err := r.GetPostCommentsByPostId(post.Id, viewerId)
 		if err != nil {
 			return nil, err
 		}
 
-		postDTOs[i] = post.TransformToDTO(userDTO, comments)
+		postDTOs[i] = post.TransformToDTO(author, comments)
+		postDTOs[i].Mentions = mentions[post.Id]
 	}
 	return postDTOs, nil
 }
 
-// GetPostCommentsByPostId 根据帖子id获取帖子评论
-func (r *PostRepository) GetPostCommentsByPostId(postId int64) ([]*model.PostCommentDTO, error) {
+// excludeMuted 排除 viewerId 屏蔽的人发布的内容, 要求表中有 author_id 列; viewerId 为0时不过滤
+func excludeMuted(query *gorm.DB, viewerId int64) *gorm.DB {
+	return excludeMutedBy(query, "author_id", viewerId)
+}
+
+// excludeMutedBy 排除 column 为 viewerId 屏蔽的人的记录
+func excludeMutedBy(query *gorm.DB, column string, viewerId int64) *gorm.DB {
+	if viewerId == 0 {
+		return query
+	}
+	return query.Where(column+" NOT IN (?)", relationRepository.MutedIdsQuery(viewerId))
+}
+
+// GetPostCommentsByPostId 根据帖子id获取帖子评论及其中的提及, viewerId 不为0时过滤掉该用户屏蔽的人发表的评论
+func (r *PostRepository) GetPostCommentsByPostId(postId int64, viewerId int64) ([]*model.PostCommentDTO, error) {
 	var comments []model.PostCommentDO
-	if err := r.DB.Where("post_id = ?", postId).Find(&comments).Error; err != nil {
+	if err := excludeMuted(r.DB.Where("post_id = ?", postId), viewerId).Find(&comments).Error; err != nil {
+		return nil, err
+	}
+
+	commentIds := make([]int64, len(comments))
+	for i, comment := range comments {
+		commentIds[i] = comment.Id
+	}
+	mentions, err := loadMentions(model.MentionInPostComment, commentIds)
+	if err != nil {
 		return nil, err
 	}
 
@@ -90,6 +204,7 @@ func (r *PostRepository) GetPostCommentsByPostId(postId int64) ([]*model.PostCom
 	postCommentDTOs := make([]*model.PostCommentDTO, len(comments))
 	for i, comment := range comments {
 		postCommentDTOs[i] = comment.TransformToDTO()
+		postCommentDTOs[i].Mentions = mentions[comment.Id]
 	}
 	return postCommentDTOs, nil
 }
@@ -108,3 +223,64 @@ func (r *PostRepository) BatchGetPostCommentById(ids []int64) ([]*model.PostComm
 	}
 	return postCommentDTOs, nil
 }
+
+// GetPostCommentById 根据ID获取帖子评论
+func (r *PostRepository) GetPostCommentById(id int64) (*model.PostCommentDTO, error) {
+	var comment model.PostCommentDO
+	if err := r.DB.First(&comment, id).Error; err != nil {
+		return nil, err
+	}
+	return comment.TransformToDTO(), nil
+}
+
+// CreatePostComment 发表帖子评论
+func (r *PostRepository) CreatePostComment(commentDTO *model.PostCommentDTO) (int64, error) {
+	commentDO := commentDTO.TransformToDO()
+	if err := r.DB.Create(commentDO).Error; err != nil {
+		return 0, err
+	}
+	return commentDO.Id, nil
+}
+
+// UpdatePostComment 更新帖子评论, 只更新内容和编辑时间
+func (r *PostRepository) UpdatePostComment(commentDTO *model.PostCommentDTO) error {
+	commentDO := commentDTO.TransformToDO()
+	return r.DB.Model(commentDO).Select("content", "edit_time").Updates(commentDO).Error
+}
+
+// DeletePostComment 删除帖子评论及指向它的回复、点赞和提及通知, 以及评论中的提及
+func (r *PostRepository) DeletePostComment(id int64) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := removeSubjectNotifications(tx, id, model.NotifyCommentReply, model.NotifyPostCommentLike,
+			model.NotifyCommentMention); err != nil {
+			return err
+		}
+		if err := removeSubjectMentions(tx, model.MentionInPostComment, id); err != nil {
+			return err
+		}
+		return tx.Delete(&model.PostCommentDO{}, id).Error
+	})
+}
+
+// GetPostsByAuthorId 获取作者发布的全部帖子
+func (r *PostRepository) GetPostsByAuthorId(authorId int64) ([]*model.PostDO, error) {
+	var posts []*model.PostDO
+	if err := r.DB.Where("author_id = ?", authorId).Order("id").Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// GetPostCommentsByAuthorId 获取作者发表的全部帖子评论
+func (r *PostRepository) GetPostCommentsByAuthorId(authorId int64) ([]*model.PostCommentDTO, error) {
+	var comments []model.PostCommentDO
+	if err := r.DB.Where("author_id = ?", authorId).Order("id").Find(&comments).Error; err != nil {
+		return nil, err
+	}
+
+	postCommentDTOs := make([]*model.PostCommentDTO, len(comments))
+	for i, comment := range comments {
+		postCommentDTOs[i] = comment.TransformToDTO()
+	}
+	return postCommentDTOs, nil
+}
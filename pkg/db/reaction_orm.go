@@ -0,0 +1,88 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"yujian-backend/pkg/model"
+	"yujian-backend/pkg/utils"
+)
+
+// reactionCounts 按表态后的点赞、点踩人数补充需要同步更新的列
+type reactionCounts func(updates map[string]interface{}, likes, dislikes int)
+
+// bookCommentCounts 书评冗余存储点赞、点踩数
+func bookCommentCounts(updates map[string]interface{}, likes, dislikes int) {
+	updates["like"] = likes
+	updates["dislike"] = dislikes
+}
+
+// postCommentScore 帖子评论的分数为点赞数减点踩数
+func postCommentScore(updates map[string]interface{}, likes, dislikes int) {
+	updates["score"] = likes - dislikes
+}
+
+// SetPostReaction 设置用户对帖子的点赞点踩, 并按 points 记录作者的声望; 帖子不存在时返回 gorm.ErrRecordNotFound
+func (r *PostRepository) SetPostReaction(postId, userId int64, reaction model.Reaction, points model.VotePoints) (*model.ReactionResultDTO, error) {
+	return setReaction(r.DB, &model.PostDO{}, model.ReputationPost, postId, userId, reaction, points, nil)
+}
+
+// SetPostCommentReaction 设置用户对帖子评论的点赞点踩, 同步更新评论的分数
+func (r *PostRepository) SetPostCommentReaction(commentId, userId int64, reaction model.Reaction, points model.VotePoints) (*model.ReactionResultDTO, error) {
+	return setReaction(r.DB, &model.PostCommentDO{}, model.ReputationPostComment, commentId, userId, reaction, points, postCommentScore)
+}
+
+// SetBookCommentReaction 设置用户对书评的点赞点踩, 同步维护 like/dislike 计数
+func (r *BookRepository) SetBookCommentReaction(commentId, userId int64, reaction model.Reaction, points model.VotePoints) (*model.ReactionResultDTO, error) {
+	return setReaction(r.DB, &model.BookCommentDO{}, model.ReputationBookComment, commentId, userId, reaction, points, bookCommentCounts)
+}
+
+// setReaction 在事务中修改 value 对应表中一行的点赞点踩列表, 并在同一事务中记录内容作者的声望流水
+// 先锁住该行再读改写, 避免并发表态互相覆盖JSON数组; 用户只能处于点赞、点踩或都没有其中之一
+// counts 不为 nil 时按列表长度重写对应的计数列
+func setReaction(db *gorm.DB, value interface{}, subject model.ReputationSubject, id, userId int64,
+	reaction model.Reaction, points model.VotePoints, counts reactionCounts) (*model.ReactionResultDTO, error) {
+	result := &model.ReactionResultDTO{Reaction: reaction}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var row reactionRow
+		if err := tx.Model(value).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "author_id", "like_user_ids", "dislike_user_ids").
+			Where("id = ?", id).Take(&row).Error; err != nil {
+			return err
+		}
+
+		likes, liked := removeUserId(row.LikeUserIds, userId)
+		dislikes, disliked := removeUserId(row.DislikeUserIds, userId)
+		switch {
+		case liked:
+			result.Previous = model.ReactionLike
+		case disliked:
+			result.Previous = model.ReactionDislike
+		}
+		switch reaction {
+		case model.ReactionLike:
+			likes = append(likes, userId)
+		case model.ReactionDislike:
+			dislikes = append(dislikes, userId)
+		}
+		result.Likes, result.Dislikes = len(likes), len(dislikes)
+		if result.Previous == reaction {
+			return nil
+		}
+
+		updates := map[string]interface{}{
+			"like_user_ids":    utils.MustToJSONString(likes),
+			"dislike_user_ids": utils.MustToJSONString(dislikes),
+		}
+		if counts != nil {
+			counts(updates, len(likes), len(dislikes))
+		}
+		if err := tx.Model(value).Where("id = ?", id).Updates(updates).Error; err != nil {
+			return err
+		}
+		return recordVote(tx, subject, id, row.AuthorId, userId, result.Previous, reaction, points)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"yujian-backend/pkg/model"
+)
+
+var relationRepository RelationRepository
+
+// RelationRepository 拉黑和屏蔽关系
+type RelationRepository struct {
+	DB *gorm.DB
+}
+
+func GetRelationRepository() *RelationRepository {
+	return &relationRepository
+}
+
+// Block 拉黑用户, 同时解除双方之间的关注关系, 已经拉黑时不做任何事
+func (r *RelationRepository) Block(userId, targetId int64) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.BlockDO{
+			UserId:     userId,
+			TargetId:   targetId,
+			CreateTime: time.Now(),
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		if _, err := unfollowTx(tx, userId, targetId); err != nil {
+			return err
+		}
+		_, err := unfollowTx(tx, targetId, userId)
+		return err
+	})
+}
+
+// Unblock 取消拉黑, 不会恢复拉黑时解除的关注关系
+func (r *RelationRepository) Unblock(userId, targetId int64) error {
+	return r.DB.Where("user_id = ? AND target_id = ?", userId, targetId).Delete(&model.BlockDO{}).Error
+}
+
+// IsBlocked userId 是否拉黑了 targetId
+func (r *RelationRepository) IsBlocked(userId, targetId int64) (bool, error) {
+	var count int64
+	err := r.DB.Model(&model.BlockDO{}).Where("user_id = ? AND target_id = ?", userId, targetId).Count(&count).Error
+	return count > 0, err
+}
+
+// BlockerIdsQuery 返回拉黑了 userId 的人的ID子查询
+func (r *RelationRepository) BlockerIdsQuery(userId int64) *gorm.DB {
+	return r.DB.Model(&model.BlockDO{}).Select("user_id").Where("target_id = ?", userId)
+}
+
+// IsMuted userId 是否屏蔽了 targetId
+func (r *RelationRepository) IsMuted(userId, targetId int64) (bool, error) {
+	var count int64
+	err := r.DB.Model(&model.MuteDO{}).Where("user_id = ? AND target_id = ?", userId, targetId).Count(&count).Error
+	return count > 0, err
+}
+
+// Mute 屏蔽用户, 已经屏蔽时不做任何事
+func (r *RelationRepository) Mute(userId, targetId int64) error {
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.MuteDO{
+		UserId:     userId,
+		TargetId:   targetId,
+		CreateTime: time.Now(),
+	}).Error
+}
+
+// Unmute 取消屏蔽
+func (r *RelationRepository) Unmute(userId, targetId int64) error {
+	return r.DB.Where("user_id = ? AND target_id = ?", userId, targetId).Delete(&model.MuteDO{}).Error
+}
+
+// MutedIdsQuery 返回用户屏蔽的人的ID子查询, 用于在帖子和评论查询中过滤
+func (r *RelationRepository) MutedIdsQuery(userId int64) *gorm.DB {
+	return r.DB.Model(&model.MuteDO{}).Select("target_id").Where("user_id = ?", userId)
+}
+
+// MutedIds 获取用户屏蔽的全部用户ID
+func (r *RelationRepository) MutedIds(userId int64) ([]int64, error) {
+	var ids []int64
+	err := r.MutedIdsQuery(userId).Pluck("target_id", &ids).Error
+	return ids, err
+}
+
+// ListBlocked 分页获取用户拉黑的人, 按拉黑时间倒序, limit 为 -1 时不分页
+func (r *RelationRepository) ListBlocked(userId int64, offset, limit int) ([]*model.RelationUserDTO, int64, error) {
+	var blocks []model.BlockDO
+	total, err := r.listRelations(&model.BlockDO{}, &blocks, userId, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	targetIds := make([]int64, len(blocks))
+	times := make([]time.Time, len(blocks))
+	for i, block := range blocks {
+		targetIds[i], times[i] = block.TargetId, block.CreateTime
+	}
+	users, err := r.relationUsers(targetIds, times)
+	return users, total, err
+}
+
+// ListMuted 分页获取用户屏蔽的人, 按屏蔽时间倒序
+func (r *RelationRepository) ListMuted(userId int64, offset, limit int) ([]*model.RelationUserDTO, int64, error) {
+	var mutes []model.MuteDO
+	total, err := r.listRelations(&model.MuteDO{}, &mutes, userId, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	targetIds := make([]int64, len(mutes))
+	times := make([]time.Time, len(mutes))
+	for i, mute := range mutes {
+		targetIds[i], times[i] = mute.TargetId, mute.CreateTime
+	}
+	users, err := r.relationUsers(targetIds, times)
+	return users, total, err
+}
+
+// listRelations 分页查询 value 对应表中 userId 的记录, limit 为 -1 时不分页
+func (r *RelationRepository) listRelations(value interface{}, dest interface{}, userId int64, offset, limit int) (int64, error) {
+	var total int64
+	if err := r.DB.Model(value).Where("user_id = ?", userId).Count(&total).Error; err != nil {
+		return 0, err
+	}
+	err := r.DB.Where("user_id = ?", userId).Order("create_time DESC, id DESC").
+		Offset(offset).Limit(limit).Find(dest).Error
+	return total, err
+}
+
+// relationUsers 按顺序组装列表项, 已注销的用户不出现在列表中
+func (r *RelationRepository) relationUsers(targetIds []int64, times []time.Time) ([]*model.RelationUserDTO, error) {
+	result := make([]*model.RelationUserDTO, 0, len(targetIds))
+	if len(targetIds) == 0 {
+		return result, nil
+	}
+	var users []model.UserDO
+	if err := r.DB.Where("id IN ?", targetIds).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	userMap := make(map[int64]*model.UserDTO, len(users))
+	for i := range users {
+		userMap[users[i].Id] = users[i].Transfer().Public()
+	}
+	for i, targetId := range targetIds {
+		if user, ok := userMap[targetId]; ok {
+			result = append(result, &model.RelationUserDTO{User: user, CreateTime: times[i]})
+		}
+	}
+	return result, nil
+}
+
+// removeUserRelations 删除与用户有关的全部拉黑和屏蔽记录, 用于注销账号
+func removeUserRelations(tx *gorm.DB, userId int64) error {
+	for _, value := range []interface{}{&model.BlockDO{}, &model.MuteDO{}} {
+		if err := tx.Where("user_id = ? OR target_id = ?", userId, userId).Delete(value).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"yujian-backend/pkg/model"
+)
+
+var reputationRepository ReputationRepository
+
+// ReputationRepository 声望流水和汇总
+type ReputationRepository struct {
+	DB *gorm.DB
+}
+
+func GetReputationRepository() *ReputationRepository {
+	return &reputationRepository
+}
+
+// recordVote 表态变化后记录作者的声望流水: 先冲销该用户之前的表态带来的声望, 再记入新的表态
+// 冲销的分值取流水中的实际合计, 配置的分值调整过也能正好抵消; 给自己的内容表态和匿名内容不计声望
+func recordVote(tx *gorm.DB, subject model.ReputationSubject, subjectId, authorId, actorId int64,
+	previous, reaction model.Reaction, points model.VotePoints) error {
+	if authorId == 0 || authorId == actorId || previous == reaction {
+		return nil
+	}
+
+	now := time.Now()
+	event := func(reason model.ReputationReason, points int64) *model.ReputationEventDO {
+		return &model.ReputationEventDO{
+			UserId:     authorId,
+			ActorId:    actorId,
+			Subject:    subject,
+			SubjectId:  subjectId,
+			Reason:     reason,
+			Points:     points,
+			CreateTime: now,
+		}
+	}
+
+	var events []*model.ReputationEventDO
+	if previous != model.ReactionNone {
+		var net int64
+		if err := tx.Model(&model.ReputationEventDO{}).
+			Where("subject = ? AND subject_id = ? AND actor_id = ? AND user_id = ? AND reason <> ?",
+				subject, subjectId, actorId, authorId, model.ReputationRemoved).
+			Select("COALESCE(SUM(points), 0)").Scan(&net).Error; err != nil {
+			return err
+		}
+		reason := model.ReputationLikeWithdrawn
+		if previous == model.ReactionDislike {
+			reason = model.ReputationDislikeWithdrawn
+		}
+		events = append(events, event(reason, -net))
+	}
+	switch reaction {
+	case model.ReactionLike:
+		events = append(events, event(model.ReputationLiked, points.Like))
+	case model.ReactionDislike:
+		events = append(events, event(model.ReputationDisliked, points.Dislike))
+	}
+	return addReputationEvents(tx, events...)
+}
+
+// addReputationEvents 写入声望流水并累加到用户的声望汇总, 分值为0的流水不记录
+func addReputationEvents(tx *gorm.DB, events ...*model.ReputationEventDO) error {
+	for _, event := range events {
+		if event.Points == 0 {
+			continue
+		}
+		if err := tx.Create(event).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "subject"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"points": gorm.Expr("points + ?", event.Points)}),
+		}).Create(&model.UserReputationDO{UserId: event.UserId, Subject: event.Subject, Points: event.Points}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordRemoval 记录内容被版主删除时作者损失的声望
+func (r *ReputationRepository) RecordRemoval(subject model.ReputationSubject, subjectId, authorId, moderatorId, points int64) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		return addReputationEvents(tx, &model.ReputationEventDO{
+			UserId:     authorId,
+			ActorId:    moderatorId,
+			Subject:    subject,
+			SubjectId:  subjectId,
+			Reason:     model.ReputationRemoved,
+			Points:     points,
+			CreateTime: time.Now(),
+		})
+	})
+}
+
+// GetReputation 获取用户在各类内容上获得的声望
+func (r *ReputationRepository) GetReputation(userId int64) (map[model.ReputationSubject]int64, error) {
+	var rows []model.UserReputationDO
+	if err := r.DB.Where("user_id = ?", userId).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return reputationBreakdown(rows), nil
+}
+
+// Recompute 丢弃用户的声望汇总, 按全部流水重新统计
+func (r *ReputationRepository) Recompute(userId int64) (map[model.ReputationSubject]int64, error) {
+	var rows []model.UserReputationDO
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userId).Delete(&model.UserReputationDO{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.ReputationEventDO{}).
+			Select("user_id, subject, SUM(points) AS points").
+			Where("user_id = ?", userId).Group("user_id, subject").
+			Scan(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reputationBreakdown(rows), nil
+}
+
+func reputationBreakdown(rows []model.UserReputationDO) map[model.ReputationSubject]int64 {
+	breakdown := make(map[model.ReputationSubject]int64, len(rows))
+	for _, row := range rows {
+		breakdown[row.Subject] = row.Points
+	}
+	return breakdown
+}
+
+// ListEvents 按ID倒序获取用户的声望流水, cursor 为上一页最后一条的ID, 为0时从最新开始; limit 为-1时不分页
+func (r *ReputationRepository) ListEvents(userId int64, cursor int64, limit int) ([]*model.ReputationEventDO, error) {
+	query := r.DB.Where("user_id = ?", userId)
+	if cursor > 0 {
+		query = query.Where("id < ?", cursor)
+	}
+	var events []*model.ReputationEventDO
+	if err := query.Order("id DESC").Limit(limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// removeUserReputation 注销账号时删除用户的声望流水和汇总, 并撤销用户的表态给别人带来的声望
+// 用户作为版主删除内容的扣分记录保留, 只是不再关联到该用户
+func removeUserReputation(tx *gorm.DB, userId int64) error {
+	var given []model.UserReputationDO
+	if err := tx.Model(&model.ReputationEventDO{}).
+		Select("user_id, subject, SUM(points) AS points").
+		Where("actor_id = ? AND user_id <> ? AND reason <> ?", userId, userId, model.ReputationRemoved).
+		Group("user_id, subject").Scan(&given).Error; err != nil {
+		return err
+	}
+	for _, row := range given {
+		if row.Points == 0 {
+			continue
+		}
+		if err := tx.Model(&model.UserReputationDO{}).
+			Where("user_id = ? AND subject = ?", row.UserId, row.Subject).
+			Update("points", gorm.Expr("points - ?", row.Points)).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Where("user_id = ? OR (actor_id = ? AND reason <> ?)", userId, userId, model.ReputationRemoved).
+		Delete(&model.ReputationEventDO{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&model.ReputationEventDO{}).Where("actor_id = ?", userId).
+		Update("actor_id", 0).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userId).Delete(&model.UserReputationDO{}).Error
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"yujian-backend/pkg/model"
+)
+
+// ErrSessionRevoked 会话已被吊销或已被轮换
+var ErrSessionRevoked = errors.New("session revoked")
+
+var sessionRepository SessionRepository
+
+type SessionRepository struct {
+	DB *gorm.DB
+}
+
+func GetSessionRepository() *SessionRepository {
+	return &sessionRepository
+}
+
+// CreateSession 创建会话
+func (r *SessionRepository) CreateSession(session *model.SessionDO) error {
+	return r.DB.Create(session).Error
+}
+
+// GetSessionByTokenHash 根据刷新令牌哈希获取会话
+func (r *SessionRepository) GetSessionByTokenHash(tokenHash string) (*model.SessionDO, error) {
+	var session model.SessionDO
+	if err := r.DB.Where("token_hash = ?", tokenHash).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RotateSession 在事务中创建新会话并把旧会话标记为已轮换
+// 旧会话已经被使用过时返回 ErrSessionRevoked, 新会话不会落库
+func (r *SessionRepository) RotateSession(oldId int64, newSession *model.SessionDO) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(newSession).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&model.SessionDO{}).
+			Where("id = ? AND revoked_at IS NULL", oldId).
+			Updates(map[string]interface{}{
+				"revoked_at":  time.Now(),
+				"replaced_by": newSession.Id,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSessionRevoked
+		}
+		return nil
+	})
+}
+
+// RevokeFamily 吊销整条会话链
+func (r *SessionRepository) RevokeFamily(familyId string) error {
+	return r.DB.Model(&model.SessionDO{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyId).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeUserSessions 吊销用户的全部会话
+func (r *SessionRepository) RevokeUserSessions(userId int64) error {
+	return r.DB.Model(&model.SessionDO{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Update("revoked_at", time.Now()).Error
+}
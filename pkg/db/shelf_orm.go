@@ -0,0 +1,266 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"yujian-backend/pkg/model"
+)
+
+var shelfRepository ShelfRepository
+
+// ShelfRepository 阅读状态书架和自建书架
+type ShelfRepository struct {
+	DB *gorm.DB
+}
+
+func GetShelfRepository() *ShelfRepository {
+	return &shelfRepository
+}
+
+// 阅读状态书架
+
+// GetShelfEntry 获取用户书架上的某本书
+func (r *ShelfRepository) GetShelfEntry(userId, bookId int64) (*model.ShelfEntryDO, error) {
+	var entry model.ShelfEntryDO
+	if err := r.DB.Where("user_id = ? AND book_id = ?", userId, bookId).First(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// SaveShelfEntry 保存书架条目, Id 为0时新建
+func (r *ShelfRepository) SaveShelfEntry(entry *model.ShelfEntryDO) error {
+	return r.DB.Save(entry).Error
+}
+
+// DeleteShelfEntry 把书从用户的书架上拿下来, 同时删除相关的阅读状态和评分动态; 书原本不在书架上时返回 false
+func (r *ShelfRepository) DeleteShelfEntry(userId, bookId int64) (bool, error) {
+	removed := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND book_id = ?", userId, bookId).Delete(&model.ShelfEntryDO{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		removed = true
+		return removeShelfEvents(tx, userId, bookId)
+	})
+	return removed, err
+}
+
+// ListShelfEntries 分页获取用户书架上的书, status 为空时返回全部状态, 按更新时间倒序; limit 为 -1 时不分页
+func (r *ShelfRepository) ListShelfEntries(userId int64, status model.ShelfStatus, offset, limit int) ([]*model.ShelfEntryDTO, int64, error) {
+	query := r.DB.Model(&model.ShelfEntryDO{}).Where("user_id = ?", userId)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var entries []model.ShelfEntryDO
+	if err := query.Order("update_time DESC, id DESC").Offset(offset).Limit(limit).Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+
+	bookIds := make([]int64, len(entries))
+	for i, entry := range entries {
+		bookIds[i] = entry.BookId
+	}
+	books, err := bookRepository.BatchGetBooks(bookIds)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]*model.ShelfEntryDTO, 0, len(entries))
+	for i := range entries {
+		if book, ok := books[entries[i].BookId]; ok {
+			result = append(result, entries[i].TransformToDTO(book))
+		}
+	}
+	return result, total, nil
+}
+
+// CountShelfEntries 统计用户各阅读状态的书的数量
+func (r *ShelfRepository) CountShelfEntries(userId int64) (*model.ShelfCountsDTO, error) {
+	var rows []struct {
+		Status model.ShelfStatus
+		Count  int64
+	}
+	if err := r.DB.Model(&model.ShelfEntryDO{}).Select("status, COUNT(*) AS count").
+		Where("user_id = ?", userId).Group("status").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := &model.ShelfCountsDTO{}
+	for _, row := range rows {
+		switch row.Status {
+		case model.ShelfWantToRead:
+			counts.WantToRead = row.Count
+		case model.ShelfReading:
+			counts.Reading = row.Count
+		case model.ShelfRead:
+			counts.Read = row.Count
+		}
+	}
+	return counts, nil
+}
+
+// 自建书架
+
+// CreateCustomShelf 创建自建书架
+func (r *ShelfRepository) CreateCustomShelf(shelf *model.CustomShelfDO) error {
+	return r.DB.Create(shelf).Error
+}
+
+// GetCustomShelf 根据ID获取自建书架
+func (r *ShelfRepository) GetCustomShelf(id int64) (*model.CustomShelfDO, error) {
+	var shelf model.CustomShelfDO
+	if err := r.DB.First(&shelf, id).Error; err != nil {
+		return nil, err
+	}
+	return &shelf, nil
+}
+
+// CustomShelfNameExists 用户是否已有同名书架, excludeId 为正在修改的书架
+func (r *ShelfRepository) CustomShelfNameExists(userId int64, name string, excludeId int64) (bool, error) {
+	var count int64
+	err := r.DB.Model(&model.CustomShelfDO{}).
+		Where("user_id = ? AND name = ? AND id <> ?", userId, name, excludeId).Count(&count).Error
+	return count > 0, err
+}
+
+// CountCustomShelves 统计用户的自建书架数量
+func (r *ShelfRepository) CountCustomShelves(userId int64) (int64, error) {
+	var count int64
+	err := r.DB.Model(&model.CustomShelfDO{}).Where("user_id = ?", userId).Count(&count).Error
+	return count, err
+}
+
+// UpdateCustomShelf 部分更新自建书架的名称和描述
+func (r *ShelfRepository) UpdateCustomShelf(id int64, updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	return r.DB.Model(&model.CustomShelfDO{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// DeleteCustomShelf 删除自建书架及上面的书
+func (r *ShelfRepository) DeleteCustomShelf(id int64) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("shelf_id = ?", id).Delete(&model.CustomShelfBookDO{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.CustomShelfDO{}, id).Error
+	})
+}
+
+// ListCustomShelves 获取用户的全部自建书架, 带每个书架上书的数量
+func (r *ShelfRepository) ListCustomShelves(userId int64) ([]*model.CustomShelfDTO, error) {
+	var shelves []model.CustomShelfDO
+	if err := r.DB.Where("user_id = ?", userId).Order("id").Find(&shelves).Error; err != nil {
+		return nil, err
+	}
+	result := make([]*model.CustomShelfDTO, len(shelves))
+	if len(shelves) == 0 {
+		return result, nil
+	}
+
+	shelfIds := make([]int64, len(shelves))
+	for i, shelf := range shelves {
+		shelfIds[i] = shelf.Id
+	}
+	var rows []struct {
+		ShelfId int64
+		Count   int64
+	}
+	if err := r.DB.Model(&model.CustomShelfBookDO{}).Select("shelf_id, COUNT(*) AS count").
+		Where("shelf_id IN ?", shelfIds).Group("shelf_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[int64]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ShelfId] = row.Count
+	}
+
+	for i := range shelves {
+		result[i] = shelves[i].TransformToDTO(counts[shelves[i].Id])
+	}
+	return result, nil
+}
+
+// CountShelfBooks 统计自建书架上书的数量
+func (r *ShelfRepository) CountShelfBooks(shelfId int64) (int64, error) {
+	var count int64
+	err := r.DB.Model(&model.CustomShelfBookDO{}).Where("shelf_id = ?", shelfId).Count(&count).Error
+	return count, err
+}
+
+// AddShelfBook 往自建书架上放书, 已经在书架上时不做任何事
+func (r *ShelfRepository) AddShelfBook(shelfId, bookId int64) error {
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.CustomShelfBookDO{ShelfId: shelfId, BookId: bookId}).Error
+}
+
+// RemoveShelfBook 把书从自建书架上拿下来, 书原本不在书架上时返回 false
+func (r *ShelfRepository) RemoveShelfBook(shelfId, bookId int64) (bool, error) {
+	result := r.DB.Where("shelf_id = ? AND book_id = ?", shelfId, bookId).Delete(&model.CustomShelfBookDO{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListShelfBooks 分页获取自建书架上的书, 按放入时间倒序; limit 为 -1 时不分页
+func (r *ShelfRepository) ListShelfBooks(shelfId int64, offset, limit int) ([]*model.CustomShelfBookDTO, int64, error) {
+	total, err := r.CountShelfBooks(shelfId)
+	if err != nil {
+		return nil, 0, err
+	}
+	var shelfBooks []model.CustomShelfBookDO
+	if err = r.DB.Where("shelf_id = ?", shelfId).Order("add_time DESC, id DESC").
+		Offset(offset).Limit(limit).Find(&shelfBooks).Error; err != nil {
+		return nil, 0, err
+	}
+
+	bookIds := make([]int64, len(shelfBooks))
+	for i, shelfBook := range shelfBooks {
+		bookIds[i] = shelfBook.BookId
+	}
+	books, err := bookRepository.BatchGetBooks(bookIds)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]*model.CustomShelfBookDTO, 0, len(shelfBooks))
+	for _, shelfBook := range shelfBooks {
+		if book, ok := books[shelfBook.BookId]; ok {
+			result = append(result, &model.CustomShelfBookDTO{Book: book, AddTime: shelfBook.AddTime})
+		}
+	}
+	return result, total, nil
+}
+
+// removeUserShelves 删除用户的全部书架数据和阅读目标, 用于注销账号
+func removeUserShelves(tx *gorm.DB, userId int64) error {
+	if err := tx.Where("user_id = ?", userId).Delete(&model.ReadingGoalDO{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("shelf_id IN (?)",
+		tx.Model(&model.CustomShelfDO{}).Select("id").Where("user_id = ?", userId)).
+		Delete(&model.CustomShelfBookDO{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userId).Delete(&model.CustomShelfDO{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userId).Delete(&model.ShelfEntryDO{}).Error
+}
+
+// removeBookFromShelves 把书从所有人的书架上拿下来并删除与书有关的动态, 用于删除书
+func removeBookFromShelves(tx *gorm.DB, bookId int64) error {
+	if err := tx.Where("book_id = ?", bookId).Delete(&model.ShelfEntryDO{}).Error; err != nil {
+		return err
+	}
+	if err := removeBookEvents(tx, bookId); err != nil {
+		return err
+	}
+	return tx.Where("book_id = ?", bookId).Delete(&model.CustomShelfBookDO{}).Error
+}
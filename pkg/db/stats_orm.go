@@ -0,0 +1,175 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"yujian-backend/pkg/model"
+)
+
+var statsRepository StatsRepository
+
+// StatsRepository 阅读统计和年度阅读目标, 统计全部在数据库中聚合
+type StatsRepository struct {
+	DB *gorm.DB
+}
+
+func GetStatsRepository() *StatsRepository {
+	return &statsRepository
+}
+
+// readBooks 用户读过的书连接书信息的查询, start 非零时只包含读完日期在 [start, end) 内的书
+func (r *StatsRepository) readBooks(userId int64, start, end time.Time) *gorm.DB {
+	query := r.DB.Table(model.ShelfEntryDO{}.TableName()+" AS e").
+		Joins("JOIN "+tableName(r.DB, &model.BookInfoDO{})+" AS b ON b.id = e.book_id").
+		Where("e.user_id = ? AND e.status = ?", userId, model.ShelfRead)
+	if !start.IsZero() {
+		query = query.Where("e.finish_date >= ? AND e.finish_date < ?", start, end)
+	}
+	return query
+}
+
+// GetReadingStats 统计用户读过的书, year 为0时统计全部年份
+func (r *StatsRepository) GetReadingStats(userId int64, year int, topN int) (*model.ReadingStatsDTO, error) {
+	var start, end time.Time
+	if year != 0 {
+		start = time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local)
+		end = start.AddDate(1, 0, 0)
+	}
+
+	var totals struct {
+		Books     int64
+		Pages     int64
+		Rated     int64
+		AvgRating float64
+	}
+	if err := r.readBooks(userId, start, end).
+		Select("COUNT(*) AS books, COALESCE(SUM(b.pages), 0) AS pages, " +
+			"COUNT(NULLIF(e.rating, 0)) AS rated, COALESCE(AVG(NULLIF(e.rating, 0)), 0) AS avg_rating").
+		Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	stats := &model.ReadingStatsDTO{
+		Year:          year,
+		BooksRead:     totals.Books,
+		PagesRead:     totals.Pages,
+		RatedBooks:    totals.Rated,
+		AverageRating: totals.AvgRating,
+	}
+
+	var err error
+	if year != 0 {
+		stats.Monthly, err = r.monthlyStats(userId, year, start, end)
+	} else {
+		stats.Yearly, err = r.yearlyStats(userId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.TopAuthors, err = r.topItems(userId, start, end, "b.author", topN); err != nil {
+		return nil, err
+	}
+	if stats.TopGenres, err = r.topItems(userId, start, end, "b.genre", topN); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// monthlyStats 按月统计一年内读完的书, 没有读完书的月份也会返回
+func (r *StatsRepository) monthlyStats(userId int64, year int, start, end time.Time) ([]*model.PeriodStatDTO, error) {
+	var rows []struct {
+		Month int
+		Books int64
+		Pages int64
+	}
+	if err := r.readBooks(userId, start, end).
+		Select("MONTH(e.finish_date) AS month, COUNT(*) AS books, COALESCE(SUM(b.pages), 0) AS pages").
+		Group("MONTH(e.finish_date)").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	monthly := make([]*model.PeriodStatDTO, 12)
+	for i := range monthly {
+		monthly[i] = &model.PeriodStatDTO{Year: year, Month: i + 1}
+	}
+	for _, row := range rows {
+		if row.Month >= 1 && row.Month <= 12 {
+			monthly[row.Month-1].Books = row.Books
+			monthly[row.Month-1].Pages = row.Pages
+		}
+	}
+	return monthly, nil
+}
+
+// yearlyStats 按年统计读完的书, 没有读完日期的书不计入
+func (r *StatsRepository) yearlyStats(userId int64) ([]*model.PeriodStatDTO, error) {
+	var yearly []*model.PeriodStatDTO
+	err := r.readBooks(userId, time.Time{}, time.Time{}).
+		Where("e.finish_date IS NOT NULL").
+		Select("YEAR(e.finish_date) AS year, COUNT(*) AS books, COALESCE(SUM(b.pages), 0) AS pages").
+		Group("YEAR(e.finish_date)").Order("year").Scan(&yearly).Error
+	return yearly, err
+}
+
+// topItems 按读过的书的数量取 column 的前 n 名, 空值不参与排名
+func (r *StatsRepository) topItems(userId int64, start, end time.Time, column string, n int) ([]*model.RankedItemDTO, error) {
+	items := []*model.RankedItemDTO{}
+	err := r.readBooks(userId, start, end).
+		Where(column + " <> ''").
+		Select(column + " AS name, COUNT(*) AS books").
+		Group(column).Order("books DESC, name").Limit(n).
+		Scan(&items).Error
+	return items, err
+}
+
+// CountBooksRead 统计用户在 [start, end) 内读完的书的数量
+func (r *StatsRepository) CountBooksRead(userId int64, start, end time.Time) (int64, error) {
+	var count int64
+	err := r.DB.Model(&model.ShelfEntryDO{}).
+		Where("user_id = ? AND status = ? AND finish_date >= ? AND finish_date < ?", userId, model.ShelfRead, start, end).
+		Count(&count).Error
+	return count, err
+}
+
+// GetReadingGoal 获取用户某一年的阅读目标
+func (r *StatsRepository) GetReadingGoal(userId int64, year int) (*model.ReadingGoalDO, error) {
+	var goal model.ReadingGoalDO
+	if err := r.DB.Where("user_id = ? AND year = ?", userId, year).First(&goal).Error; err != nil {
+		return nil, err
+	}
+	return &goal, nil
+}
+
+// ListReadingGoals 获取用户的全部阅读目标, 按年份倒序
+func (r *StatsRepository) ListReadingGoals(userId int64) ([]*model.ReadingGoalDO, error) {
+	var goals []*model.ReadingGoalDO
+	if err := r.DB.Where("user_id = ?", userId).Order("year DESC").Find(&goals).Error; err != nil {
+		return nil, err
+	}
+	return goals, nil
+}
+
+// SaveReadingGoal 设置阅读目标, 该年已有目标时覆盖
+func (r *StatsRepository) SaveReadingGoal(goal *model.ReadingGoalDO) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "year"}},
+		DoUpdates: clause.AssignmentColumns([]string{"target_books", "update_time"}),
+	}).Create(goal).Error
+}
+
+// DeleteReadingGoal 删除阅读目标, 原本没有设置时返回 false
+func (r *StatsRepository) DeleteReadingGoal(userId int64, year int) (bool, error) {
+	result := r.DB.Where("user_id = ? AND year = ?", userId, year).Delete(&model.ReadingGoalDO{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// tableName 取 value 对应的表名, 用于拼接 JOIN 子句
+func tableName(db *gorm.DB, value interface{}) string {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(value); err != nil {
+		return ""
+	}
+	return stmt.Schema.Table
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"strings"
+
 	"gorm.io/gorm"
 	"yujian-backend/pkg/model"
 )
@@ -35,6 +37,22 @@ func (r *UserRepository) GetUserById(id int64) (*model.UserDTO, error) {
 	}
 }
 
+// BatchGetUsers 批量获取用户的公开信息, 返回以用户ID为键的映射, 不存在的用户不在结果中
+func (r *UserRepository) BatchGetUsers(ids []int64) (map[int64]*model.UserDTO, error) {
+	result := make(map[int64]*model.UserDTO, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+	var users []model.UserDO
+	if err := r.DB.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for i := range users {
+		result[users[i].Id] = users[i].Transfer().Public()
+	}
+	return result, nil
+}
+
 func (r *UserRepository) GetUserByName(name string) (*model.UserDTO, error) {
 	var userDO model.UserDO
 	if err := r.DB.Where("name IS NOT NULL").
@@ -45,12 +63,111 @@ func (r *UserRepository) GetUserByName(name string) (*model.UserDTO, error) {
 	}
 }
 
-// UpdateUser 更新用户信息
+// BatchGetUsersByNames 按用户名批量获取用户的公开信息, 返回以用户名为键的映射, 不存在的用户名不在结果中
+func (r *UserRepository) BatchGetUsersByNames(names []string) (map[string]*model.UserDTO, error) {
+	result := make(map[string]*model.UserDTO, len(names))
+	if len(names) == 0 {
+		return result, nil
+	}
+	var users []model.UserDO
+	if err := r.DB.Where("name IN ?", names).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for i := range users {
+		result[users[i].Name] = users[i].Transfer().Public()
+	}
+	return result, nil
+}
+
+// SearchUsersByNamePrefix 按用户名前缀查找用户, 用于 @提及 时的补全, 按用户名排序, 返回公开信息
+// 拉黑了 viewerId 的用户不出现在结果中
+func (r *UserRepository) SearchUsersByNamePrefix(prefix string, viewerId int64, limit int) ([]*model.UserDTO, error) {
+	var users []model.UserDO
+	if err := r.DB.Where("name LIKE ?", escapeLike(prefix)+"%").
+		Where("id NOT IN (?)", relationRepository.BlockerIdsQuery(viewerId)).
+		Order("name").Limit(limit).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	result := make([]*model.UserDTO, len(users))
+	for i := range users {
+		result[i] = users[i].Transfer().Public()
+	}
+	return result, nil
+}
+
+// GetUserCredentialByName 根据用户名获取带密码哈希的用户, 仅用于登录校验
+func (r *UserRepository) GetUserCredentialByName(name string) (*model.UserDO, error) {
+	var userDO model.UserDO
+	if err := r.DB.Where("name = ?", name).First(&userDO).Error; err != nil {
+		return nil, err
+	}
+	return &userDO, nil
+}
+
+// GetUserCredentialById 根据ID获取带密码哈希的用户
+func (r *UserRepository) GetUserCredentialById(id int64) (*model.UserDO, error) {
+	var userDO model.UserDO
+	if err := r.DB.First(&userDO, id).Error; err != nil {
+		return nil, err
+	}
+	return &userDO, nil
+}
+
+// GetUserByEmail 根据邮箱获取用户
+func (r *UserRepository) GetUserByEmail(email string) (*model.UserDTO, error) {
+	var userDO model.UserDO
+	if err := r.DB.Where("email = ?", email).First(&userDO).Error; err != nil {
+		return nil, err
+	}
+	return userDO.Transfer(), nil
+}
+
+// UpdateEmail 更新用户邮箱, 新邮箱需要重新验证
+func (r *UserRepository) UpdateEmail(id int64, email string) error {
+	return r.DB.Model(&model.UserDO{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"email": email, "email_verified": false}).Error
+}
+
+// MarkEmailVerified 将邮箱标记为已验证, 邮箱已被修改时不生效
+func (r *UserRepository) MarkEmailVerified(id int64, email string) (bool, error) {
+	result := r.DB.Model(&model.UserDO{}).Where("id = ? AND email = ?", id, email).Update("email_verified", true)
+	return result.RowsAffected > 0, result.Error
+}
+
+// UpdatePassword 更新用户密码哈希
+func (r *UserRepository) UpdatePassword(id int64, passwordHash string) error {
+	return r.DB.Model(&model.UserDO{}).Where("id = ?", id).Update("password", passwordHash).Error
+}
+
+// UpdateUserRole 更新用户角色
+func (r *UserRepository) UpdateUserRole(id int64, role model.Role) error {
+	return r.DB.Model(&model.UserDO{}).Where("id = ?", id).Update("role", role).Error
+}
+
+// UpdateUser 更新用户名和密码, 密码为空时保持不变
+// 角色、邮箱和资料各有单独的更新方法, 这里不会覆盖
 func (r *UserRepository) UpdateUser(user *model.UserDO) error {
-	return r.DB.Save(user).Error
+	columns := []string{"name"}
+	if user.Password != "" {
+		columns = append(columns, "password")
+	}
+	return r.DB.Model(user).Select(columns).Updates(user).Error
+}
+
+// UpdateProfile 部分更新用户资料, 只更新 profile 中给出的列
+func (r *UserRepository) UpdateProfile(id int64, profile map[string]interface{}) error {
+	if len(profile) == 0 {
+		return nil
+	}
+	return r.DB.Model(&model.UserDO{}).Where("id = ?", id).Updates(profile).Error
 }
 
 // DeleteUser 删除用户
 func (r *UserRepository) DeleteUser(id int64) error {
 	return r.DB.Delete(&model.UserDO{}, id).Error
 }
+
+// escapeLike 转义 LIKE 模式中的通配符, 使其按字面匹配
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
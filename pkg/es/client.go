@@ -15,6 +15,12 @@ import (
 
 var es *elasticsearch.Client
 
+// ErrClientNotReady ES客户端尚未初始化
+var ErrClientNotReady = errors.New("elasticsearch client not initialized")
+
+// ErrDocumentNotFound 文档不存在
+var ErrDocumentNotFound = errors.New("document not found")
+
 // ensureIndex 确保索引存在
 func ensureIndex(ctx context.Context, indexName string) error {
 	// 检查索引是否存在
@@ -42,6 +48,10 @@ func ensureIndex(ctx context.Context, indexName string) error {
 
 // Create 创建内容
 func Create(ctx context.Context, item model.EsModel) error {
+	if es == nil {
+		return ErrClientNotReady
+	}
+
 	// 先确保索引存在且打开
 	index := item.GetIndexName()
 	if err := ensureIndex(ctx, index); err != nil {
@@ -411,3 +421,58 @@ func SearchArticlesWithScores[T model.EsModel](ctx context.Context, indexName st
 
 	return items, nil
 }
+
+// DeleteDocuments 批量删除索引中的文档, 文档不存在时视为已删除
+func DeleteDocuments(ctx context.Context, indexName string, ids []string) error {
+	if es == nil {
+		return ErrClientNotReady
+	}
+
+	var failed []string
+	for _, id := range ids {
+		res, err := es.Delete(indexName, id, es.Delete.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		if res.IsError() && res.StatusCode != 404 {
+			failed = append(failed, id)
+		}
+		res.Body.Close()
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to delete documents %v from %s", failed, indexName)
+	}
+	return nil
+}
+
+// GetDocument 根据ID获取文档, 文档不存在时返回 ErrDocumentNotFound
+func GetDocument[T model.EsModel](ctx context.Context, indexName string, id string) (T, error) {
+	var item T
+	if es == nil {
+		return item, ErrClientNotReady
+	}
+
+	res, err := es.Get(indexName, id, es.Get.WithContext(ctx))
+	if err != nil {
+		return item, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return item, ErrDocumentNotFound
+	}
+	if res.IsError() {
+		return item, fmt.Errorf("elasticsearch error: %v", res.String())
+	}
+
+	var result struct {
+		Source json.RawMessage `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return item, err
+	}
+	if err := json.Unmarshal(result.Source, &item); err != nil {
+		return item, err
+	}
+	return item, nil
+}
@@ -7,8 +7,6 @@ import (
 	"github.com/elastic/go-elasticsearch/v8"
 )
 
-var esClient *elasticsearch.Client
-
 func InitESClient() {
 	esConfig := config.Config.ES
 	cfg := elasticsearch.Config{
@@ -23,5 +21,5 @@ func InitESClient() {
 		return
 	}
 
-	esClient = client
+	es = client
 }
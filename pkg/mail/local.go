@@ -0,0 +1,35 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/utils"
+)
+
+// FileMailer 把邮件写成目录下的 .eml 文件, 用于本地开发和测试
+type FileMailer struct {
+	Dir string
+}
+
+func (m *FileMailer) Send(msg *Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405"), utils.GenerateUUID())
+	return os.WriteFile(filepath.Join(m.Dir, name), buildMessage("noreply@localhost", msg), 0o644)
+}
+
+// LogMailer 只把邮件内容打到日志里, 用于本地开发
+// 正文中带有验证和重置密码的令牌, 只在 debug 级别输出
+type LogMailer struct{}
+
+func (m *LogMailer) Send(msg *Message) error {
+	log.GetLogger().Infof("send mail to %s, subject: %s", strings.Join(msg.To, ", "), msg.Subject)
+	log.GetLogger().Debugf("mail body:\n%s", msg.Body)
+	return nil
+}
@@ -0,0 +1,60 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+
+	"yujian-backend/pkg/log"
+	"yujian-backend/pkg/model"
+)
+
+// Message 一封纯文本邮件
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Mailer 邮件发送器
+type Mailer interface {
+	// Send 发送邮件
+	Send(msg *Message) error
+}
+
+var mailer Mailer = &LogMailer{}
+
+// InitMailer 根据配置初始化邮件发送器
+func InitMailer(config *model.MailConfig) error {
+	switch config.Driver {
+	case "log":
+		log.GetLogger().Warnf("邮件驱动为 log, 只用于本地开发: 邮件中的验证和重置链接会写进日志")
+		mailer = &LogMailer{}
+	case "file":
+		log.GetLogger().Warnf("邮件驱动为 file, 只用于本地开发: 邮件中的验证和重置链接会写进 %s", config.Dir)
+		mailer = &FileMailer{Dir: config.Dir}
+	case "smtp":
+		if config.Host == "" {
+			return errors.New("mail.host is required for the smtp driver")
+		}
+		mailer = &SMTPMailer{
+			Host:     config.Host,
+			Port:     config.Port,
+			Username: config.Username,
+			Password: config.Password,
+			From:     config.From,
+		}
+	default:
+		return fmt.Errorf("unsupported mail driver: %s", config.Driver)
+	}
+	return nil
+}
+
+// GetMailer 获取全局邮件发送器
+func GetMailer() Mailer {
+	return mailer
+}
+
+// SetMailer 替换全局邮件发送器
+func SetMailer(m Mailer) {
+	mailer = m
+}
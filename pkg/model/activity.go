@@ -0,0 +1,85 @@
+package model
+
+import (
+	"time"
+)
+
+// ActivityType 动态类型
+type ActivityType string
+
+const (
+	ActivityPost        ActivityType = "post"         // 发布帖子
+	ActivityBookReview  ActivityType = "book_review"  // 发表书评
+	ActivityShelfUpdate ActivityType = "shelf_update" // 修改书的阅读状态
+	ActivityRating      ActivityType = "rating"       // 给书评分
+)
+
+// ActivityEventDO 用户动态, 创建时把展示需要的字段一并存下, 读取时不必再查帖子和书评
+// FannedOut 表示动态已写入粉丝的收件箱; 为 false 的动态来自粉丝很多的用户, 由粉丝读取时拉取
+type ActivityEventDO struct {
+	Id         int64        `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	ActorId    int64        `gorm:"column:actor_id;index:idx_activity_actor,priority:1;index:idx_activity_pull,priority:2" json:"actor_id"`
+	Type       ActivityType `gorm:"column:type;size:16;index:idx_activity_subject,priority:1" json:"type"`
+	SubjectId  int64        `gorm:"column:subject_id;index:idx_activity_subject,priority:2" json:"subject_id"` // 帖子、书评或书架条目的ID
+	BookId     int64        `gorm:"column:book_id" json:"book_id"`
+	Title      string       `gorm:"column:title;size:255" json:"title"`
+	Summary    string       `gorm:"column:summary;size:512" json:"summary"`
+	Status     ShelfStatus  `gorm:"column:status;size:16" json:"status"`
+	Rating     int          `gorm:"column:rating" json:"rating"`
+	FannedOut  bool         `gorm:"column:fanned_out;index:idx_activity_pull,priority:1" json:"fanned_out"`
+	CreateTime time.Time    `gorm:"column:create_time" json:"create_time"`
+}
+
+func (a ActivityEventDO) TableName() string {
+	return "activity_event"
+}
+
+// TimelineEntryDO 首页动态收件箱, 粉丝不多的用户发布动态时为每个粉丝写入一行
+type TimelineEntryDO struct {
+	Id         int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId     int64     `gorm:"column:user_id;uniqueIndex:idx_timeline_user_event,priority:1;index:idx_timeline_user_actor,priority:1" json:"user_id"`
+	EventId    int64     `gorm:"column:event_id;uniqueIndex:idx_timeline_user_event,priority:2;index" json:"event_id"`
+	ActorId    int64     `gorm:"column:actor_id;index:idx_timeline_user_actor,priority:2" json:"actor_id"`
+	CreateTime time.Time `gorm:"column:create_time" json:"create_time"`
+}
+
+func (t TimelineEntryDO) TableName() string {
+	return "timeline_entry"
+}
+
+// ActivityEventDTO 动态
+type ActivityEventDTO struct {
+	Id         int64        `json:"id"`
+	Type       ActivityType `json:"type"`
+	Actor      *UserDTO     `json:"actor"`
+	SubjectId  int64        `json:"subject_id"`
+	Book       *BookInfoDTO `json:"book,omitempty"`
+	Title      string       `json:"title,omitempty"`
+	Summary    string       `json:"summary,omitempty"`
+	Status     ShelfStatus  `json:"status,omitempty"`
+	Rating     int          `json:"rating,omitempty"`
+	CreateTime time.Time    `json:"create_time"`
+}
+
+// TransformToDTO 将ActivityEventDO转换为ActivityEventDTO, 作者和书由调用方加载
+func (a *ActivityEventDO) TransformToDTO(actor *UserDTO, book *BookInfoDTO) *ActivityEventDTO {
+	return &ActivityEventDTO{
+		Id:         a.Id,
+		Type:       a.Type,
+		Actor:      actor,
+		SubjectId:  a.SubjectId,
+		Book:       book,
+		Title:      a.Title,
+		Summary:    a.Summary,
+		Status:     a.Status,
+		Rating:     a.Rating,
+		CreateTime: a.CreateTime,
+	}
+}
+
+// ActivityFeedResponseDTO 动态列表响应, NextCursor 为空表示没有更多
+type ActivityFeedResponseDTO struct {
+	BaseResp
+	Events     []*ActivityEventDTO `json:"events"`
+	NextCursor string              `json:"next_cursor"`
+}
@@ -0,0 +1,125 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Scope API Key 的授权范围
+type Scope string
+
+const (
+	ScopeBooksRead  Scope = "books:read"  // 读取书籍和书评
+	ScopeBooksWrite Scope = "books:write" // 维护书籍信息
+	ScopePostsRead  Scope = "posts:read"  // 读取帖子
+	ScopePostsWrite Scope = "posts:write" // 发布和管理帖子
+)
+
+// validScopes 已定义的授权范围
+var validScopes = map[Scope]struct{}{
+	ScopeBooksRead:  {},
+	ScopeBooksWrite: {},
+	ScopePostsRead:  {},
+	ScopePostsWrite: {},
+}
+
+// Valid 是否为已定义的授权范围
+func (s Scope) Valid() bool {
+	_, ok := validScopes[s]
+	return ok
+}
+
+// APIKeyDTO API Key DTO, 不包含密钥本身
+type APIKeyDTO struct {
+	Id         int64      `json:"id"`
+	UserId     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // 密钥前几位, 方便用户辨认
+	Scopes     []Scope    `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreateTime time.Time  `json:"create_time"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// APIKeyDO API Key DO, 只保存密钥哈希
+type APIKeyDO struct {
+	Id         int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId     int64      `gorm:"column:user_id;index" json:"user_id"` // 密钥代表的用户, 服务账号也是一个用户
+	Name       string     `gorm:"column:name;size:64" json:"name"`
+	Prefix     string     `gorm:"column:prefix;size:16" json:"prefix"`
+	KeyHash    string     `gorm:"column:key_hash;size:64;uniqueIndex" json:"-"`
+	Scopes     string     `gorm:"column:scopes;size:255" json:"scopes"` // 空格分隔
+	ExpiresAt  time.Time  `gorm:"column:expires_at" json:"expires_at"`
+	CreateTime time.Time  `gorm:"column:create_time" json:"create_time"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at" json:"last_used_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at" json:"revoked_at"`
+}
+
+func (k APIKeyDO) TableName() string {
+	return "api_key"
+}
+
+// ScopeList 解析授权范围列表
+func (k *APIKeyDO) ScopeList() []Scope {
+	fields := strings.Fields(k.Scopes)
+	scopes := make([]Scope, len(fields))
+	for i, field := range fields {
+		scopes[i] = Scope(field)
+	}
+	return scopes
+}
+
+// HasScope 是否拥有指定的授权范围
+func (k *APIKeyDO) HasScope(scope Scope) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *APIKeyDO) Transfer() *APIKeyDTO {
+	return &APIKeyDTO{
+		Id:         k.Id,
+		UserId:     k.UserId,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.ScopeList(),
+		ExpiresAt:  k.ExpiresAt,
+		CreateTime: k.CreateTime,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+}
+
+// JoinScopes 把授权范围拼接为存储格式
+func JoinScopes(scopes []Scope) string {
+	fields := make([]string, len(scopes))
+	for i, scope := range scopes {
+		fields[i] = string(scope)
+	}
+	return strings.Join(fields, " ")
+}
+
+// CreateAPIKeyRequestDTO 创建 API Key 的请求
+type CreateAPIKeyRequestDTO struct {
+	Name      string     `json:"name"`
+	Scopes    []Scope    `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"` // 不填时使用默认有效期
+	UserId    int64      `json:"user_id"`    // 为其他用户(服务账号)创建, 需要用户管理权限
+}
+
+// CreateAPIKeyResponseDTO 创建 API Key 的响应, 密钥明文只在这里返回一次
+type CreateAPIKeyResponseDTO struct {
+	BaseResp
+	Key    string     `json:"key"`
+	APIKey *APIKeyDTO `json:"api_key"`
+}
+
+// APIKeyListResponseDTO API Key 列表响应
+type APIKeyListResponseDTO struct {
+	BaseResp
+	Keys []*APIKeyDTO `json:"keys"`
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"time"
+)
+
+// AuditEventType 审计事件类型
+type AuditEventType string
+
+const (
+	AuditLoginSuccess   AuditEventType = "login_success"
+	AuditLoginFailure   AuditEventType = "login_failure"
+	AuditRegister       AuditEventType = "register"
+	AuditPasswordChange AuditEventType = "password_change"
+	AuditRoleChange     AuditEventType = "role_change"
+	AuditUserDelete     AuditEventType = "user_delete"
+	AuditAPIKeyCreate   AuditEventType = "apikey_create"
+	AuditAPIKeyRevoke   AuditEventType = "apikey_revoke"
+)
+
+// AuditEventDTO 审计事件DTO
+type AuditEventDTO struct {
+	Id         int64          `json:"id"`
+	EventType  AuditEventType `json:"event_type"`
+	UserId     int64          `json:"user_id"`  // 事件涉及的用户, 登录失败且用户不存在时为0
+	ActorId    int64          `json:"actor_id"` // 执行操作的用户, 未登录时为0
+	UserName   string         `json:"user_name"`
+	IP         string         `json:"ip"`
+	UserAgent  string         `json:"user_agent"`
+	Detail     string         `json:"detail"`
+	CreateTime time.Time      `json:"create_time"`
+}
+
+// AuditEventDO 审计事件DO, 只追加不修改
+type AuditEventDO struct {
+	Id         int64          `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	EventType  AuditEventType `gorm:"column:event_type;size:32;index:idx_audit_type_time" json:"event_type"`
+	UserId     int64          `gorm:"column:user_id;index:idx_audit_user_time" json:"user_id"`
+	ActorId    int64          `gorm:"column:actor_id" json:"actor_id"`
+	UserName   string         `gorm:"column:user_name;size:64" json:"user_name"`
+	IP         string         `gorm:"column:ip;size:64" json:"ip"`
+	UserAgent  string         `gorm:"column:user_agent;size:255" json:"user_agent"`
+	Detail     string         `gorm:"column:detail;size:255" json:"detail"`
+	CreateTime time.Time      `gorm:"column:create_time;index:idx_audit_type_time;index:idx_audit_user_time;index" json:"create_time"`
+}
+
+func (a AuditEventDO) TableName() string {
+	return "audit_event"
+}
+
+func (a *AuditEventDO) Transfer() *AuditEventDTO {
+	return &AuditEventDTO{
+		Id:         a.Id,
+		EventType:  a.EventType,
+		UserId:     a.UserId,
+		ActorId:    a.ActorId,
+		UserName:   a.UserName,
+		IP:         a.IP,
+		UserAgent:  a.UserAgent,
+		Detail:     a.Detail,
+		CreateTime: a.CreateTime,
+	}
+}
+
+// AuditQuery 审计事件查询条件, 零值表示不限制
+type AuditQuery struct {
+	UserId    int64
+	EventType AuditEventType
+	Start     time.Time
+	End       time.Time
+	Offset    int
+	Limit     int
+}
+
+// AuditEventListResponseDTO 审计事件查询响应
+type AuditEventListResponseDTO struct {
+	BaseResp
+	Total  int64            `json:"total"`
+	Events []*AuditEventDTO `json:"events"`
+}
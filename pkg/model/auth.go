@@ -7,17 +7,67 @@ type LoginRequestDTO struct {
 
 type LoginResponseDTO struct {
 	BaseResp
-	Token string  `json:"token"`
-	User  UserDTO `json:"user"`
+	Token        string  `json:"token"`
+	RefreshToken string  `json:"refresh_token"`
+	User         UserDTO `json:"user"`
+	MFARequired  bool    `json:"mfa_required"`        // 为 true 时需要带 MFAToken 调用 /login/mfa
+	MFAToken     string  `json:"mfa_token,omitempty"` // 两步登录的中间令牌
 }
 
 type RegisterRequestDTO struct {
 	UserName string `json:"user_name"`
 	Password string `json:"password"`
+	Email    string `json:"email"` // 可选, 填写后会发送验证邮件
 }
 
 type RegisterResponseDTO struct {
 	BaseResp
-	Token string  `json:"token"`
-	User  UserDTO `json:"user"`
+	Token        string  `json:"token"`
+	RefreshToken string  `json:"refresh_token"`
+	User         UserDTO `json:"user"`
+}
+
+// RefreshTokenRequestDTO 刷新令牌请求
+type RefreshTokenRequestDTO struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshTokenResponseDTO 刷新令牌响应
+type RefreshTokenResponseDTO struct {
+	BaseResp
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// LogoutRequestDTO 登出请求
+type LogoutRequestDTO struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// EmailVerificationRequestDTO 申请邮箱验证请求, Email 为空时向当前邮箱重发
+type EmailVerificationRequestDTO struct {
+	Email string `json:"email"`
+}
+
+// ConfirmTokenRequestDTO 提交邮件中令牌的请求
+type ConfirmTokenRequestDTO struct {
+	Token string `json:"token"`
+}
+
+// PasswordResetRequestDTO 申请重置密码请求
+type PasswordResetRequestDTO struct {
+	Email string `json:"email"`
+}
+
+// PasswordResetConfirmDTO 确认重置密码请求
+type PasswordResetConfirmDTO struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// StreamTicketResponseDTO 实时推送连接票据响应, 浏览器的 EventSource 不能设置请求头, 用票据代替令牌放在链接里
+type StreamTicketResponseDTO struct {
+	BaseResp
+	Ticket    string `json:"ticket"`
+	ExpiresIn int64  `json:"expires_in"` // 有效期, 单位秒
 }
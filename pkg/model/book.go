@@ -12,6 +12,8 @@ type BookInfoDTO struct {
 	ISBN   string  `json:"ISBN"`
 	Score  float64 `json:"score"`
 	Intro  string  `json:"intro"`
+	Pages  int     `json:"pages"` // 页数, 用于阅读统计
+	Genre  string  `json:"genre"` // 类型, 用于阅读统计
 }
 
 // BookInfoDO 书信息数据库对象
@@ -22,6 +24,8 @@ type BookInfoDO struct {
 	ISBN   string  `gorm:"column:isbn" json:"ISBN"`
 	Score  float64 `gorm:"column:score" json:"score"`
 	Intro  string  `gorm:"column:intro" json:"intro"`
+	Pages  int     `gorm:"column:pages" json:"pages"`
+	Genre  string  `gorm:"column:genre;size:32" json:"genre"`
 }
 
 // TransformToDTO 将BookInfoDO转换为BookInfoDTO
@@ -33,6 +37,8 @@ func (bookInfoDO *BookInfoDO) Transfer() *BookInfoDTO {
 		ISBN:   bookInfoDO.ISBN,
 		Score:  bookInfoDO.Score,
 		Intro:  bookInfoDO.Intro,
+		Pages:  bookInfoDO.Pages,
+		Genre:  bookInfoDO.Genre,
 	}
 }
 
@@ -45,6 +51,8 @@ func (bookInfoDTO *BookInfoDTO) TransformToDO() *BookInfoDO {
 		ISBN:   bookInfoDTO.ISBN,
 		Score:  bookInfoDTO.Score,
 		Intro:  bookInfoDTO.Intro,
+		Pages:  bookInfoDTO.Pages,
+		Genre:  bookInfoDTO.Genre,
 	}
 }
 
@@ -52,6 +60,8 @@ func (bookInfoDTO *BookInfoDTO) TransformToDO() *BookInfoDO {
 type BookCommentDTO struct {
 	Id             int64   `json:"id"`
 	BookId         int64   `json:"book_id"`
+	AuthorId       int64   `json:"author_id"`
+	AuthorName     string  `json:"author_name"`
 	Content        string  `json:"content"`
 	Like           int64   `json:"like"`
 	Dislike        int64   `json:"dislike"`
@@ -63,6 +73,8 @@ type BookCommentDTO struct {
 type BookCommentDO struct {
 	Id             int64  `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
 	BookId         int64  `gorm:"column:book_id" json:"book_id"`
+	AuthorId       int64  `gorm:"column:author_id;index" json:"author_id"`
+	AuthorName     string `gorm:"column:author_name" json:"author_name"`
 	Content        string `gorm:"column:content" json:"content"`
 	Like           int64  `gorm:"column:like" json:"like"`
 	Dislike        int64  `gorm:"column:dislike" json:"dislike"`
@@ -75,6 +87,8 @@ func (bookCommentDTO *BookCommentDTO) Transfer() *BookCommentDO {
 	return &BookCommentDO{
 		Id:             bookCommentDTO.Id,
 		BookId:         bookCommentDTO.BookId,
+		AuthorId:       bookCommentDTO.AuthorId,
+		AuthorName:     bookCommentDTO.AuthorName,
 		Content:        bookCommentDTO.Content,
 		Like:           bookCommentDTO.Like,
 		Dislike:        bookCommentDTO.Dislike,
@@ -88,6 +102,8 @@ func (bookCommentDO *BookCommentDO) TransformToDTO() *BookCommentDTO {
 	return &BookCommentDTO{
 		Id:             bookCommentDO.Id,
 		BookId:         bookCommentDO.BookId,
+		AuthorId:       bookCommentDO.AuthorId,
+		AuthorName:     bookCommentDO.AuthorName,
 		Content:        bookCommentDO.Content,
 		Like:           bookCommentDO.Like,
 		Dislike:        bookCommentDO.Dislike,
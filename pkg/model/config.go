@@ -1,5 +1,11 @@
 package model
 
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
 type DBConfig struct {
 	UserName string
 	PassWord string
@@ -13,8 +19,8 @@ type DBConfig struct {
 func (config *DBConfig) CreateDsn() string {
 	// 该方法根据DBConfig结构体中的配置信息，构造并返回一个数据库连接字符串。
 	// 这个连接字符串可以用于建立与数据库的连接。
-	// todo[ruibo]
-	return ""
+	return fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=%s&parseTime=True&loc=%s",
+		config.UserName, config.PassWord, config.Host, config.DBName, config.Charset, url.QueryEscape(config.TimeZone))
 }
 
 type LogConfig struct {
@@ -32,9 +38,120 @@ type ESConfig struct {
 	Password  string
 }
 
+// JWTConfig JWT签发与校验配置
+type JWTConfig struct {
+	Algorithm      string        // 签名算法, 支持 HS256 和 RS256
+	Secret         string        // HS256 使用的密钥
+	PrivateKeyFile string        // RS256 使用的私钥文件(PEM)
+	PublicKeyFile  string        // RS256 使用的公钥文件(PEM)
+	Issuer         string        // 签发者
+	Expire         time.Duration // 访问令牌有效期
+	RefreshExpire  time.Duration // 刷新令牌有效期
+	VerifyExpire   time.Duration // 邮箱验证令牌有效期
+	ResetExpire    time.Duration // 密码重置令牌有效期
+}
+
+// PasswordConfig 密码哈希配置
+type PasswordConfig struct {
+	BcryptCost int // bcrypt代价, 取值 4~31
+}
+
+// LoginConfig 登录防爆破配置
+type LoginConfig struct {
+	MaxFailures   int           // 同一用户名连续失败多少次后锁定账号
+	IPMaxFailures int           // 同一IP连续失败多少次后封禁该IP的登录
+	LockDuration  time.Duration // 锁定时长
+	BaseDelay     time.Duration // 退避基础时长, 每多失败一次翻倍
+	MaxDelay      time.Duration // 退避最大时长
+	Window        time.Duration // 失败计数的统计窗口, 超过窗口未再失败则清零
+}
+
+// MailConfig 邮件配置
+type MailConfig struct {
+	Driver      string // smtp, file 或 log, 后两者只用于本地开发
+	Host        string // SMTP服务器
+	Port        int
+	Username    string
+	Password    string
+	From        string // 发件人
+	Dir         string // file 驱动写入的目录
+	LinkBaseURL string // 邮件中链接的前缀, 例如 https://yujian.example.com
+}
+
+// OIDCProviderConfig 一个OpenID Connect身份提供方
+type OIDCProviderConfig struct {
+	Name         string   `mapstructure:"name"`   // 提供方名称, 出现在登录路径中
+	Issuer       string   `mapstructure:"issuer"` // 用于服务发现的 issuer 地址
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	RedirectURL  string   `mapstructure:"redirect_url"` // 回调地址, 指向 /oauth/:provider/callback
+	Scopes       []string `mapstructure:"scopes"`       // 为空时使用 openid profile email
+}
+
+// OIDCConfig 第三方登录配置
+type OIDCConfig struct {
+	Providers []*OIDCProviderConfig
+}
+
+// MFAConfig 二次验证配置
+type MFAConfig struct {
+	Issuer        string        // 显示在验证器App中的发行方名称
+	PendingExpire time.Duration // 两步登录中间令牌的有效期
+	RecoveryCodes int           // 生成的恢复码数量
+}
+
+// APIKeyConfig API Key 配置
+type APIKeyConfig struct {
+	DefaultExpire time.Duration // 未指定过期时间时的有效期
+	MaxExpire     time.Duration // 允许的最长有效期
+	MaxPerUser    int           // 每个用户最多持有的有效密钥数量
+}
+
+// ExportConfig 个人数据导出配置
+type ExportConfig struct {
+	Dir             string        // 导出文件存放目录
+	LinkExpire      time.Duration // 下载链接和导出文件的有效期
+	MaxConcurrent   int           // 同时打包的任务数量
+	CleanupInterval time.Duration // 清理过期导出文件的间隔
+}
+
+// FeedConfig 首页动态配置
+type FeedConfig struct {
+	FanOutMaxFollowers int64 // 粉丝数低于该值的用户发布动态时写入粉丝的收件箱, 否则由粉丝读取时拉取
+	BackfillOnFollow   int   // 关注后从对方最近的动态中补进收件箱的数量
+}
+
+// RealtimeConfig 实时推送配置, 连接数限制按单个进程计算
+type RealtimeConfig struct {
+	BufferSize        int           // 每个连接待发送事件的队列长度, 客户端读得太慢导致队列满时断开连接
+	MaxConnsPerUser   int           // 每个用户同时保持的连接数上限
+	MaxConns          int           // 全部连接数上限
+	HeartbeatInterval time.Duration // 没有事件时发送心跳的间隔, 防止代理断开空闲连接
+	MaxConnAge        time.Duration // 单个连接的最长保持时间, 到期后由客户端重连, 重连时重新校验令牌
+}
+
+// ReputationConfig 声望配置, 扣分用负数
+type ReputationConfig struct {
+	Post             VotePoints // 帖子被点赞、点踩
+	PostComment      VotePoints // 帖子评论被点赞、点踩
+	BookComment      VotePoints // 书评被点赞、点踩
+	ModeratorRemoval int64      // 内容被版主删除
+}
+
 type AppConfig struct {
-	DB     *DBConfig
-	Log    *LogConfig
-	Server *ServerConfig
-	ES     *ESConfig
+	DB         *DBConfig
+	Log        *LogConfig
+	Server     *ServerConfig
+	ES         *ESConfig
+	JWT        *JWTConfig
+	Password   *PasswordConfig
+	Login      *LoginConfig
+	Mail       *MailConfig
+	OIDC       *OIDCConfig
+	MFA        *MFAConfig
+	APIKey     *APIKeyConfig
+	Export     *ExportConfig
+	Feed       *FeedConfig
+	Realtime   *RealtimeConfig
+	Reputation *ReputationConfig
 }
@@ -6,4 +6,78 @@ const (
 	Success       ErrorCode = 0
 	UserExists    ErrorCode = 301
 	UserNotExists ErrorCode = 302
+	WrongPassword ErrorCode = 303
+	EmailInvalid  ErrorCode = 304
+	EmailExists   ErrorCode = 305
+	EmailNotSet   ErrorCode = 306
+
+	InvalidCredentials ErrorCode = 307 // 用户名或密码错误, 登录时不区分用户是否存在
+
+	Unauthorized ErrorCode = 401 // 未登录或缺少令牌
+	TokenInvalid ErrorCode = 402 // 令牌无效
+	TokenExpired ErrorCode = 403 // 令牌已过期
+
+	ActionTokenInvalid ErrorCode = 404 // 邮箱验证或密码重置令牌无效、已过期或已使用
+
+	RefreshTokenInvalid ErrorCode = 411 // 刷新令牌无效或已过期
+	RefreshTokenReused  ErrorCode = 412 // 刷新令牌被重复使用, 整条会话链已吊销
+
+	PermissionDenied ErrorCode = 420 // 没有权限
+	NotResourceOwner ErrorCode = 421 // 不是资源的作者
+
+	LoginTooFrequent ErrorCode = 430 // 登录失败后处于退避期, 需要稍后再试
+	LoginIPBlocked   ErrorCode = 431 // 该IP登录失败次数过多, 暂时禁止登录
+	AccountLocked    ErrorCode = 432 // 账号登录失败次数过多, 已临时锁定
+
+	OAuthProviderUnknown ErrorCode = 450 // 未配置的第三方登录提供方
+	OAuthStateInvalid    ErrorCode = 451 // 第三方登录的 state 无效或已过期
+	OAuthFailed          ErrorCode = 452 // 与第三方交换令牌或校验身份失败
+
+	MFARequired       ErrorCode = 460 // 密码正确, 还需要提交二次验证码
+	MFACodeInvalid    ErrorCode = 461 // 二次验证码错误或已使用
+	MFANotEnrolled    ErrorCode = 462 // 尚未登记二次验证
+	MFAAlreadyEnabled ErrorCode = 463 // 已经启用二次验证
+
+	APIKeyInvalid      ErrorCode = 470 // API Key 不存在或已吊销
+	APIKeyExpired      ErrorCode = 471 // API Key 已过期
+	APIKeyScopeDenied  ErrorCode = 472 // API Key 没有所需的授权范围
+	APIKeyLimitReached ErrorCode = 473 // 有效 API Key 数量达到上限
+
+	ExportJobNotFound ErrorCode = 480 // 没有导出任务
+	ExportJobNotReady ErrorCode = 481 // 导出任务尚未完成
+	ExportFileExpired ErrorCode = 482 // 导出文件已过期
+
+	CannotFollowSelf ErrorCode = 490 // 不能关注自己
+	BlockedByUser    ErrorCode = 491 // 已被对方拉黑
+	CannotBlockSelf  ErrorCode = 492 // 不能拉黑或屏蔽自己
+
+	BookNotExists       ErrorCode = 510 // 书不存在
+	ShelfEntryNotExists ErrorCode = 511 // 书不在书架上
+	ShelfEntryInvalid   ErrorCode = 512 // 阅读状态、日期、进度或评分不合法
+	ShelfNotExists      ErrorCode = 513 // 自建书架不存在
+	ShelfInfoInvalid    ErrorCode = 514 // 书架名称为空、过长或重名, 或描述过长
+	ShelfLimitReached   ErrorCode = 515 // 自建书架数量达到上限
+
+	ReadingGoalInvalid   ErrorCode = 520 // 阅读目标的年份或数量不合法
+	ReadingGoalNotExists ErrorCode = 521 // 没有设置该年的阅读目标
+
+	NotificationNotExists ErrorCode = 530 // 通知不存在
+	ReactionInvalid       ErrorCode = 531 // 表态只能是 like、dislike 或空
+
+	RealtimeConnLimit ErrorCode = 540 // 实时推送的连接数达到上限
+
+	CannotMessageSelf     ErrorCode = 550 // 不能给自己发私信
+	MessageNotAllowed     ErrorCode = 551 // 对方的私信设置不允许你发起会话
+	MessageTargetBlocked  ErrorCode = 552 // 你已拉黑对方, 解除后才能发私信
+	MessageInvalid        ErrorCode = 553 // 私信内容为空或过长
+	ConversationNotExists ErrorCode = 554 // 会话不存在或不是会话成员
+
+	GroupNotExists        ErrorCode = 560 // 读书会不存在, 或是只邀请的读书会而你不是成员也没有受邀
+	GroupInfoInvalid      ErrorCode = 561 // 读书会名称为空或过长、简介过长, 或可见性不合法
+	GroupPermissionDenied ErrorCode = 562 // 只有读书会的创建者或管理员可以操作
+	GroupMembersOnly      ErrorCode = 563 // 只有读书会成员可以查看或发帖
+	GroupAlreadyMember    ErrorCode = 564 // 已经是读书会成员
+	GroupOwnerCannotLeave ErrorCode = 565 // 创建者不能退出, 需要先转让或解散读书会
+	GroupRequestNotExists ErrorCode = 566 // 没有待处理的申请或邀请
+	GroupRoleInvalid      ErrorCode = 567 // 角色只能是 owner、admin 或 member, 或不能这样修改该成员的角色
 )
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"time"
+)
+
+// ExportStatus 数据导出任务状态
+type ExportStatus string
+
+const (
+	ExportPending ExportStatus = "pending" // 排队中
+	ExportRunning ExportStatus = "running" // 打包中
+	ExportDone    ExportStatus = "done"    // 可以下载
+	ExportFailed  ExportStatus = "failed"  // 打包失败
+	ExportExpired ExportStatus = "expired" // 下载期限已过, 文件已清理
+)
+
+// DataExportDTO 数据导出任务DTO
+type DataExportDTO struct {
+	Id          int64        `json:"id"`
+	Status      ExportStatus `json:"status"`
+	CreateTime  time.Time    `json:"create_time"`
+	FinishTime  *time.Time   `json:"finish_time"`
+	ExpiresAt   *time.Time   `json:"expires_at"`
+	DownloadURL string       `json:"download_url,omitempty"` // 带签名的下载链接, 仅在可以下载时返回
+}
+
+// DataExportDO 数据导出任务DO
+type DataExportDO struct {
+	Id         int64        `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId     int64        `gorm:"column:user_id;index" json:"user_id"`
+	Status     ExportStatus `gorm:"column:status;size:16;index" json:"status"`
+	FileName   string       `gorm:"column:file_name;size:128" json:"-"`
+	ErrMsg     string       `gorm:"column:err_msg;size:255" json:"err_msg"`
+	CreateTime time.Time    `gorm:"column:create_time" json:"create_time"`
+	FinishTime *time.Time   `gorm:"column:finish_time" json:"finish_time"`
+	ExpiresAt  *time.Time   `gorm:"column:expires_at" json:"expires_at"`
+}
+
+func (d DataExportDO) TableName() string {
+	return "data_export"
+}
+
+func (d *DataExportDO) Transfer() *DataExportDTO {
+	return &DataExportDTO{
+		Id:         d.Id,
+		Status:     d.Status,
+		CreateTime: d.CreateTime,
+		FinishTime: d.FinishTime,
+		ExpiresAt:  d.ExpiresAt,
+	}
+}
+
+// DataExportResponseDTO 数据导出任务响应
+type DataExportResponseDTO struct {
+	BaseResp
+	Export *DataExportDTO `json:"export"`
+}
+
+// UserReactionsDTO 用户点赞点踩过的内容ID
+type UserReactionsDTO struct {
+	LikedPosts           []int64 `json:"liked_posts"`
+	DislikedPosts        []int64 `json:"disliked_posts"`
+	LikedPostComments    []int64 `json:"liked_post_comments"`
+	DislikedPostComments []int64 `json:"disliked_post_comments"`
+	LikedBookComments    []int64 `json:"liked_book_comments"`
+	DislikedBookComments []int64 `json:"disliked_book_comments"`
+}
+
+// ExportedPostDTO 导出的帖子, 包含正文
+type ExportedPostDTO struct {
+	Id        int64     `json:"id"`
+	Title     string    `json:"title"`
+	ContentId string    `json:"content_id"`
+	Content   string    `json:"content"`
+	EditTime  time.Time `json:"edit_time"`
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+)
+
+// FollowDO 关注关系DO, FollowerId 关注了 FolloweeId
+type FollowDO struct {
+	Id         int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	FollowerId int64     `gorm:"column:follower_id;uniqueIndex:idx_follow_pair,priority:1" json:"follower_id"`
+	FolloweeId int64     `gorm:"column:followee_id;uniqueIndex:idx_follow_pair,priority:2;index:idx_followee_time,priority:1" json:"followee_id"`
+	CreateTime time.Time `gorm:"column:create_time;index:idx_followee_time,priority:2" json:"create_time"`
+}
+
+func (f FollowDO) TableName() string {
+	return "user_follow"
+}
+
+// FollowUserDTO 关注列表中的一项
+type FollowUserDTO struct {
+	User       *UserDTO  `json:"user"`
+	FollowTime time.Time `json:"follow_time"`
+	Mutual     bool      `json:"mutual"` // 与列表所属用户互相关注
+}
+
+// FollowListResponseDTO 关注/粉丝列表响应
+type FollowListResponseDTO struct {
+	BaseResp
+	Total int64            `json:"total"`
+	Users []*FollowUserDTO `json:"users"`
+}
+
+// FollowStatusDTO 当前用户与目标用户之间的关注关系
+type FollowStatusDTO struct {
+	Following  bool `json:"following"`   // 当前用户关注了对方
+	FollowedBy bool `json:"followed_by"` // 对方关注了当前用户
+	Mutual     bool `json:"mutual"`
+}
+
+// FollowStatusResponseDTO 关注关系响应
+type FollowStatusResponseDTO struct {
+	BaseResp
+	Status *FollowStatusDTO `json:"status"`
+}
+
+// PostListResponseDTO 帖子列表响应
+type PostListResponseDTO struct {
+	BaseResp
+	Posts []*PostDTO `json:"posts"`
+}
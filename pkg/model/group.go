@@ -0,0 +1,228 @@
+package model
+
+import (
+	"time"
+)
+
+// GroupVisibility 读书会的可见性
+type GroupVisibility string
+
+const (
+	GroupPublic     GroupVisibility = "public"      // 所有人可见, 可以直接加入, 帖子公开
+	GroupPrivate    GroupVisibility = "private"     // 所有人可见, 申请后由管理员批准加入, 帖子只有成员可见
+	GroupInviteOnly GroupVisibility = "invite_only" // 只有成员和受邀的人可见, 只能受邀加入, 帖子只有成员可见
+)
+
+// Valid 是否为已定义的可见性
+func (v GroupVisibility) Valid() bool {
+	return v == GroupPublic || v == GroupPrivate || v == GroupInviteOnly
+}
+
+// GroupRole 读书会成员的角色
+type GroupRole string
+
+const (
+	GroupOwner  GroupRole = "owner"  // 创建者, 每个读书会只有一个, 可以任免管理员、转让和解散读书会
+	GroupAdmin  GroupRole = "admin"  // 管理员, 可以修改读书会信息、审批申请、邀请和移除普通成员、删除读书会内的帖子
+	GroupMember GroupRole = "member" // 普通成员
+)
+
+// Valid 是否为已定义的角色
+func (r GroupRole) Valid() bool {
+	return r == GroupOwner || r == GroupAdmin || r == GroupMember
+}
+
+// CanManage 是否可以管理读书会
+func (r GroupRole) CanManage() bool {
+	return r == GroupOwner || r == GroupAdmin
+}
+
+// GroupRequestType 待处理的加入请求类型
+type GroupRequestType string
+
+const (
+	GroupJoinRequest GroupRequestType = "request" // 用户申请加入, 等待管理员批准
+	GroupInvitation  GroupRequestType = "invite"  // 管理员邀请, 等待用户接受
+)
+
+// 读书会名称、简介和申请附言的长度上限, 按字符计
+const (
+	MaxGroupNameLen           = 64
+	MaxGroupDescriptionLen    = 1000
+	MaxGroupRequestMessageLen = 200
+)
+
+// GroupDTO 读书会DTO
+type GroupDTO struct {
+	Id          int64            `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Visibility  GroupVisibility  `json:"visibility"`
+	Owner       *UserDTO         `json:"owner"`
+	CurrentBook *BookInfoDTO     `json:"current_book,omitempty"` // 正在共读的书
+	MemberCount int64            `json:"member_count"`
+	CreateTime  time.Time        `json:"create_time"`
+	MyRole      GroupRole        `json:"my_role,omitempty"` // 当前用户的角色, 不是成员时为空
+	Pending     GroupRequestType `json:"pending,omitempty"` // 当前用户待处理的申请或邀请
+}
+
+// TransformToDO 将GroupDTO转换为GroupDO
+func (g *GroupDTO) TransformToDO() *GroupDO {
+	group := &GroupDO{
+		Id:          g.Id,
+		Name:        g.Name,
+		Description: g.Description,
+		Visibility:  g.Visibility,
+		MemberCount: g.MemberCount,
+		CreateTime:  g.CreateTime,
+	}
+	if g.Owner != nil {
+		group.OwnerId = g.Owner.Id
+	}
+	if g.CurrentBook != nil {
+		group.CurrentBookId = g.CurrentBook.Id
+	}
+	return group
+}
+
+// GroupDO 读书会DO, group 是 MySQL 的保留字, 表名为 reading_group
+type GroupDO struct {
+	Id            int64           `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Name          string          `gorm:"column:name;size:64" json:"name"`
+	Description   string          `gorm:"column:description;size:4000" json:"description"`
+	Visibility    GroupVisibility `gorm:"column:visibility;size:16;index" json:"visibility"`
+	OwnerId       int64           `gorm:"column:owner_id;index" json:"owner_id"`
+	CurrentBookId int64           `gorm:"column:current_book_id;index" json:"current_book_id"` // 关联 BookInfoDO, 没有时为0
+	MemberCount   int64           `gorm:"column:member_count;default:0" json:"member_count"`
+	CreateTime    time.Time       `gorm:"column:create_time" json:"create_time"`
+	UpdateTime    time.Time       `gorm:"column:update_time" json:"update_time"`
+}
+
+func (g GroupDO) TableName() string {
+	return "reading_group"
+}
+
+// TransformToDTO 将GroupDO转换为GroupDTO, 当前在读的书已被删除时 book 为 nil
+func (g *GroupDO) TransformToDTO(owner *UserDTO, book *BookInfoDTO) *GroupDTO {
+	return &GroupDTO{
+		Id:          g.Id,
+		Name:        g.Name,
+		Description: g.Description,
+		Visibility:  g.Visibility,
+		Owner:       owner,
+		CurrentBook: book,
+		MemberCount: g.MemberCount,
+		CreateTime:  g.CreateTime,
+	}
+}
+
+// GroupMemberDO 读书会成员
+type GroupMemberDO struct {
+	Id       int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	GroupId  int64     `gorm:"column:group_id;uniqueIndex:idx_group_member,priority:1" json:"group_id"`
+	UserId   int64     `gorm:"column:user_id;uniqueIndex:idx_group_member,priority:2;index" json:"user_id"`
+	Role     GroupRole `gorm:"column:role;size:16" json:"role"`
+	JoinTime time.Time `gorm:"column:join_time" json:"join_time"`
+}
+
+func (m GroupMemberDO) TableName() string {
+	return "reading_group_member"
+}
+
+// GroupRequestDO 待处理的加入申请或邀请, 处理后删除; 同一个人在同一读书会最多有一条
+type GroupRequestDO struct {
+	Id         int64            `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	GroupId    int64            `gorm:"column:group_id;uniqueIndex:idx_group_request,priority:1" json:"group_id"`
+	UserId     int64            `gorm:"column:user_id;uniqueIndex:idx_group_request,priority:2;index" json:"user_id"`
+	Type       GroupRequestType `gorm:"column:type;size:16" json:"type"`
+	InviterId  int64            `gorm:"column:inviter_id" json:"inviter_id"` // 邀请人, 申请时为0
+	Message    string           `gorm:"column:message;size:1000" json:"message"`
+	CreateTime time.Time        `gorm:"column:create_time" json:"create_time"`
+}
+
+func (r GroupRequestDO) TableName() string {
+	return "reading_group_request"
+}
+
+// GroupMemberDTO 读书会成员
+type GroupMemberDTO struct {
+	User     *UserDTO  `json:"user"`
+	Role     GroupRole `json:"role"`
+	JoinTime time.Time `json:"join_time"`
+}
+
+// GroupRequestDTO 加入申请或邀请
+type GroupRequestDTO struct {
+	Id         int64            `json:"id"`
+	Group      *GroupDTO        `json:"group,omitempty"` // 查看自己收到的邀请时返回
+	User       *UserDTO         `json:"user"`
+	Type       GroupRequestType `json:"type"`
+	Inviter    *UserDTO         `json:"inviter,omitempty"`
+	Message    string           `json:"message"`
+	CreateTime time.Time        `json:"create_time"`
+}
+
+// CreateGroupRequestDTO 创建读书会请求
+type CreateGroupRequestDTO struct {
+	Name          string          `json:"name"`
+	Description   string          `json:"description"`
+	Visibility    GroupVisibility `json:"visibility"` // 不填时为 public
+	CurrentBookId int64           `json:"current_book_id"`
+}
+
+// UpdateGroupRequestDTO 修改读书会请求, 只更新传了的字段; current_book_id 传0表示清空
+type UpdateGroupRequestDTO struct {
+	Name          *string          `json:"name"`
+	Description   *string          `json:"description"`
+	Visibility    *GroupVisibility `json:"visibility"`
+	CurrentBookId *int64           `json:"current_book_id"`
+}
+
+// JoinGroupRequestDTO 加入读书会请求, 私密读书会的申请可以附言
+type JoinGroupRequestDTO struct {
+	Message string `json:"message"`
+}
+
+// InviteGroupMemberRequestDTO 邀请加入读书会请求
+type InviteGroupMemberRequestDTO struct {
+	UserId  int64  `json:"user_id"`
+	Message string `json:"message"`
+}
+
+// SetGroupRoleRequestDTO 修改成员角色请求, 设为 owner 表示转让读书会, 原创建者变为管理员
+type SetGroupRoleRequestDTO struct {
+	Role GroupRole `json:"role"`
+}
+
+// GroupResponseDTO 读书会响应
+type GroupResponseDTO struct {
+	BaseResp
+	Group *GroupDTO `json:"group"`
+}
+
+// GroupListResponseDTO 读书会列表响应
+type GroupListResponseDTO struct {
+	BaseResp
+	Total  int64       `json:"total"`
+	Groups []*GroupDTO `json:"groups"`
+}
+
+// JoinGroupResponseDTO 加入读书会或邀请成员的响应, 已加入时 Joined 为 true, 否则 Pending 为等待处理的申请或邀请
+type JoinGroupResponseDTO struct {
+	BaseResp
+	Joined  bool             `json:"joined"`
+	Pending GroupRequestType `json:"pending,omitempty"`
+}
+
+// GroupMemberListResponseDTO 成员列表响应
+type GroupMemberListResponseDTO struct {
+	BaseResp
+	Total   int64             `json:"total"`
+	Members []*GroupMemberDTO `json:"members"`
+}
+
+// GroupRequestListResponseDTO 申请或邀请列表响应
+type GroupRequestListResponseDTO struct {
+	BaseResp
+	Requests []*GroupRequestDTO `json:"requests"`
+}
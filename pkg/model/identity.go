@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+)
+
+// UserIdentityDO 第三方身份与本站用户的绑定关系
+type UserIdentityDO struct {
+	Id         int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId     int64     `gorm:"column:user_id;index" json:"user_id"`
+	Provider   string    `gorm:"column:provider;size:32;uniqueIndex:idx_provider_subject" json:"provider"`
+	Subject    string    `gorm:"column:subject;size:255;uniqueIndex:idx_provider_subject" json:"subject"` // 提供方的 sub
+	Email      string    `gorm:"column:email;size:128" json:"email"`                                      // 提供方给出的邮箱, 仅作记录
+	CreateTime time.Time `gorm:"column:create_time" json:"create_time"`
+}
+
+func (u UserIdentityDO) TableName() string {
+	return "user_identity"
+}
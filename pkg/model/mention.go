@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+)
+
+// MentionSubject 提及所在的内容类型
+type MentionSubject string
+
+const (
+	MentionInPost        MentionSubject = "post"         // 帖子正文
+	MentionInPostComment MentionSubject = "post_comment" // 帖子评论
+)
+
+// MaxMentionedUsers 一条内容最多解析的不同用户名数, 之后出现的新用户名按普通文字处理
+const MaxMentionedUsers = 10
+
+// MentionDO 内容中对用户的一处提及
+// 按用户ID关联, 被提及的人改名后仍然指向同一个人; Start 和 Length 是 @用户名 在内容中的位置, 按字符计, 包含 @ 符号
+type MentionDO struct {
+	Id         int64          `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId     int64          `gorm:"column:user_id;index" json:"user_id"` // 被提及的人
+	Subject    MentionSubject `gorm:"column:subject;size:16;index:idx_mention_subject,priority:1" json:"subject"`
+	SubjectId  int64          `gorm:"column:subject_id;index:idx_mention_subject,priority:2" json:"subject_id"`
+	PostId     int64          `gorm:"column:post_id;index" json:"post_id"` // 所在的帖子, 用于查询提到我的帖子以及帖子删除时清理
+	AuthorId   int64          `gorm:"column:author_id;index" json:"author_id"`
+	Start      int            `gorm:"column:start" json:"start"`
+	Length     int            `gorm:"column:length" json:"length"`
+	CreateTime time.Time      `gorm:"column:create_time" json:"create_time"`
+}
+
+func (m MentionDO) TableName() string {
+	return "mention"
+}
+
+// MentionDTO 内容中的一处提及, User 为被提及的人当前的公开信息, 客户端据此把 Start 起 Length 个字符渲染成链接
+type MentionDTO struct {
+	User   *UserDTO `json:"user"`
+	Start  int      `json:"start"`
+	Length int      `json:"length"`
+}
+
+// UserSuggestionListResponseDTO 用户名补全响应
+type UserSuggestionListResponseDTO struct {
+	BaseResp
+	Users []*UserDTO `json:"users"`
+}
@@ -0,0 +1,151 @@
+package model
+
+import (
+	"time"
+)
+
+// MessagePolicy 谁可以给用户发私信
+// 不论哪种设置, 用户自己发过消息的会话里对方都可以回复
+type MessagePolicy string
+
+const (
+	MessageEveryone  MessagePolicy = "everyone"  // 所有人
+	MessageFollowing MessagePolicy = "following" // 只有自己关注的人
+	MessageNobody    MessagePolicy = "nobody"    // 不接收新的私信
+)
+
+// Valid 是否为已定义的私信设置
+func (p MessagePolicy) Valid() bool {
+	return p == MessageEveryone || p == MessageFollowing || p == MessageNobody
+}
+
+// MaxMessageLen 私信内容的最大长度, 按字符计
+const MaxMessageLen = 2000
+
+// ConversationDO 两个用户之间的会话, 每对用户只有一个, UserLowId 为两人中较小的ID
+type ConversationDO struct {
+	Id         int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserLowId  int64     `gorm:"column:user_low_id;uniqueIndex:idx_conversation_pair,priority:1" json:"user_low_id"`
+	UserHighId int64     `gorm:"column:user_high_id;uniqueIndex:idx_conversation_pair,priority:2" json:"user_high_id"`
+	CreateTime time.Time `gorm:"column:create_time" json:"create_time"`
+}
+
+func (c ConversationDO) TableName() string {
+	return "conversation"
+}
+
+// ConversationMemberDO 会话中一方的状态, 会话列表、未读数和已读回执都从这里读取
+// LastMessageId 冗余会话最新一条消息的ID, 用于按最近消息排序和游标分页
+type ConversationMemberDO struct {
+	Id                int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	ConversationId    int64     `gorm:"column:conversation_id;uniqueIndex:idx_member_conversation_user,priority:1" json:"conversation_id"`
+	UserId            int64     `gorm:"column:user_id;uniqueIndex:idx_member_conversation_user,priority:2;index:idx_member_inbox,priority:1" json:"user_id"`
+	PeerId            int64     `gorm:"column:peer_id;index" json:"peer_id"`
+	LastMessageId     int64     `gorm:"column:last_message_id;index:idx_member_inbox,priority:2" json:"last_message_id"`
+	LastReadMessageId int64     `gorm:"column:last_read_message_id" json:"last_read_message_id"`
+	UnreadCount       int64     `gorm:"column:unread_count;default:0" json:"unread_count"`
+	UpdateTime        time.Time `gorm:"column:update_time" json:"update_time"`
+}
+
+func (m ConversationMemberDO) TableName() string {
+	return "conversation_member"
+}
+
+// MessageDTO 私信DTO
+type MessageDTO struct {
+	Id             int64     `json:"id"`
+	ConversationId int64     `json:"conversation_id"`
+	SenderId       int64     `json:"sender_id"`
+	RecipientId    int64     `json:"recipient_id"`
+	Content        string    `json:"content"`
+	CreateTime     time.Time `json:"create_time"`
+	Read           bool      `json:"read"` // 接收方是否已读
+}
+
+// TransformToDO 将MessageDTO转换为MessageDO
+func (m *MessageDTO) TransformToDO() *MessageDO {
+	return &MessageDO{
+		Id:             m.Id,
+		ConversationId: m.ConversationId,
+		SenderId:       m.SenderId,
+		RecipientId:    m.RecipientId,
+		Content:        m.Content,
+		CreateTime:     m.CreateTime,
+	}
+}
+
+// MessageDO 私信DO
+type MessageDO struct {
+	Id             int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	ConversationId int64     `gorm:"column:conversation_id;index" json:"conversation_id"`
+	SenderId       int64     `gorm:"column:sender_id;index:idx_message_pair,priority:1" json:"sender_id"`
+	RecipientId    int64     `gorm:"column:recipient_id;index:idx_message_pair,priority:2;index" json:"recipient_id"`
+	Content        string    `gorm:"column:content;size:8000" json:"content"`
+	CreateTime     time.Time `gorm:"column:create_time" json:"create_time"`
+}
+
+func (m MessageDO) TableName() string {
+	return "message"
+}
+
+// TransformToDTO 将MessageDO转换为MessageDTO, recipientLastRead 为接收方已读到的消息ID
+func (m *MessageDO) TransformToDTO(recipientLastRead int64) *MessageDTO {
+	return &MessageDTO{
+		Id:             m.Id,
+		ConversationId: m.ConversationId,
+		SenderId:       m.SenderId,
+		RecipientId:    m.RecipientId,
+		Content:        m.Content,
+		CreateTime:     m.CreateTime,
+		Read:           m.Id <= recipientLastRead,
+	}
+}
+
+// ConversationDTO 会话列表中的一项
+type ConversationDTO struct {
+	Id                    int64       `json:"id"`
+	Peer                  *UserDTO    `json:"peer"`
+	LastMessage           *MessageDTO `json:"last_message,omitempty"`
+	UnreadCount           int64       `json:"unread_count"`
+	LastReadMessageId     int64       `json:"last_read_message_id"`      // 自己已读到的消息
+	PeerLastReadMessageId int64       `json:"peer_last_read_message_id"` // 对方已读到的消息, 用于已读回执
+	UpdateTime            time.Time   `json:"update_time"`
+}
+
+// SendMessageRequestDTO 发送私信请求
+type SendMessageRequestDTO struct {
+	Content string `json:"content"`
+}
+
+// SendMessageResponseDTO 发送私信响应
+type SendMessageResponseDTO struct {
+	BaseResp
+	Message *MessageDTO `json:"message"`
+}
+
+// MarkConversationReadRequestDTO 标记已读请求, MessageId 为0时标记到最新一条
+type MarkConversationReadRequestDTO struct {
+	MessageId int64 `json:"message_id"`
+}
+
+// ConversationListResponseDTO 会话列表响应, NextCursor 为空表示没有更多
+type ConversationListResponseDTO struct {
+	BaseResp
+	Conversations []*ConversationDTO `json:"conversations"`
+	NextCursor    string             `json:"next_cursor,omitempty"`
+}
+
+// MessageListResponseDTO 消息列表响应, 按消息ID倒序, NextCursor 为空表示没有更多
+type MessageListResponseDTO struct {
+	BaseResp
+	Messages              []*MessageDTO `json:"messages"`
+	PeerLastReadMessageId int64         `json:"peer_last_read_message_id"`
+	NextCursor            string        `json:"next_cursor,omitempty"`
+}
+
+// ConversationReadDTO 已读回执, 推送给对方
+type ConversationReadDTO struct {
+	ConversationId    int64 `json:"conversation_id"`
+	UserId            int64 `json:"user_id"`
+	LastReadMessageId int64 `json:"last_read_message_id"`
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"time"
+)
+
+// UserMFADO 用户的TOTP二次验证设置
+type UserMFADO struct {
+	UserId     int64     `gorm:"column:user_id;primaryKey" json:"user_id"`
+	Secret     string    `gorm:"column:secret;size:64" json:"-"` // base32编码的TOTP密钥
+	Enabled    bool      `gorm:"column:enabled" json:"enabled"`  // 确认之前只是登记, 不参与登录
+	LastStep   int64     `gorm:"column:last_step" json:"-"`      // 最近一次通过校验的时间步, 防止同一个验证码被重放
+	CreateTime time.Time `gorm:"column:create_time" json:"create_time"`
+}
+
+func (m UserMFADO) TableName() string {
+	return "user_mfa"
+}
+
+// RecoveryCodeDO 二次验证的一次性恢复码
+type RecoveryCodeDO struct {
+	Id       int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId   int64      `gorm:"column:user_id;index" json:"user_id"`
+	CodeHash string     `gorm:"column:code_hash;size:64" json:"-"`
+	UsedAt   *time.Time `gorm:"column:used_at" json:"used_at"`
+}
+
+func (r RecoveryCodeDO) TableName() string {
+	return "mfa_recovery_code"
+}
+
+// TOTPEnrollResponseDTO 登记TOTP的响应
+type TOTPEnrollResponseDTO struct {
+	BaseResp
+	Secret string `json:"secret"`
+	URI    string `json:"uri"` // otpauth:// 链接, 客户端可以生成二维码
+}
+
+// TOTPCodeRequestDTO 提交验证码的请求, Code 可以是TOTP验证码或恢复码
+type TOTPCodeRequestDTO struct {
+	Code string `json:"code"`
+}
+
+// TOTPConfirmResponseDTO 确认启用TOTP的响应, 恢复码只在这里返回一次
+type TOTPConfirmResponseDTO struct {
+	BaseResp
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFALoginRequestDTO 两步登录第二步的请求
+type MFALoginRequestDTO struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
@@ -0,0 +1,152 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"yujian-backend/pkg/utils"
+)
+
+// NotificationType 通知类型
+type NotificationType string
+
+const (
+	NotifyPostComment     NotificationType = "post_comment"      // 评论了你的帖子
+	NotifyCommentReply    NotificationType = "comment_reply"     // 回复了你的评论
+	NotifyPostLike        NotificationType = "post_like"         // 赞了你的帖子
+	NotifyPostCommentLike NotificationType = "post_comment_like" // 赞了你的帖子评论
+	NotifyBookCommentLike NotificationType = "book_comment_like" // 赞了你的书评
+	NotifyFollow          NotificationType = "follow"            // 关注了你
+	NotifyPostMention     NotificationType = "post_mention"      // 在帖子中提到了你
+	NotifyCommentMention  NotificationType = "comment_mention"   // 在评论中提到了你
+)
+
+// notificationActions 各类通知在消息中的动作描述
+var notificationActions = map[NotificationType]string{
+	NotifyPostComment:     "评论了你的帖子",
+	NotifyCommentReply:    "回复了你的评论",
+	NotifyPostLike:        "赞了你的帖子",
+	NotifyPostCommentLike: "赞了你的评论",
+	NotifyBookCommentLike: "赞了你的书评",
+	NotifyFollow:          "关注了你",
+	NotifyPostMention:     "在帖子中提到了你",
+	NotifyCommentMention:  "在评论中提到了你",
+}
+
+// MaxRecentActors 通知中保留用于展示的最近触发者数量
+const MaxRecentActors = 3
+
+// NotificationDO 通知DO
+// 同一接收者、同一类型、同一对象的未读通知合并为一条, 例如"5人赞了你的帖子"; 已读后再有新的触发会生成新的一条
+// 触发者去重依赖 NotificationActorDO, 这里只冗余人数和最近几个触发者用于展示
+type NotificationDO struct {
+	Id             int64            `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId         int64            `gorm:"column:user_id;index:idx_notification_group,priority:1;index:idx_notification_inbox,priority:1" json:"user_id"`
+	Type           NotificationType `gorm:"column:type;size:32;index:idx_notification_group,priority:2" json:"type"`
+	SubjectId      int64            `gorm:"column:subject_id;index:idx_notification_group,priority:3" json:"subject_id"` // 帖子、帖子评论或书评的ID, 关注通知为0
+	PostId         int64            `gorm:"column:post_id;index" json:"post_id"`                                         // 相关的帖子, 用于跳转以及帖子删除时清理
+	BookId         int64            `gorm:"column:book_id" json:"book_id"`
+	ActorCount     int64            `gorm:"column:actor_count;default:0" json:"actor_count"`
+	RecentActorIds string           `gorm:"column:recent_actor_ids;size:255" json:"recent_actor_ids"` // JSON数组, 最近的在前
+	Preview        string           `gorm:"column:preview;size:255" json:"preview"`                   // 最近一条评论或回复的摘要
+	Read           bool             `gorm:"column:is_read;index:idx_notification_group,priority:4" json:"read"`
+	CreateTime     time.Time        `gorm:"column:create_time" json:"create_time"`
+	UpdateTime     time.Time        `gorm:"column:update_time;index:idx_notification_inbox,priority:2" json:"update_time"`
+}
+
+func (n NotificationDO) TableName() string {
+	return "notification"
+}
+
+// NotificationActorDO 通知的触发者, 同一个人多次点赞只计一次
+type NotificationActorDO struct {
+	Id             int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	NotificationId int64     `gorm:"column:notification_id;uniqueIndex:idx_notification_actor,priority:1" json:"notification_id"`
+	ActorId        int64     `gorm:"column:actor_id;uniqueIndex:idx_notification_actor,priority:2;index" json:"actor_id"`
+	CreateTime     time.Time `gorm:"column:create_time" json:"create_time"`
+}
+
+func (n NotificationActorDO) TableName() string {
+	return "notification_actor"
+}
+
+// RecentActors 解析最近的触发者ID
+func (n *NotificationDO) RecentActors() []int64 {
+	var ids []int64
+	// 解析失败按没有触发者处理, 展示时退化为只显示人数
+	_ = utils.FromJSONString(n.RecentActorIds, &ids)
+	return ids
+}
+
+// NotificationDTO 通知
+type NotificationDTO struct {
+	Id         int64            `json:"id"`
+	Type       NotificationType `json:"type"`
+	Message    string           `json:"message"`
+	Actors     []*UserDTO       `json:"actors"`
+	ActorCount int64            `json:"actor_count"`
+	SubjectId  int64            `json:"subject_id,omitempty"`
+	PostId     int64            `json:"post_id,omitempty"`
+	BookId     int64            `json:"book_id,omitempty"`
+	Preview    string           `json:"preview,omitempty"`
+	Read       bool             `json:"read"`
+	CreateTime time.Time        `json:"create_time"`
+	UpdateTime time.Time        `json:"update_time"`
+}
+
+// TransformToDTO 将NotificationDO转换为NotificationDTO, actors 为已加载的最近触发者, 按最近的在前
+func (n *NotificationDO) TransformToDTO(actors []*UserDTO) *NotificationDTO {
+	if actors == nil {
+		actors = []*UserDTO{}
+	}
+	return &NotificationDTO{
+		Id:         n.Id,
+		Type:       n.Type,
+		Message:    notificationMessage(n.Type, actors, n.ActorCount),
+		Actors:     actors,
+		ActorCount: n.ActorCount,
+		SubjectId:  n.SubjectId,
+		PostId:     n.PostId,
+		BookId:     n.BookId,
+		Preview:    n.Preview,
+		Read:       n.Read,
+		CreateTime: n.CreateTime,
+		UpdateTime: n.UpdateTime,
+	}
+}
+
+// notificationMessage 生成通知的文字, 如"张三赞了你的帖子"、"张三等5人赞了你的帖子"
+func notificationMessage(t NotificationType, actors []*UserDTO, count int64) string {
+	action := notificationActions[t]
+	if len(actors) == 0 {
+		return fmt.Sprintf("%d人%s", count, action)
+	}
+	name := actors[0].DisplayName
+	if name == "" {
+		name = actors[0].Name
+	}
+	if count <= 1 {
+		return name + action
+	}
+	return fmt.Sprintf("%s等%d人%s", name, count, action)
+}
+
+// NotificationListResponseDTO 通知列表响应
+type NotificationListResponseDTO struct {
+	BaseResp
+	Total         int64              `json:"total"`
+	UnreadCount   int64              `json:"unread_count"`
+	Notifications []*NotificationDTO `json:"notifications"`
+}
+
+// UnreadCountResponseDTO 未读数响应, 通知和私信共用
+type UnreadCountResponseDTO struct {
+	BaseResp
+	UnreadCount int64 `json:"unread_count"`
+}
+
+// MarkAllReadResponseDTO 全部标为已读的响应
+type MarkAllReadResponseDTO struct {
+	BaseResp
+	Updated int64 `json:"updated"`
+}
@@ -6,14 +6,16 @@ import (
 
 // PostDTO 帖子DTO
 type PostDTO struct {
-	Id        int64             `json:"id"`
-	Author    *UserDTO          `json:"author"`
-	Title     string            `json:"title"`
-	ContentId string            `json:"content_id"`
-	EditTime  time.Time         `json:"edit_time"`
-	Comments  []*PostCommentDTO `json:"comments"`
-	LikeUserIds    []int64   `json:"like_user_ids"`    // 点赞的用户ID列表
-	DislikeUserIds []int64   `json:"dislike_user_ids"` // 点踩的用户ID列表
+	Id             int64             `json:"id"`
+	Author         *UserDTO          `json:"author"`
+	Title          string            `json:"title"`
+	ContentId      string            `json:"content_id"`
+	EditTime       time.Time         `json:"edit_time"`
+	Comments       []*PostCommentDTO `json:"comments"`
+	GroupId        int64             `json:"group_id,omitempty"` // 所属读书会, 为0表示不属于任何读书会
+	Mentions       []*MentionDTO     `json:"mentions,omitempty"` // 正文中提及的人
+	LikeUserIds    []int64           `json:"like_user_ids"`      // 点赞的用户ID列表
+	DislikeUserIds []int64           `json:"dislike_user_ids"`   // 点踩的用户ID列表
 }
 
 // TransformToDO 将PostDTO转换为PostDO
@@ -25,17 +27,19 @@ func (p *PostDTO) TransformToDO() *PostDO {
 		Title:      p.Title,
 		ContentId:  p.ContentId,
 		EditTime:   p.EditTime,
+		GroupId:    p.GroupId,
 	}
 }
 
 // PostDO 帖子DO
 type PostDO struct {
-	Id         int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-	AuthorId   int64     `gorm:"column:author_id" json:"author_id"`
-	AuthorName string    `gorm:"column:author_name" json:"author_name"`
-	Title      string    `gorm:"column:title" json:"title"`
-	ContentId  string    `gorm:"column:content_id" json:"content_id"`
-	EditTime   time.Time `gorm:"column:edit_time" json:"edit_time"`
+	Id             int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	AuthorId       int64     `gorm:"column:author_id;index" json:"author_id"`
+	AuthorName     string    `gorm:"column:author_name" json:"author_name"`
+	Title          string    `gorm:"column:title" json:"title"`
+	ContentId      string    `gorm:"column:content_id" json:"content_id"`
+	EditTime       time.Time `gorm:"column:edit_time" json:"edit_time"`
+	GroupId        int64     `gorm:"column:group_id;index;default:0" json:"group_id"` // 所属读书会, 为0表示不属于任何读书会
 	LikeUserIds    string    `gorm:"column:like_user_ids" json:"like_user_ids"`
 	DislikeUserIds string    `gorm:"column:dislike_user_ids" json:"dislike_user_ids"`
 }
@@ -53,6 +57,7 @@ func (p *PostDO) TransformToDTO(userDTO *UserDTO, comments []*PostCommentDTO) *P
 		ContentId: p.ContentId,
 		EditTime:  p.EditTime,
 		Comments:  comments,
+		GroupId:   p.GroupId,
 	}
 }
 
@@ -90,25 +95,28 @@ func (p *PostEsModel) GetTitle() string {
 
 // PostCommentDTO 帖子评论DTO
 type PostCommentDTO struct {
-	Id             int64     `json:"id"`
-	PostId         int64     `json:"post_id"`
-	Author         UserDTO   `json:"author"`
-	EditTime       time.Time `json:"edit_time"`
-	Content        string    `json:"content"`          // 评论的内容不会很长,直接存mysql
-	Score          int       `json:"score"`            // 评论的分数
-	LikeUserIds    []int64   `json:"like_user_ids"`    // 点赞的用户ID列表
-	DislikeUserIds []int64   `json:"dislike_user_ids"` // 点踩的用户ID列表
+	Id             int64         `json:"id"`
+	PostId         int64         `json:"post_id"`
+	ReplyToId      int64         `json:"reply_to_id,omitempty"` // 回复的评论ID, 直接评论帖子时为0
+	Author         UserDTO       `json:"author"`
+	EditTime       time.Time     `json:"edit_time"`
+	Content        string        `json:"content"`            // 评论的内容不会很长,直接存mysql
+	Score          int           `json:"score"`              // 评论的分数
+	Mentions       []*MentionDTO `json:"mentions,omitempty"` // 评论中提及的人
+	LikeUserIds    []int64       `json:"like_user_ids"`      // 点赞的用户ID列表
+	DislikeUserIds []int64       `json:"dislike_user_ids"`   // 点踩的用户ID列表
 }
 
 // PostCommentDO 帖子评论DO
 type PostCommentDO struct {
 	Id             int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
 	PostId         int64     `gorm:"column:post_id" json:"post_id"`
+	ReplyToId      int64     `gorm:"column:reply_to_id;default:0" json:"reply_to_id"`
 	AuthorId       int64     `gorm:"column:author_id" json:"author_id"`
 	AuthorName     string    `gorm:"column:author_name" json:"author_name"`
 	EditTime       time.Time `gorm:"column:edit_time" json:"edit_time"`
 	Content        string    `gorm:"column:content" json:"content"` // 评论的内容不会很长,直接存mysql
-	Score          int       `gorm:"column:score" json:"score"`
+	Score          int       `gorm:"column:score" json:"score"`     // 点赞数减点踩数, 表态时更新
 	LikeUserIds    string    `gorm:"column:like_user_ids" json:"like_user_ids"`
 	DislikeUserIds string    `gorm:"column:dislike_user_ids" json:"dislike_user_ids"`
 }
@@ -120,11 +128,13 @@ func (p PostCommentDO) TableName() string {
 // TransformToDTO 将PostCommentDO转换为PostCommentDTO
 func (p *PostCommentDO) TransformToDTO() *PostCommentDTO {
 	return &PostCommentDTO{
-		Id:       p.Id,
-		PostId:   p.PostId,
-		Author:   UserDTO{Id: p.AuthorId, Name: p.AuthorName},
-		EditTime: p.EditTime,
-		Content:  p.Content,
+		Id:        p.Id,
+		PostId:    p.PostId,
+		ReplyToId: p.ReplyToId,
+		Author:    UserDTO{Id: p.AuthorId, Name: p.AuthorName},
+		EditTime:  p.EditTime,
+		Content:   p.Content,
+		Score:     p.Score,
 	}
 }
 
@@ -132,6 +142,7 @@ func (p *PostCommentDTO) TransformToDO() *PostCommentDO {
 	return &PostCommentDO{
 		Id:         p.Id,
 		PostId:     p.PostId,
+		ReplyToId:  p.ReplyToId,
 		AuthorId:   p.Author.Id,
 		AuthorName: p.Author.Name,
 		EditTime:   p.EditTime,
@@ -141,9 +152,39 @@ func (p *PostCommentDTO) TransformToDO() *PostCommentDO {
 
 // CreatePostRequestDTO 创建帖子请求DTO
 type CreatePostRequestDTO struct {
-	Title   string `json:"title"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	GroupId  int64  `json:"group_id"` // 发到读书会时填写, 必须是该读书会的成员
+	UserId   int64  `json:"-"`        // 由令牌中的当前用户填充
+	UserName string `json:"-"`
+}
+
+// UpdatePostRequestDTO 更新帖子请求DTO
+type UpdatePostRequestDTO struct {
+	Title string `json:"title"`
+}
+
+// UpdatePostCommentRequestDTO 更新帖子评论请求DTO
+type UpdatePostCommentRequestDTO struct {
 	Content string `json:"content"`
-	UserId  int64  `json:"user_id"`
+}
+
+// CreatePostCommentRequestDTO 发表帖子评论请求DTO
+type CreatePostCommentRequestDTO struct {
+	Content   string `json:"content"`
+	ReplyToId int64  `json:"reply_to_id"` // 回复同一帖子下的某条评论, 不填表示直接评论帖子
+}
+
+// CreatePostCommentResponseDTO 发表帖子评论响应DTO
+type CreatePostCommentResponseDTO struct {
+	BaseResp
+	CommentId int64 `json:"comment_id"`
+}
+
+// PostCommentListResponseDTO 帖子评论列表响应
+type PostCommentListResponseDTO struct {
+	BaseResp
+	Comments []*PostCommentDTO `json:"comments"`
 }
 
 // CreatePostResponseDTO 创建帖子响应DTO
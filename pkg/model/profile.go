@@ -0,0 +1,96 @@
+package model
+
+import (
+	"time"
+)
+
+// Gender 性别, 选填
+type Gender string
+
+const (
+	GenderUnset  Gender = ""
+	GenderMale   Gender = "male"
+	GenderFemale Gender = "female"
+	GenderOther  Gender = "other"
+)
+
+// Valid 是否为已定义的性别
+func (g Gender) Valid() bool {
+	switch g {
+	case GenderUnset, GenderMale, GenderFemale, GenderOther:
+		return true
+	}
+	return false
+}
+
+// UserProfileDTO 用户资料
+// 邮箱、性别、地区和私信设置属于私人信息, 只在本人或管理员查看时返回
+type UserProfileDTO struct {
+	Id             int64           `json:"id"`
+	Name           string          `json:"name"`
+	Role           Role            `json:"role"`
+	DisplayName    string          `json:"display_name"`
+	Bio            string          `json:"bio"`
+	Avatar         string          `json:"avatar"`
+	FavoriteGenres []string        `json:"favorite_genres"`
+	JoinTime       time.Time       `json:"join_time"`
+	FollowerCount  int64           `json:"follower_count"`
+	FollowingCount int64           `json:"following_count"`
+	ShelfCounts    *ShelfCountsDTO `json:"shelf_counts,omitempty"` // 由 biz 层单独查询填充
+	Reputation     *ReputationDTO  `json:"reputation,omitempty"`   // 由 biz 层单独查询填充
+
+	Email         string        `json:"email,omitempty"`
+	EmailVerified *bool         `json:"email_verified,omitempty"`
+	Gender        Gender        `json:"gender,omitempty"`
+	Region        string        `json:"region,omitempty"`
+	MessagePolicy MessagePolicy `json:"message_policy,omitempty"`
+}
+
+// PublicProfile 公开资料, 不包含任何私人信息
+func (userDTO *UserDTO) PublicProfile() *UserProfileDTO {
+	genres := userDTO.FavoriteGenres
+	if genres == nil {
+		genres = []string{}
+	}
+	return &UserProfileDTO{
+		Id:             userDTO.Id,
+		Name:           userDTO.Name,
+		Role:           userDTO.Role,
+		DisplayName:    userDTO.DisplayName,
+		Bio:            userDTO.Bio,
+		Avatar:         userDTO.Avatar,
+		FavoriteGenres: genres,
+		JoinTime:       userDTO.JoinTime,
+		FollowerCount:  userDTO.FollowerCount,
+		FollowingCount: userDTO.FollowingCount,
+	}
+}
+
+// PrivateProfile 完整资料, 只返回给本人或管理员
+func (userDTO *UserDTO) PrivateProfile() *UserProfileDTO {
+	profile := userDTO.PublicProfile()
+	emailVerified := userDTO.EmailVerified
+	profile.Email = userDTO.Email
+	profile.EmailVerified = &emailVerified
+	profile.Gender = userDTO.Gender
+	profile.Region = userDTO.Region
+	profile.MessagePolicy = userDTO.MessagePolicy
+	return profile
+}
+
+// UpdateProfileRequestDTO 修改资料请求, 只更新传了的字段, 传空字符串表示清空
+type UpdateProfileRequestDTO struct {
+	DisplayName    *string        `json:"display_name"`
+	Bio            *string        `json:"bio"`
+	Avatar         *string        `json:"avatar"`
+	Gender         *Gender        `json:"gender"`
+	Region         *string        `json:"region"`
+	FavoriteGenres *[]string      `json:"favorite_genres"`
+	MessagePolicy  *MessagePolicy `json:"message_policy"`
+}
+
+// UserProfileResponseDTO 用户资料响应
+type UserProfileResponseDTO struct {
+	BaseResp
+	Profile *UserProfileDTO `json:"profile"`
+}
@@ -0,0 +1,34 @@
+package model
+
+// Reaction 用户对帖子、帖子评论或书评的表态
+type Reaction string
+
+const (
+	ReactionNone    Reaction = ""        // 取消点赞或点踩
+	ReactionLike    Reaction = "like"    // 点赞
+	ReactionDislike Reaction = "dislike" // 点踩
+)
+
+// Valid 是否为已定义的表态
+func (r Reaction) Valid() bool {
+	return r == ReactionNone || r == ReactionLike || r == ReactionDislike
+}
+
+// SetReactionRequestDTO 点赞/点踩请求, reaction 为空表示取消
+type SetReactionRequestDTO struct {
+	Reaction Reaction `json:"reaction"`
+}
+
+// ReactionResultDTO 表态后的结果
+type ReactionResultDTO struct {
+	Reaction Reaction `json:"reaction"`
+	Previous Reaction `json:"-"` // 修改前的表态, 用于判断是否需要发通知
+	Likes    int      `json:"likes"`
+	Dislikes int      `json:"dislikes"`
+}
+
+// ReactionResponseDTO 点赞/点踩响应
+type ReactionResponseDTO struct {
+	BaseResp
+	*ReactionResultDTO
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+)
+
+// BlockDO 拉黑关系DO, UserId 拉黑了 TargetId
+// 被拉黑的用户不能评论拉黑者的帖子, 也不能关注拉黑者
+type BlockDO struct {
+	Id         int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId     int64     `gorm:"column:user_id;uniqueIndex:idx_block_pair,priority:1" json:"user_id"`
+	TargetId   int64     `gorm:"column:target_id;uniqueIndex:idx_block_pair,priority:2;index" json:"target_id"`
+	CreateTime time.Time `gorm:"column:create_time" json:"create_time"`
+}
+
+func (b BlockDO) TableName() string {
+	return "user_block"
+}
+
+// MuteDO 屏蔽关系DO, UserId 屏蔽了 TargetId
+// 只影响 UserId 自己看到的帖子和评论, 对方不会察觉
+type MuteDO struct {
+	Id         int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId     int64     `gorm:"column:user_id;uniqueIndex:idx_mute_pair,priority:1" json:"user_id"`
+	TargetId   int64     `gorm:"column:target_id;uniqueIndex:idx_mute_pair,priority:2;index" json:"target_id"`
+	CreateTime time.Time `gorm:"column:create_time" json:"create_time"`
+}
+
+func (m MuteDO) TableName() string {
+	return "user_mute"
+}
+
+// RelationUserDTO 拉黑/屏蔽列表中的一项
+type RelationUserDTO struct {
+	User       *UserDTO  `json:"user"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+// RelationListResponseDTO 拉黑/屏蔽列表响应
+type RelationListResponseDTO struct {
+	BaseResp
+	Total int64              `json:"total"`
+	Users []*RelationUserDTO `json:"users"`
+}
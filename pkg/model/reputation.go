@@ -0,0 +1,122 @@
+package model
+
+import (
+	"time"
+)
+
+// ReputationSubject 带来声望变化的内容类型
+type ReputationSubject string
+
+const (
+	ReputationPost        ReputationSubject = "post"         // 帖子
+	ReputationPostComment ReputationSubject = "post_comment" // 帖子评论
+	ReputationBookComment ReputationSubject = "book_comment" // 书评
+)
+
+// ReputationReason 声望变化的原因
+type ReputationReason string
+
+const (
+	ReputationLiked            ReputationReason = "liked"             // 内容被点赞
+	ReputationDisliked         ReputationReason = "disliked"          // 内容被点踩
+	ReputationLikeWithdrawn    ReputationReason = "like_withdrawn"    // 点赞被取消或改为点踩, 冲销之前的加分
+	ReputationDislikeWithdrawn ReputationReason = "dislike_withdrawn" // 点踩被取消或改为点赞, 冲销之前的扣分
+	ReputationRemoved          ReputationReason = "removed"           // 内容被版主删除
+)
+
+// VotePoints 一类内容被点赞、点踩时作者获得的声望, 扣分用负数
+type VotePoints struct {
+	Like    int64
+	Dislike int64
+}
+
+// ReputationEventDO 声望流水, 只追加不修改; 用户的声望就是其全部流水的合计
+// 取消表态时追加一条冲销记录, 不删除原记录, 因此可以随时从流水重新统计声望
+type ReputationEventDO struct {
+	Id         int64             `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId     int64             `gorm:"column:user_id;index" json:"user_id"` // 声望变化的用户, 即内容作者
+	ActorId    int64             `gorm:"column:actor_id;index:idx_reputation_vote,priority:3" json:"actor_id"`
+	Subject    ReputationSubject `gorm:"column:subject;size:16;index:idx_reputation_vote,priority:1" json:"subject"`
+	SubjectId  int64             `gorm:"column:subject_id;index:idx_reputation_vote,priority:2" json:"subject_id"`
+	Reason     ReputationReason  `gorm:"column:reason;size:20" json:"reason"`
+	Points     int64             `gorm:"column:points" json:"points"`
+	CreateTime time.Time         `gorm:"column:create_time" json:"create_time"`
+}
+
+func (r ReputationEventDO) TableName() string {
+	return "reputation_event"
+}
+
+// UserReputationDO 用户在每类内容上获得的声望合计, 是流水的汇总, 可以由流水重建
+type UserReputationDO struct {
+	UserId  int64             `gorm:"column:user_id;primaryKey;autoIncrement:false" json:"user_id"`
+	Subject ReputationSubject `gorm:"column:subject;primaryKey;size:16" json:"subject"`
+	Points  int64             `gorm:"column:points" json:"points"`
+}
+
+func (r UserReputationDO) TableName() string {
+	return "user_reputation"
+}
+
+// Badge 达到声望门槛后获得的徽章
+// Subject 为空时按总声望计算, 否则只按该类内容获得的声望计算
+type Badge struct {
+	Code        string            `json:"code"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Subject     ReputationSubject `json:"subject,omitempty"`
+	Threshold   int64             `json:"threshold"`
+}
+
+// Badges 全部徽章, 按门槛从低到高展示
+var Badges = []*Badge{
+	{Code: "contributor", Name: "活跃书友", Description: "总声望达到100", Threshold: 100},
+	{Code: "thoughtful_commenter", Name: "评论达人", Description: "帖子评论获得的声望达到200", Subject: ReputationPostComment, Threshold: 200},
+	{Code: "top_reviewer", Name: "Top Reviewer", Description: "书评获得的声望达到500", Subject: ReputationBookComment, Threshold: 500},
+	{Code: "trusted", Name: "资深书友", Description: "总声望达到1000", Threshold: 1000},
+}
+
+// ReputationDTO 用户的声望和已获得的徽章
+// 徽章按当前声望计算, 声望跌回门槛以下时不再展示
+type ReputationDTO struct {
+	Total     int64                       `json:"total"`
+	Breakdown map[ReputationSubject]int64 `json:"breakdown"`
+	Badges    []*Badge                    `json:"badges"`
+}
+
+// NewReputationDTO 根据各类内容的声望合计计算总声望和徽章
+func NewReputationDTO(breakdown map[ReputationSubject]int64) *ReputationDTO {
+	reputation := &ReputationDTO{Breakdown: breakdown, Badges: []*Badge{}}
+	for _, points := range breakdown {
+		reputation.Total += points
+	}
+	for _, badge := range Badges {
+		points := reputation.Total
+		if badge.Subject != "" {
+			points = breakdown[badge.Subject]
+		}
+		if points >= badge.Threshold {
+			reputation.Badges = append(reputation.Badges, badge)
+		}
+	}
+	return reputation
+}
+
+// ReputationResponseDTO 用户声望响应
+type ReputationResponseDTO struct {
+	BaseResp
+	Reputation *ReputationDTO `json:"reputation"`
+}
+
+// BadgeListResponseDTO 徽章列表响应
+type BadgeListResponseDTO struct {
+	BaseResp
+	Badges []*Badge `json:"badges"`
+}
+
+// ReputationEventListResponseDTO 声望流水响应, 按时间倒序, NextCursor 为空表示没有更多
+type ReputationEventListResponseDTO struct {
+	BaseResp
+	Events     []*ReputationEventDO `json:"events"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
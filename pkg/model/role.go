@@ -0,0 +1,51 @@
+package model
+
+// Role 用户角色
+type Role string
+
+const (
+	RoleAdmin     Role = "admin"     // 管理员
+	RoleModerator Role = "moderator" // 版主
+	RoleUser      Role = "user"      // 普通用户
+)
+
+// Permission 权限
+type Permission string
+
+const (
+	PermManageUsers      Permission = "users:manage"        // 管理任意用户
+	PermManageBooks      Permission = "books:manage"        // 管理书籍
+	PermDeleteAnyPost    Permission = "posts:delete_any"    // 删除任意帖子
+	PermDeleteAnyComment Permission = "comments:delete_any" // 删除任意帖子评论和书评
+)
+
+// rolePermissions 角色拥有的权限
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermManageUsers,
+		PermManageBooks,
+		PermDeleteAnyPost,
+		PermDeleteAnyComment,
+	},
+	RoleModerator: {
+		PermDeleteAnyPost,
+		PermDeleteAnyComment,
+	},
+	RoleUser: {},
+}
+
+// Valid 是否为已定义的角色
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// HasPermission 角色是否拥有指定权限
+func (r Role) HasPermission(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+)
+
+// SessionDO 刷新令牌会话
+// 同一次登录轮换出来的刷新令牌共享一个 FamilyId, 旧令牌被重复使用时整条链一起吊销
+type SessionDO struct {
+	Id         int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId     int64      `gorm:"column:user_id;index" json:"user_id"`
+	FamilyId   string     `gorm:"column:family_id;size:36;index" json:"family_id"`
+	TokenHash  string     `gorm:"column:token_hash;size:64;uniqueIndex" json:"-"` // 刷新令牌的sha256, 不存明文
+	ExpiresAt  time.Time  `gorm:"column:expires_at" json:"expires_at"`
+	CreateTime time.Time  `gorm:"column:create_time" json:"create_time"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at" json:"revoked_at"`   // 吊销或被轮换的时间
+	ReplacedBy int64      `gorm:"column:replaced_by" json:"replaced_by"` // 轮换出的新会话ID, 0表示未轮换
+}
+
+func (s SessionDO) TableName() string {
+	return "session"
+}
@@ -0,0 +1,224 @@
+package model
+
+import (
+	"time"
+)
+
+// ShelfStatus 阅读状态书架
+type ShelfStatus string
+
+const (
+	ShelfWantToRead ShelfStatus = "want_to_read" // 想读
+	ShelfReading    ShelfStatus = "reading"      // 在读
+	ShelfRead       ShelfStatus = "read"         // 读过
+)
+
+// Valid 是否为已定义的阅读状态
+func (s ShelfStatus) Valid() bool {
+	switch s {
+	case ShelfWantToRead, ShelfReading, ShelfRead:
+		return true
+	}
+	return false
+}
+
+// ProgressUnit 阅读进度的单位
+type ProgressUnit string
+
+const (
+	ProgressPage    ProgressUnit = "page"    // 读到第几页
+	ProgressPercent ProgressUnit = "percent" // 读了百分之几, 0-100
+)
+
+// Valid 是否为已定义的进度单位
+func (u ProgressUnit) Valid() bool {
+	return u == ProgressPage || u == ProgressPercent
+}
+
+// ShelfDateLayout 开始和读完日期的格式
+const ShelfDateLayout = "2006-01-02"
+
+// MaxShelfRating 用户给书的最高评分
+const MaxShelfRating = 5
+
+// ShelfEntryDO 用户书架上的一本书, 每本书同一时间只处于一种阅读状态
+type ShelfEntryDO struct {
+	Id           int64        `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId       int64        `gorm:"column:user_id;uniqueIndex:idx_shelf_user_book,priority:1;index:idx_shelf_user_status,priority:1" json:"user_id"`
+	BookId       int64        `gorm:"column:book_id;uniqueIndex:idx_shelf_user_book,priority:2;index" json:"book_id"`
+	Status       ShelfStatus  `gorm:"column:status;size:16;index:idx_shelf_user_status,priority:2" json:"status"`
+	StartDate    *time.Time   `gorm:"column:start_date;type:date" json:"start_date"`
+	FinishDate   *time.Time   `gorm:"column:finish_date;type:date" json:"finish_date"`
+	ProgressUnit ProgressUnit `gorm:"column:progress_unit;size:16" json:"progress_unit"`
+	Progress     int          `gorm:"column:progress" json:"progress"`
+	Rating       int          `gorm:"column:rating" json:"rating"` // 用户给的评分, 1-5, 0表示未评分
+	CreateTime   time.Time    `gorm:"column:create_time;autoCreateTime" json:"create_time"`
+	UpdateTime   time.Time    `gorm:"column:update_time;autoUpdateTime" json:"update_time"`
+}
+
+func (s ShelfEntryDO) TableName() string {
+	return "shelf_entry"
+}
+
+// ShelfEntryDTO 书架上的一本书
+type ShelfEntryDTO struct {
+	Id           int64        `json:"id"`
+	UserId       int64        `json:"user_id"`
+	Book         *BookInfoDTO `json:"book"`
+	Status       ShelfStatus  `json:"status"`
+	StartDate    string       `json:"start_date,omitempty"`
+	FinishDate   string       `json:"finish_date,omitempty"`
+	ProgressUnit ProgressUnit `json:"progress_unit,omitempty"`
+	Progress     int          `json:"progress"`
+	Rating       int          `json:"rating,omitempty"`
+	UpdateTime   time.Time    `json:"update_time"`
+}
+
+// TransformToDTO 将ShelfEntryDO转换为ShelfEntryDTO, 书的信息由调用方加载
+func (s *ShelfEntryDO) TransformToDTO(book *BookInfoDTO) *ShelfEntryDTO {
+	return &ShelfEntryDTO{
+		Id:           s.Id,
+		UserId:       s.UserId,
+		Book:         book,
+		Status:       s.Status,
+		StartDate:    formatShelfDate(s.StartDate),
+		FinishDate:   formatShelfDate(s.FinishDate),
+		ProgressUnit: s.ProgressUnit,
+		Progress:     s.Progress,
+		Rating:       s.Rating,
+		UpdateTime:   s.UpdateTime,
+	}
+}
+
+func formatShelfDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(ShelfDateLayout)
+}
+
+// UpdateShelfEntryRequestDTO 把书放上书架或修改阅读状态, 只更新传了的字段
+// 日期格式为 2006-01-02, 传空字符串表示清空
+type UpdateShelfEntryRequestDTO struct {
+	Status       *ShelfStatus  `json:"status"`
+	StartDate    *string       `json:"start_date"`
+	FinishDate   *string       `json:"finish_date"`
+	ProgressUnit *ProgressUnit `json:"progress_unit"`
+	Progress     *int          `json:"progress"`
+	Rating       *int          `json:"rating"` // 1-5, 传0表示取消评分
+}
+
+// ShelfEntryResponseDTO 书架条目响应
+type ShelfEntryResponseDTO struct {
+	BaseResp
+	Entry *ShelfEntryDTO `json:"entry"`
+}
+
+// ShelfEntryListResponseDTO 书架条目列表响应
+type ShelfEntryListResponseDTO struct {
+	BaseResp
+	Total   int64            `json:"total"`
+	Entries []*ShelfEntryDTO `json:"entries"`
+}
+
+// ShelfCountsDTO 各阅读状态的书的数量
+type ShelfCountsDTO struct {
+	WantToRead int64 `json:"want_to_read"`
+	Reading    int64 `json:"reading"`
+	Read       int64 `json:"read"`
+}
+
+// CustomShelfDO 用户自建的书架, 与阅读状态无关, 一本书可以放在多个自建书架上
+type CustomShelfDO struct {
+	Id          int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId      int64     `gorm:"column:user_id;uniqueIndex:idx_custom_shelf_name,priority:1" json:"user_id"`
+	Name        string    `gorm:"column:name;size:64;uniqueIndex:idx_custom_shelf_name,priority:2" json:"name"`
+	Description string    `gorm:"column:description;size:512" json:"description"`
+	CreateTime  time.Time `gorm:"column:create_time;autoCreateTime" json:"create_time"`
+}
+
+func (s CustomShelfDO) TableName() string {
+	return "custom_shelf"
+}
+
+// CustomShelfBookDO 自建书架上的书
+type CustomShelfBookDO struct {
+	Id      int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	ShelfId int64     `gorm:"column:shelf_id;uniqueIndex:idx_custom_shelf_book,priority:1" json:"shelf_id"`
+	BookId  int64     `gorm:"column:book_id;uniqueIndex:idx_custom_shelf_book,priority:2;index" json:"book_id"`
+	AddTime time.Time `gorm:"column:add_time;autoCreateTime" json:"add_time"`
+}
+
+func (s CustomShelfBookDO) TableName() string {
+	return "custom_shelf_book"
+}
+
+// CustomShelfDTO 自建书架
+type CustomShelfDTO struct {
+	Id          int64     `json:"id"`
+	UserId      int64     `json:"user_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	BookCount   int64     `json:"book_count"`
+	CreateTime  time.Time `json:"create_time"`
+}
+
+// TransformToDTO 将CustomShelfDO转换为CustomShelfDTO
+func (s *CustomShelfDO) TransformToDTO(bookCount int64) *CustomShelfDTO {
+	return &CustomShelfDTO{
+		Id:          s.Id,
+		UserId:      s.UserId,
+		Name:        s.Name,
+		Description: s.Description,
+		BookCount:   bookCount,
+		CreateTime:  s.CreateTime,
+	}
+}
+
+// CustomShelfRequestDTO 创建或修改自建书架, 修改时只更新传了的字段
+type CustomShelfRequestDTO struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+// CustomShelfResponseDTO 自建书架响应
+type CustomShelfResponseDTO struct {
+	BaseResp
+	Shelf *CustomShelfDTO `json:"shelf"`
+}
+
+// CustomShelfListResponseDTO 自建书架列表响应
+type CustomShelfListResponseDTO struct {
+	BaseResp
+	Shelves []*CustomShelfDTO `json:"shelves"`
+}
+
+// CustomShelfBookDTO 自建书架上的一本书
+type CustomShelfBookDTO struct {
+	Book    *BookInfoDTO `json:"book"`
+	AddTime time.Time    `json:"add_time"`
+}
+
+// CustomShelfBookListResponseDTO 自建书架上的书的列表响应
+type CustomShelfBookListResponseDTO struct {
+	BaseResp
+	Total int64                 `json:"total"`
+	Books []*CustomShelfBookDTO `json:"books"`
+}
+
+// AddShelfBookRequestDTO 往自建书架上放书的请求
+type AddShelfBookRequestDTO struct {
+	BookId int64 `json:"book_id"`
+}
+
+// ExportedShelvesDTO 导出的书架数据
+type ExportedShelvesDTO struct {
+	Entries       []*ShelfEntryDTO          `json:"entries"`
+	CustomShelves []*ExportedCustomShelfDTO `json:"custom_shelves"`
+}
+
+// ExportedCustomShelfDTO 导出的自建书架, 带上架的全部书
+type ExportedCustomShelfDTO struct {
+	*CustomShelfDTO
+	Books []*CustomShelfBookDTO `json:"books"`
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"time"
+)
+
+// PeriodStatDTO 一个月或一年内读完的书和页数
+type PeriodStatDTO struct {
+	Year  int   `json:"year"`
+	Month int   `json:"month,omitempty"` // 按年统计时为0
+	Books int64 `json:"books"`
+	Pages int64 `json:"pages"`
+}
+
+// RankedItemDTO 按读完的书的数量排序的作者或类型
+type RankedItemDTO struct {
+	Name  string `json:"name"`
+	Books int64  `json:"books"`
+}
+
+// ReadingStatsDTO 阅读统计, 只统计读过的书
+// Year 为0时统计全部年份并给出每年的数据, 否则只统计该年并给出每月的数据
+type ReadingStatsDTO struct {
+	Year          int              `json:"year"`
+	BooksRead     int64            `json:"books_read"`
+	PagesRead     int64            `json:"pages_read"`
+	RatedBooks    int64            `json:"rated_books"`
+	AverageRating float64          `json:"average_rating"`
+	Monthly       []*PeriodStatDTO `json:"monthly,omitempty"`
+	Yearly        []*PeriodStatDTO `json:"yearly,omitempty"`
+	TopAuthors    []*RankedItemDTO `json:"top_authors"`
+	TopGenres     []*RankedItemDTO `json:"top_genres"`
+}
+
+// ReadingStatsResponseDTO 阅读统计响应
+type ReadingStatsResponseDTO struct {
+	BaseResp
+	Stats *ReadingStatsDTO `json:"stats"`
+}
+
+// ReadingGoalDO 年度阅读目标
+type ReadingGoalDO struct {
+	Id          int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserId      int64     `gorm:"column:user_id;uniqueIndex:idx_goal_user_year,priority:1" json:"user_id"`
+	Year        int       `gorm:"column:year;uniqueIndex:idx_goal_user_year,priority:2" json:"year"`
+	TargetBooks int       `gorm:"column:target_books" json:"target_books"`
+	UpdateTime  time.Time `gorm:"column:update_time;autoUpdateTime" json:"update_time"`
+}
+
+func (g ReadingGoalDO) TableName() string {
+	return "reading_goal"
+}
+
+// ReadingGoalDTO 年度阅读目标及完成情况
+type ReadingGoalDTO struct {
+	Year          int     `json:"year"`
+	TargetBooks   int     `json:"target_books"`
+	BooksRead     int64   `json:"books_read"`
+	Percent       float64 `json:"percent"`         // 完成百分比, 可以超过100
+	ExpectedByNow float64 `json:"expected_by_now"` // 按时间进度到今天应读完的数量
+	AheadBy       float64 `json:"ahead_by"`        // 比时间进度多读的数量, 负数表示落后
+}
+
+// SetReadingGoalRequestDTO 设置年度阅读目标的请求
+type SetReadingGoalRequestDTO struct {
+	TargetBooks int `json:"target_books"`
+}
+
+// ReadingGoalResponseDTO 年度阅读目标响应
+type ReadingGoalResponseDTO struct {
+	BaseResp
+	Goal *ReadingGoalDTO `json:"goal"`
+}
+
+// ReadingGoalListResponseDTO 年度阅读目标列表响应
+type ReadingGoalListResponseDTO struct {
+	BaseResp
+	Goals []*ReadingGoalDTO `json:"goals"`
+}
@@ -1,31 +1,157 @@
 package model
 
+import (
+	"time"
+
+	"yujian-backend/pkg/utils"
+)
+
 // UserDTO `用户`DTO结构体
 type UserDTO struct {
-	Id       int64  `json:"id"`
-	Name     string `json:"name"`
-	Password string `json:"password"`
+	Id             int64         `json:"id"`
+	Name           string        `json:"name"`
+	Password       string        `json:"password,omitempty"` // 只用于接收请求, 不会从存储层带出
+	Role           Role          `json:"role"`
+	Email          string        `json:"email,omitempty"`
+	EmailVerified  bool          `json:"email_verified"`
+	DisplayName    string        `json:"display_name,omitempty"`
+	Bio            string        `json:"bio,omitempty"`
+	Avatar         string        `json:"avatar,omitempty"`
+	Gender         Gender        `json:"gender,omitempty"`
+	Region         string        `json:"region,omitempty"`
+	FavoriteGenres []string      `json:"favorite_genres,omitempty"`
+	JoinTime       time.Time     `json:"join_time"`
+	FollowerCount  int64         `json:"follower_count"`
+	FollowingCount int64         `json:"following_count"`
+	MessagePolicy  MessagePolicy `json:"message_policy,omitempty"`
+}
+
+// UpdateUserRequestDTO 修改用户名或密码的请求
+type UpdateUserRequestDTO struct {
+	UserDTO
+	CurrentPassword string `json:"current_password"` // 本人修改已设置的密码时需要提交当前密码
 }
 
+// MaxUserNameLen 用户名的最大长度, 按字符计
+const MaxUserNameLen = 64
+
 // UserDO `用户`存储数据结构体
 type UserDO struct {
-	Id       int64  `json:"id"`
-	Name     string `json:"name"`
-	Password string `json:"password"`
+	Id             int64         `json:"id"`
+	Name           string        `gorm:"column:name;size:64;uniqueIndex" json:"name"` // 唯一, @提及按用户名解析
+	Password       string        `json:"-"`                                           // bcrypt哈希
+	Role           Role          `gorm:"column:role;size:16;default:user" json:"role"`
+	Email          *string       `gorm:"column:email;size:128;uniqueIndex" json:"email"` // 未设置时为 NULL, 唯一索引不会让未设置邮箱的用户互相冲突
+	EmailVerified  bool          `gorm:"column:email_verified" json:"email_verified"`
+	DisplayName    string        `gorm:"column:display_name;size:64" json:"display_name"`
+	Bio            string        `gorm:"column:bio;size:1024" json:"bio"`
+	Avatar         string        `gorm:"column:avatar;size:255" json:"avatar"`
+	Gender         Gender        `gorm:"column:gender;size:16" json:"gender"`
+	Region         string        `gorm:"column:region;size:64" json:"region"`
+	FavoriteGenres string        `gorm:"column:favorite_genres;size:512" json:"favorite_genres"` // JSON数组
+	JoinTime       time.Time     `gorm:"column:join_time;autoCreateTime" json:"join_time"`
+	FollowerCount  int64         `gorm:"column:follower_count;default:0" json:"follower_count"` // 冗余的粉丝数, 随关注关系在同一事务中更新
+	FollowingCount int64         `gorm:"column:following_count;default:0" json:"following_count"`
+	MessagePolicy  MessagePolicy `gorm:"column:message_policy;size:16;default:everyone" json:"message_policy"` // 谁可以发私信
 }
 
 func (userDTO *UserDTO) Transfer() *UserDO {
 	return &UserDO{
-		Id:       userDTO.Id,
-		Name:     userDTO.Name,
-		Password: userDTO.Password,
+		Id:             userDTO.Id,
+		Name:           userDTO.Name,
+		Password:       userDTO.Password,
+		Role:           userDTO.Role,
+		Email:          optionalString(userDTO.Email),
+		EmailVerified:  userDTO.EmailVerified,
+		DisplayName:    userDTO.DisplayName,
+		Bio:            userDTO.Bio,
+		Avatar:         userDTO.Avatar,
+		Gender:         userDTO.Gender,
+		Region:         userDTO.Region,
+		FavoriteGenres: utils.MustToJSONString(userDTO.FavoriteGenres),
+		JoinTime:       userDTO.JoinTime,
+		MessagePolicy:  userDTO.MessagePolicy,
 	}
 }
 
+// Transfer 转换为DTO, 密码哈希不会被带出
 func (userDO *UserDO) Transfer() *UserDTO {
+	var genres []string
+	// 旧数据该列为空字符串, 解析失败按没有偏好处理
+	_ = utils.FromJSONString(userDO.FavoriteGenres, &genres)
+	return &UserDTO{
+		Id:             userDO.Id,
+		Name:           userDO.Name,
+		Role:           userDO.Role,
+		Email:          stringValue(userDO.Email),
+		EmailVerified:  userDO.EmailVerified,
+		DisplayName:    userDO.DisplayName,
+		Bio:            userDO.Bio,
+		Avatar:         userDO.Avatar,
+		Gender:         userDO.Gender,
+		Region:         userDO.Region,
+		FavoriteGenres: genres,
+		JoinTime:       userDO.JoinTime,
+		FollowerCount:  userDO.FollowerCount,
+		FollowingCount: userDO.FollowingCount,
+		MessagePolicy:  userDO.MessagePolicy,
+	}
+}
+
+// optionalString 空字符串转为 nil, 用于可以为 NULL 的列
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// stringValue 取出可以为 NULL 的列的值, NULL 时为空字符串
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// Public 返回可以展示给其他用户的副本, 用于帖子作者等场景, 只保留名称和头像
+func (userDTO *UserDTO) Public() *UserDTO {
 	return &UserDTO{
-		Id:       userDO.Id,
-		Name:     userDO.Name,
-		Password: userDO.Password,
+		Id:          userDTO.Id,
+		Name:        userDTO.Name,
+		Role:        userDTO.Role,
+		DisplayName: userDTO.DisplayName,
+		Avatar:      userDTO.Avatar,
 	}
 }
+
+// UpdateRoleRequestDTO 修改用户角色请求
+type UpdateRoleRequestDTO struct {
+	Role Role `json:"role"`
+}
+
+// DeletionMode 注销账号时对用户内容的处理方式
+type DeletionMode string
+
+const (
+	DeletionModeErase     DeletionMode = "erase"     // 删除用户发布的帖子、评论和书评
+	DeletionModeAnonymize DeletionMode = "anonymize" // 保留内容, 作者改为匿名
+)
+
+// DeletedUserName 已注销或已匿名的作者显示的名称
+const DeletedUserName = "已注销用户"
+
+// Valid 是否为已定义的处理方式
+func (m DeletionMode) Valid() bool {
+	return m == DeletionModeErase || m == DeletionModeAnonymize
+}
+
+// DeleteUserRequestDTO 注销账号请求, 不填处理方式时默认匿名化
+type DeleteUserRequestDTO struct {
+	Mode DeletionMode `json:"mode"`
+}
+
+// DeletedUser 作者不存在时代替显示的用户
+func DeletedUser() *UserDTO {
+	return &UserDTO{Name: DeletedUserName}
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// mentionPattern 匹配 @用户名, 用户名由字母、数字和 _ . - 组成
+var mentionPattern = regexp.MustCompile(`@([\p{L}\p{N}_.-]+)`)
+
+// MentionToken 文本中的一处 @用户名, Start 和 Length 按字符计, 包含 @ 符号
+type MentionToken struct {
+	Name   string
+	Start  int
+	Length int
+}
+
+// ParseMentions 找出文本中所有的 @用户名, 按出现顺序返回
+// @ 前面紧挨着用户名字符时不算提及, 避免把邮箱地址当成提及; 中日韩文字不用空格分词, 紧挨着这些文字的 @ 仍算提及
+func ParseMentions(text string) []MentionToken {
+	var tokens []MentionToken
+	for _, loc := range mentionPattern.FindAllStringSubmatchIndex(text, -1) {
+		if loc[0] > 0 {
+			if r, _ := utf8.DecodeLastRuneInString(text[:loc[0]]); isMentionNameRune(r) && !isCJK(r) {
+				continue
+			}
+		}
+		name := text[loc[2]:loc[3]]
+		tokens = append(tokens, MentionToken{
+			Name:   name,
+			Start:  utf8.RuneCountInString(text[:loc[0]]),
+			Length: 1 + utf8.RuneCountInString(name),
+		})
+	}
+	return tokens
+}
+
+// MentionNameCandidates 文本中匹配到的 name 可能连带了后面的文字, 按从长到短返回可能的用户名
+// 只在真正的分界处截断: 去掉末尾的 . 和 -(通常是句末标点), 或者在中日韩文字内部及其与其他文字的交界处截断
+// (中日韩文字不用空格分词, "@张三你好" 中的用户名可能是 "张三"); 不会截断连续的字母、数字和 _,
+// 避免 "@johnny" 提及到 "john"。超过 maxLen 个字符的候选不返回
+func MentionNameCandidates(name string, maxLen int) []string {
+	runes := []rune(name)
+	seen := make(map[string]bool)
+	var candidates []string
+	add := func(candidate string) {
+		if candidate != "" && !seen[candidate] && utf8.RuneCountInString(candidate) <= maxLen {
+			seen[candidate] = true
+			candidates = append(candidates, candidate)
+		}
+	}
+	for i := len(runes); i > 0; i-- {
+		if i < len(runes) && !isCJK(runes[i-1]) && !isCJK(runes[i]) {
+			continue
+		}
+		candidate := string(runes[:i])
+		add(candidate)
+		add(strings.TrimRight(candidate, ".-"))
+	}
+	return candidates
+}
+
+func isMentionNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_' || r == '.' || r == '-'
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
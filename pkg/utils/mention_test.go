@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []MentionToken
+	}{
+		{
+			name: "ascii",
+			text: "hi @bob and @alice_1",
+			want: []MentionToken{{Name: "bob", Start: 3, Length: 4}, {Name: "alice_1", Start: 12, Length: 8}},
+		},
+		{
+			name: "cjk name followed by text",
+			text: "@张三你好",
+			want: []MentionToken{{Name: "张三你好", Start: 0, Length: 5}},
+		},
+		{
+			name: "cjk punctuation ends name",
+			text: "你好@张三，明天见。@李四！",
+			want: []MentionToken{{Name: "张三", Start: 2, Length: 3}, {Name: "李四", Start: 10, Length: 3}},
+		},
+		{
+			name: "latin text before at",
+			text: "ab@bob",
+			want: nil,
+		},
+		{
+			name: "trailing period kept for lookup",
+			text: "thanks @bob.",
+			want: []MentionToken{{Name: "bob.", Start: 7, Length: 5}},
+		},
+		{
+			name: "email is not a mention",
+			text: "mail bob@example.com",
+			want: nil,
+		},
+		{
+			name: "bare at sign",
+			text: "@ @@",
+			want: nil,
+		},
+		{
+			name: "double at",
+			text: "@@bob",
+			want: []MentionToken{{Name: "bob", Start: 1, Length: 4}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseMentions(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseMentions(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMentionNameCandidates(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{name: "bob", want: []string{"bob"}},
+		{name: "johnny", want: []string{"johnny"}},
+		{name: "alice_1", want: []string{"alice_1"}},
+		{name: "bob.", want: []string{"bob.", "bob"}},
+		{name: "bob.x", want: []string{"bob.x"}},
+		{name: "张三你好", want: []string{"张三你好", "张三你", "张三", "张"}},
+		{name: "Tom你好", want: []string{"Tom你好", "Tom你", "Tom"}},
+		{name: "张三abc", want: []string{"张三abc", "张三", "张"}},
+		{name: "abcdefghi", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MentionNameCandidates(tt.name, 8); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("MentionNameCandidates(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
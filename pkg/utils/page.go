@@ -0,0 +1,24 @@
+package utils
+
+import "strconv"
+
+// ParsePage 解析 offset/limit 分页参数, 空字符串使用默认值, limit 超过上限时取上限
+// 参数不是合法的非负整数时返回 false
+func ParsePage(offsetStr, limitStr string, defaultLimit, maxLimit int) (offset int, limit int, ok bool) {
+	limit = defaultLimit
+	var err error
+	if offsetStr != "" {
+		if offset, err = strconv.Atoi(offsetStr); err != nil || offset < 0 {
+			return 0, 0, false
+		}
+	}
+	if limitStr != "" {
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit <= 0 {
+			return 0, 0, false
+		}
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return offset, limit, true
+}
@@ -0,0 +1,10 @@
+package utils
+
+// Truncate 按字符截断字符串, 避免超出列长度
+func Truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}